@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// Return the pipeline identified by 'key' and the provider it was fetched from
+func (c *Cache) pipelineProvider(key PipelineKey) (Pipeline, CIProvider, error) {
+	pipeline, exists := c.Pipeline(key)
+	if !exists {
+		return Pipeline{}, nil, ErrUnknownStep
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return Pipeline{}, nil, fmt.Errorf("no matching provider found in cache for account ID %q", pipeline.providerID)
+	}
+
+	return pipeline, provider, nil
+}
+
+// Ask the provider of the step identified by 'key' to run the step again. The pipeline of the
+// step is monitored again right away.
+func (c *Cache) Restart(ctx context.Context, key taskKey) error {
+	pKey, stepIDs := key.pipelineKeyAndPath()
+	_, provider, err := c.pipelineProvider(pKey)
+	if err != nil {
+		return err
+	}
+	restarter, ok := provider.(Restarter)
+	if !ok {
+		return ErrNotSupported
+	}
+	step, exists := c.Step(pKey, stepIDs)
+	if !exists {
+		return ErrUnknownStep
+	}
+
+	if err := restarter.Restart(ctx, step); err != nil {
+		return err
+	}
+
+	if err := c.forget(pKey); err != nil {
+		return err
+	}
+	c.wakeUpMonitor(pKey)
+
+	return nil
+}
+
+// Ask the provider of the step identified by 'key' to cancel the step. The pipeline of the step
+// is updated right away.
+func (c *Cache) Cancel(ctx context.Context, key taskKey) error {
+	pKey, stepIDs := key.pipelineKeyAndPath()
+	_, provider, err := c.pipelineProvider(pKey)
+	if err != nil {
+		return err
+	}
+	canceler, ok := provider.(Canceler)
+	if !ok {
+		return ErrNotSupported
+	}
+	step, exists := c.Step(pKey, stepIDs)
+	if !exists {
+		return ErrUnknownStep
+	}
+
+	if err := canceler.Cancel(ctx, step); err != nil {
+		return err
+	}
+	c.wakeUpMonitor(pKey)
+
+	return nil
+}
+
+// Ask the provider of the pipeline identified by 'key' to start a new pipeline for the same git
+// reference of the repository of 'target'
+func (c *Cache) Trigger(ctx context.Context, target Target, key PipelineKey) error {
+	pipeline, provider, err := c.pipelineProvider(key)
+	if err != nil {
+		return err
+	}
+	triggerer, ok := provider.(Triggerer)
+	if !ok {
+		return ErrNotSupported
+	}
+	if pipeline.Ref == "" {
+		return fmt.Errorf("git reference of pipeline %s is unknown", pipeline.ID)
+	}
+
+	// Providers need the URL of the repository, not the path to a local clone
+	repo := target.Repository
+	switch originURL, _, err := GitOriginURL(target.Repository, target.Ref); err {
+	case nil, ErrUnknownGitReference:
+		repo = originURL
+	case ErrUnknownRepositoryURL:
+		// target.Repository is already a URL
+	default:
+		return err
+	}
+
+	return triggerer.Trigger(ctx, repo, pipeline.Ref)
+}
+
+// Ask the provider of the manual step identified by 'key' to start the step with the environment
+// variables 'variables'. The step is shown as pending until the next update of its pipeline,
+// which happens right away.
+func (c *Cache) Play(ctx context.Context, key taskKey, variables map[string]string) error {
+	pKey, stepIDs := key.pipelineKeyAndPath()
+	_, provider, err := c.pipelineProvider(pKey)
+	if err != nil {
+		return err
+	}
+	player, ok := provider.(Player)
+	if !ok {
+		return ErrNotSupported
+	}
+	step, exists := c.Step(pKey, stepIDs)
+	if !exists {
+		return ErrUnknownStep
+	}
+	if step.State != Manual {
+		return fmt.Errorf("%s is not a manual step", step.Name)
+	}
+
+	if err := player.Play(ctx, step, variables); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	if p, exists := c.pipelineByKey[pKey]; exists {
+		p.Step = p.Step.withPendingStep(stepIDs)
+	}
+	c.mutex.Unlock()
+	c.wakeUpMonitor(pKey)
+
+	return nil
+}
+
+// Return a copy of s where the descendant of s identified by the path 'stepIDs' and all its
+// ancestors are pending, unless they are already active
+func (s Step) withPendingStep(stepIDs []string) Step {
+	if !s.State.IsActive() {
+		s.State = Pending
+	}
+	if len(stepIDs) == 0 {
+		return s
+	}
+
+	children := make([]Step, len(s.Children))
+	for i, child := range s.Children {
+		if child.ID == stepIDs[0] {
+			child = child.withPendingStep(stepIDs[1:])
+		}
+		children[i] = child
+	}
+	s.Children = children
+
+	return s
+}
+
+// Drop the logs of the pipeline identified by 'key' and stop relying on the copy of the
+// pipeline found in store. This is required once the pipeline is restarted since finished
+// steps are expected never to change.
+func (c *Cache) forget(key PipelineKey) error {
+	c.mutex.Lock()
+	for u, k := range c.storedPipelineByURL {
+		if k == key {
+			delete(c.storedPipelineByURL, u)
+		}
+	}
+	var step Step
+	if p, exists := c.pipelineByKey[key]; exists {
+		p.Step = withoutLogContent(p.Step)
+		step = p.Step
+	}
+	c.mutex.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+
+	var deleteLogs func(s Step, stepIDs []string) error
+	deleteLogs = func(s Step, stepIDs []string) error {
+		if err := c.store.deleteLog(key, stepIDs); err != nil {
+			return err
+		}
+		for _, child := range s.Children {
+			if err := deleteLogs(child, append(stepIDs[:len(stepIDs):len(stepIDs)], child.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return deleteLogs(step, nil)
+}
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type restartProvider struct {
+	logProvider
+	restarted *[]string
+}
+
+func (p restartProvider) Restart(ctx context.Context, step Step) error {
+	*p.restarted = append(*p.restarted, step.ID)
+	return nil
+}
+
+// Mark the monitoring of the pipeline identified by key as over, as if the pipeline had
+// finished, and return a function failing the test if the monitoring is not resumed shortly
+func stopMonitor(c Cache, key PipelineKey) func(t *testing.T) {
+	resumed := make(chan struct{})
+	c.stopMonitor(key, func() { close(resumed) })
+
+	return func(t *testing.T) {
+		select {
+		case <-resumed:
+		case <-time.After(time.Second):
+			t.Fatal("monitoring of the pipeline was not resumed")
+		}
+	}
+}
+
+func TestCache_Restart(t *testing.T) {
+	pipeline := Pipeline{
+		Step: Step{
+			ID:    "1",
+			State: Failed,
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Failed,
+				},
+			},
+		},
+	}
+	key := taskKey{
+		providerHost: "example.com",
+		stepIDs: StepPath{
+			{Valid: true, String: "1"},
+			{Valid: true, String: "2"},
+		},
+	}
+
+	t.Run("provider unable to restart steps", func(t *testing.T) {
+		provider := logProvider{}
+		c := NewCache([]CIProvider{provider}, nil)
+		pipeline.SetProvider(provider.ID(), provider.Host())
+		if err := c.SavePipeline(Target{}, pipeline); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Restart(context.Background(), key); err != ErrNotSupported {
+			t.Fatalf("expected %v but got %v", ErrNotSupported, err)
+		}
+	})
+
+	t.Run("logs of the restarted pipeline are dropped", func(t *testing.T) {
+		provider := restartProvider{restarted: &[]string{}}
+		c := NewCache([]CIProvider{provider}, nil)
+		pipeline.SetProvider(provider.ID(), provider.Host())
+		if err := c.SavePipeline(Target{}, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SaveLog(pipeline.Key(), []string{"2"}, "log\n"); err != nil {
+			t.Fatal(err)
+		}
+		assertResumed := stopMonitor(c, pipeline.Key())
+
+		if err := c.Restart(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+		if len(*provider.restarted) != 1 || (*provider.restarted)[0] != "2" {
+			t.Fatalf("expected step 2 to be restarted but got %v", *provider.restarted)
+		}
+		step, exists := c.Step(pipeline.Key(), []string{"2"})
+		if !exists {
+			t.Fatal("step not found")
+		}
+		if step.Log.Content.Valid {
+			t.Fatalf("expected log to be dropped but got %q", step.Log.Content.String)
+		}
+
+		assertResumed(t)
+	})
+}
+
+// Provider processing restart requests asynchronously: the pipeline only changes a few
+// requests after the restart
+type asyncRestartProvider struct {
+	logProvider
+	restarted *int32
+	calls     *int32
+}
+
+func (p asyncRestartProvider) Restart(ctx context.Context, step Step) error {
+	atomic.StoreInt32(p.restarted, 1)
+	return nil
+}
+
+func (p asyncRestartProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	pipeline := Pipeline{
+		Step: Step{
+			ID:        "1",
+			State:     Failed,
+			UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Failed,
+				},
+			},
+		},
+	}
+	if calls := atomic.AddInt32(p.calls, 1); atomic.LoadInt32(p.restarted) == 1 && calls >= 3 {
+		pipeline.State = Running
+		pipeline.Children[0].State = Running
+		pipeline.UpdatedAt = pipeline.UpdatedAt.Add(time.Minute)
+	}
+
+	return pipeline, nil
+}
+
+func TestCache_Restart_async(t *testing.T) {
+	interval := pipelinePollingInterval
+	pipelinePollingInterval = time.Millisecond
+	defer func() { pipelinePollingInterval = interval }()
+
+	provider := asyncRestartProvider{restarted: new(int32), calls: new(int32)}
+	c := NewCache([]CIProvider{provider}, nil)
+	updates := make(chan time.Time)
+	go func() {
+		for range updates {
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The pipeline is not active so its monitoring stops right away
+	if err := c.monitorPipeline(ctx, provider, "https://example.com/1", Target{}, updates, false); err != nil {
+		t.Fatal(err)
+	}
+
+	key := taskKey{
+		providerHost: provider.Host(),
+		stepIDs: StepPath{
+			{Valid: true, String: "1"},
+			{Valid: true, String: "2"},
+		},
+	}
+	if err := c.Restart(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		pipeline, exists := c.Pipeline(PipelineKey{ProviderHost: provider.Host(), ID: "1"})
+		if exists && pipeline.State == Running {
+			break
+		}
+		select {
+		case <-time.After(time.Millisecond):
+		case <-timeout:
+			t.Fatal("the restarted pipeline was not fetched again")
+		}
+	}
+}
+
+type playProvider struct {
+	logProvider
+	variables *map[string]string
+}
+
+func (p playProvider) Play(ctx context.Context, step Step, variables map[string]string) error {
+	*p.variables = variables
+	return nil
+}
+
+func TestCache_Play(t *testing.T) {
+	provider := playProvider{variables: &map[string]string{}}
+	c := NewCache([]CIProvider{provider}, nil)
+	pipeline := Pipeline{
+		Step: Step{
+			ID:    "1",
+			State: Manual,
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Passed,
+				},
+				{
+					ID:    "3",
+					State: Manual,
+				},
+			},
+		},
+	}
+	pipeline.SetProvider(provider.ID(), provider.Host())
+	if err := c.SavePipeline(Target{}, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	assertResumed := stopMonitor(c, pipeline.Key())
+
+	stepKey := func(ID string) taskKey {
+		return taskKey{
+			providerHost: provider.Host(),
+			stepIDs: StepPath{
+				{Valid: true, String: "1"},
+				{Valid: true, String: ID},
+			},
+		}
+	}
+
+	t.Run("step that is not manual", func(t *testing.T) {
+		if err := c.Play(context.Background(), stepKey("2"), nil); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("manual step", func(t *testing.T) {
+		variables := map[string]string{"ENV": "production"}
+		if err := c.Play(context.Background(), stepKey("3"), variables); err != nil {
+			t.Fatal(err)
+		}
+		if (*provider.variables)["ENV"] != "production" {
+			t.Fatalf("expected variables %v but got %v", variables, *provider.variables)
+		}
+
+		p, exists := c.Pipeline(pipeline.Key())
+		if !exists {
+			t.Fatal("pipeline not found")
+		}
+		states := []State{p.State, p.Children[0].State, p.Children[1].State}
+		if diff := cmp.Diff([]State{Pending, Passed, Pending}, states); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		assertResumed(t)
+	})
+}
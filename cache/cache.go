@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,8 @@ import (
 	"github.com/nbedos/citop/utils"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
 
 var ErrUnknownRepositoryURL = errors.New("unknown repository URL")
@@ -35,6 +39,46 @@ type CIProvider interface {
 	BuildFromURL(ctx context.Context, u string) (Pipeline, error)
 }
 
+// CIProviders implementing this interface can fetch the log of a step incrementally instead of
+// downloading the whole log each time new content is available
+type LogStreamer interface {
+	// Write to w the content of the log of 'step' found after the position 'offset' and return
+	// the position of the end of the log. The unit of offset depends on the provider but an
+	// offset of zero always refers to the start of the log. Content held back while the step is
+	// active, such as an incomplete last line, must be written once the step is finished.
+	StreamLog(ctx context.Context, step Step, offset int, w io.Writer) (int, error)
+}
+
+// CIProviders implementing this interface can run a pipeline or some of its steps again
+type Restarter interface {
+	// Run 'step' again. ErrNotSupported is returned if the provider cannot restart this type of
+	// step.
+	Restart(ctx context.Context, step Step) error
+}
+
+// CIProviders implementing this interface can cancel an active pipeline or some of its steps
+type Canceler interface {
+	// Cancel 'step'. ErrNotSupported is returned if the provider cannot cancel this type of step.
+	Cancel(ctx context.Context, step Step) error
+}
+
+// CIProviders implementing this interface can start a new pipeline without a push to the
+// repository
+type Triggerer interface {
+	// Start a new pipeline for the branch or tag 'ref' of the repository located at the URL
+	// 'repo'
+	Trigger(ctx context.Context, repo string, ref string) error
+}
+
+// CIProviders implementing this interface can start steps waiting for a manual action
+type Player interface {
+	// Start the manual step 'step'. Variables are passed to the step as environment variables.
+	// ErrNotSupported is returned if the provider cannot start this type of step.
+	Play(ctx context.Context, step Step, variables map[string]string) error
+}
+
+var ErrNotSupported = errors.New("operation not supported by the CI provider")
+
 type SourceProvider interface {
 	// Unique identifier of the provider instance among all other instances
 	ID() string
@@ -42,8 +86,47 @@ type SourceProvider interface {
 	Commit(ctx context.Context, repo string, sha string) (Commit, error)
 }
 
-// Poll provider at increasing interval for the URL of statuses associated to "ref"
-func monitorRefStatuses(ctx context.Context, p SourceProvider, url string, ref string, commitc chan<- Commit) error {
+// SourceProviders implementing this interface can resolve pull requests (merge requests on GitLab)
+// to their head commit
+type PullRequestProvider interface {
+	// Return the SHA identifier of the head commit of the pull request 'number' of the repository
+	// 'repo'. ErrUnknownGitReference is returned if there is no such pull request.
+	PullRequestHead(ctx context.Context, repo string, number int) (string, error)
+}
+
+// SourceProviders implementing this interface can list the history of a git reference
+type HistoryProvider interface {
+	// Return the last 'limit' commits of the history of 'ref' in the repository 'repo', most
+	// recent commit first. Statuses of the commits are not set.
+	Commits(ctx context.Context, repo string, ref string, limit int) ([]Commit, error)
+}
+
+// Return the number of the pull request referenced by 'ref' if 'ref' is written "#1234" or
+// "pr:1234"
+func PullRequestNumber(ref string) (int, bool) {
+	var s string
+	switch {
+	case strings.HasPrefix(ref, "#"):
+		s = strings.TrimPrefix(ref, "#")
+	case strings.HasPrefix(ref, "pr:"):
+		s = strings.TrimPrefix(ref, "pr:")
+	default:
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(s)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+
+	return number, true
+}
+
+// Poll provider at increasing interval for the URL of statuses associated to "ref". If "ref"
+// refers to a pull request, its head commit is looked up again before each poll so that
+// force-pushes to the pull request are followed. If 'once' is true, polling stops as soon as
+// statuses are found.
+func monitorRefStatuses(ctx context.Context, p SourceProvider, url string, ref string, commitc chan<- Commit, once bool) error {
 	b := backoff.ExponentialBackOff{
 		InitialInterval:     10 * time.Second,
 		RandomizationFactor: backoff.DefaultRandomizationFactor,
@@ -54,6 +137,20 @@ func monitorRefStatuses(ctx context.Context, p SourceProvider, url string, ref s
 	}
 	b.Reset()
 
+	number, isPullRequest := PullRequestNumber(ref)
+	var pullRequestProvider PullRequestProvider
+	if isPullRequest {
+		var ok bool
+		if pullRequestProvider, ok = p.(PullRequestProvider); !ok {
+			return ErrUnknownRepositoryURL
+		}
+		head, err := pullRequestProvider.PullRequestHead(ctx, url, number)
+		if err != nil {
+			return err
+		}
+		ref = head
+	}
+
 	commit, err := p.Commit(ctx, url, ref)
 	if err != nil {
 		return err
@@ -73,6 +170,29 @@ func monitorRefStatuses(ctx context.Context, p SourceProvider, url string, ref s
 			return ctx.Err()
 		}
 
+		if isPullRequest {
+			head, err := pullRequestProvider.PullRequestHead(ctx, url, number)
+			if err != nil {
+				if err != context.Canceled {
+					err = fmt.Errorf("provider %s: %v (#%d@%s)", p.ID(), err, number, url)
+				}
+				return err
+			}
+			if head != commit.Sha {
+				if commit, err = p.Commit(ctx, url, head); err != nil {
+					return err
+				}
+				select {
+				case commitc <- commit:
+					// Do nothing
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				b.Reset()
+			}
+			ref = head
+		}
+
 		statuses, err := p.RefStatuses(ctx, url, ref, commit.Sha)
 		if err != nil {
 			if err != ErrUnknownRepositoryURL && err != context.Canceled {
@@ -92,6 +212,10 @@ func monitorRefStatuses(ctx context.Context, p SourceProvider, url string, ref s
 			}
 			b.Reset()
 		}
+
+		if once && len(commit.Statuses) > 0 {
+			break
+		}
 	}
 
 	return nil
@@ -327,6 +451,95 @@ func GitOriginURL(path string, sha string) (string, Commit, error) {
 	return origin, c, nil
 }
 
+// Return the last 'limit' commits of the history of 'ref' in the local repository located at
+// 'path', most recent commit first. ErrUnknownRepositoryURL is returned if 'path' does not refer
+// to a local repository.
+func GitCommits(path string, ref string, limit int) ([]Commit, error) {
+	// See GitOriginURL for why invalid paths must not be handed to go-git
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			err = ErrUnknownRepositoryURL
+		}
+		return nil, err
+	}
+
+	r, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var hash plumbing.Hash
+	if p, err := r.ResolveRevision(plumbing.Revision(ref)); err == nil {
+		hash = *p
+	} else {
+		// Same workaround as in GitOriginURL for abbreviated SHAs
+		cmd := exec.Command("git", "-C", path, "show", ref, "--pretty=format:%H")
+		bs, err := cmd.Output()
+		if err != nil {
+			return nil, ErrUnknownGitReference
+		}
+		hash = plumbing.NewHash(strings.SplitN(string(bs), "\n", 2)[0])
+	}
+
+	iter, err := r.Log(&git.LogOptions{From: hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	commits := make([]Commit, 0, limit)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		if len(commits) >= limit {
+			return storer.ErrStop
+		}
+		commits = append(commits, Commit{
+			Sha:     commit.Hash.String(),
+			Author:  commit.Author.String(),
+			Date:    commit.Author.When,
+			Message: commit.Message,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// Notes attached to a commit under this reference are expected to contain the URLs of the CI
+// pipelines of the commit, one URL per line
+const GitNotesRef = "refs/notes/ci"
+
+// Return the URLs contained in the note attached to the commit 'sha' of the local repository
+// located at 'path' under GitNotesRef. A commit without note has no URL.
+func GitNoteURLs(path string, sha string) ([]string, error) {
+	// go-git does not support git notes so use the local git binary
+	cmd := exec.Command("git", "-C", path, "notes", "--ref", GitNotesRef, "show", sha)
+	bs, err := cmd.Output()
+	if err != nil {
+		switch err := err.(type) {
+		case *exec.ExitError:
+			// No note is attached to this commit
+			return nil, nil
+		case *exec.Error:
+			if err.Err == exec.ErrNotFound {
+				return nil, nil
+			}
+		}
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	for _, line := range strings.Split(string(bs), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			urls = append(urls, line)
+		}
+	}
+
+	return urls, nil
+}
+
 type StepType int
 
 const (
@@ -336,6 +549,21 @@ const (
 	StepTask
 )
 
+func (t StepType) String() string {
+	switch t {
+	case StepPipeline:
+		return "pipeline"
+	case StepStage:
+		return "stage"
+	case StepJob:
+		return "job"
+	case StepTask:
+		return "task"
+	default:
+		return "unknown"
+	}
+}
+
 type Log struct {
 	Key     string
 	Content utils.NullString
@@ -371,6 +599,58 @@ func (s Step) Map(f func(Step) Step) Step {
 	return s
 }
 
+// Return a copy of s where the content of the log of the descendant identified by the path
+// 'stepIDs' is set to 'log'. The second return value is false if there is no such step.
+// Children of s are copied along the path so that s itself is left untouched.
+func (s Step) withLog(stepIDs []string, log string) (Step, bool) {
+	if len(stepIDs) == 0 {
+		s.Log.Content = utils.NullString{Valid: true, String: log}
+		return s, true
+	}
+
+	for i, child := range s.Children {
+		if child.ID == stepIDs[0] {
+			child, exists := child.withLog(stepIDs[1:], log)
+			if !exists {
+				return s, false
+			}
+			children := make([]Step, len(s.Children))
+			copy(children, s.Children)
+			children[i] = child
+			s.Children = children
+			return s, true
+		}
+	}
+
+	return s, false
+}
+
+// Return a copy of s where finished steps without log content take the log content of the
+// matching step of 'previous', if that step was finished too
+func (s Step) withLogsOf(previous Step) Step {
+	if !s.Log.Content.Valid && previous.Log.Content.Valid && !s.State.IsActive() &&
+		!previous.State.IsActive() && s.Log.Key == previous.Log.Key {
+		s.Log.Content = previous.Log.Content
+	}
+
+	if len(s.Children) > 0 && len(previous.Children) > 0 {
+		previousChildren := make(map[string]Step, len(previous.Children))
+		for _, child := range previous.Children {
+			previousChildren[child.ID] = child
+		}
+		children := make([]Step, len(s.Children))
+		for i, child := range s.Children {
+			if previousChild, exists := previousChildren[child.ID]; exists {
+				child = child.withLogsOf(previousChild)
+			}
+			children[i] = child
+		}
+		s.Children = children
+	}
+
+	return s
+}
+
 type GitReference struct {
 	SHA   string
 	Ref   string
@@ -390,6 +670,12 @@ func (p Pipeline) Diff(other Pipeline) string {
 	return cmp.Diff(p, other, options)
 }
 
+// Associate the pipeline to the CI provider identified by 'id' and located at 'host'
+func (p *Pipeline) SetProvider(id string, host string) {
+	p.providerID = id
+	p.providerHost = host
+}
+
 type PipelineKey struct {
 	ProviderHost string
 	ID           string
@@ -402,14 +688,44 @@ func (p Pipeline) Key() PipelineKey {
 	}
 }
 
+// Git reference of a repository monitored by the user. Commits and pipelines are stored in cache
+// by target since the same reference may exist in several repositories.
+type Target struct {
+	// Path to a local repository or URL of an online repository
+	Repository string
+	// SHA identifier of a commit, or name of a tag or a branch
+	Ref string
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s@%s", t.Ref, t.Repository)
+}
+
 type Cache struct {
 	ciProvidersByID map[string]CIProvider
 	sourceProviders []SourceProvider
-	mutex           *sync.Mutex
+	// On-disk copy of the cache, nil if persistence is disabled
+	store *Store
+	mutex *sync.Mutex
 	// All the following data structures must be accessed after acquiring mutex
-	commitsByRef  map[string]Commit
+	commitsByRef  map[Target]Commit
 	pipelineByKey map[PipelineKey]*Pipeline
-	pipelineByRef map[string]map[PipelineKey]*Pipeline
+	pipelineByRef map[Target]map[PipelineKey]*Pipeline
+	// Keys of the pipelines loaded from store, indexed by the URL used for looking them up
+	storedPipelineByURL map[string]PipelineKey
+	// Channel receiving state transitions of pipelines, nil if nobody is interested in them
+	transitions chan<- StateTransition
+	// Monitoring of each pipeline, used for requesting an update of a pipeline right away
+	monitors map[PipelineKey]*pipelineMonitor
+	// SHA identifiers of the recent commits of each target, most recent commit first
+	historyByRef map[Target][]string
+}
+
+type pipelineMonitor struct {
+	// Channel waking up the monitoring of the pipeline while it is running
+	wakeup chan struct{}
+	// Function resuming the monitoring of the pipeline once it is over, nil while it is running
+	resume func()
 }
 
 func NewCache(CIProviders []CIProvider, sourceProviders []SourceProvider) Cache {
@@ -419,22 +735,116 @@ func NewCache(CIProviders []CIProvider, sourceProviders []SourceProvider) Cache
 	}
 
 	return Cache{
-		commitsByRef:    make(map[string]Commit),
-		pipelineByKey:   make(map[PipelineKey]*Pipeline),
-		pipelineByRef:   make(map[string]map[PipelineKey]*Pipeline),
-		mutex:           &sync.Mutex{},
-		ciProvidersByID: providersByAccountID,
-		sourceProviders: sourceProviders,
+		commitsByRef:        make(map[Target]Commit),
+		pipelineByKey:       make(map[PipelineKey]*Pipeline),
+		pipelineByRef:       make(map[Target]map[PipelineKey]*Pipeline),
+		storedPipelineByURL: make(map[string]PipelineKey),
+		monitors:            make(map[PipelineKey]*pipelineMonitor),
+		historyByRef:        make(map[Target][]string),
+		mutex:               &sync.Mutex{},
+		ciProvidersByID:     providersByAccountID,
+		sourceProviders:     sourceProviders,
 	}
 }
 
+// Return a new cache loaded with the content of store. Finished pipelines, commits and logs of
+// finished steps are saved to store as they are retrieved from providers.
+func NewPersistentCache(CIProviders []CIProvider, sourceProviders []SourceProvider, store *Store) (Cache, error) {
+	c := NewCache(CIProviders, sourceProviders)
+	c.store = store
+
+	return c, c.load()
+}
+
+// Load commits and pipelines from c.store
+func (c *Cache) load() error {
+	commits, err := c.store.commits()
+	if err != nil {
+		return err
+	}
+	for _, record := range commits {
+		c.SaveCommit(record.Target, record.Commit)
+	}
+
+	pipelines, err := c.store.pipelines()
+	if err != nil {
+		return err
+	}
+	for _, record := range pipelines {
+		// Only pipelines of commits still referenced by their target are relevant
+		commit, exists := c.Commit(record.Target)
+		if exists && record.Pipeline.SHA != "" && commit.Sha != record.Pipeline.SHA {
+			continue
+		}
+		p := record.pipeline()
+		if err := c.SavePipeline(record.Target, p); err != nil && err != ErrObsoleteBuild {
+			return err
+		}
+		c.mutex.Lock()
+		c.storedPipelineByURL[record.URL] = p.Key()
+		c.mutex.Unlock()
+	}
+
+	return nil
+}
+
+// Return the pipeline loaded from store for the URL u if the pipeline is finished
+func (c *Cache) storedPipeline(u string) (Pipeline, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, exists := c.storedPipelineByURL[u]
+	if !exists {
+		return Pipeline{}, false
+	}
+	p, exists := c.pipelineByKey[key]
+	if !exists || p == nil || p.State.IsActive() {
+		return Pipeline{}, false
+	}
+
+	return *p, true
+}
+
+// Save the pipeline identified by the URL u to store if it is finished
+func (c *Cache) storePipeline(u string, target Target, p Pipeline) error {
+	if c.store == nil || p.State.IsActive() {
+		return nil
+	}
+
+	return c.store.savePipeline(pipelineRecord{
+		URL:          u,
+		ProviderID:   p.providerID,
+		ProviderHost: p.providerHost,
+		Target:       target,
+		Pipeline:     p,
+	})
+}
+
+// Save the commit referenced by target to store
+func (c *Cache) storeCommit(target Target) error {
+	if c.store == nil {
+		return nil
+	}
+
+	commit, exists := c.Commit(target)
+	if !exists {
+		return nil
+	}
+
+	return c.store.saveCommit(commitRecord{
+		Target: target,
+		Commit: commit,
+	})
+}
+
 var ErrObsoleteBuild = errors.New("build to save is older than current build in cache")
+var ErrUnknownStep = errors.New("no matching step in cache")
 
 // Store build in cache. If a build from the same provider and with the same ID is
 // already stored in cache, it will be overwritten if the build to save is more recent
 // than the build in cache. If the build to save is older than the build in cache,
 // SavePipeline will return ErrObsoleteBuild.
-func (c *Cache) SavePipeline(ref string, p Pipeline) error {
+func (c *Cache) SavePipeline(target Target, p Pipeline) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -442,38 +852,72 @@ func (c *Cache) SavePipeline(ref string, p Pipeline) error {
 	// UpdatedAt refers to the last update of the build and does not reflect an eventual
 	// update of a job so default to always updating an active build
 	if exists && !p.State.IsActive() && !p.UpdatedAt.After(existingBuild.UpdatedAt) {
-		// Point ref to existingBuild
-		if _, exists := c.pipelineByRef[ref]; !exists {
-			c.pipelineByRef[ref] = make(map[PipelineKey]*Pipeline)
+		// Point target to existingBuild
+		if _, exists := c.pipelineByRef[target]; !exists {
+			c.pipelineByRef[target] = make(map[PipelineKey]*Pipeline)
 		}
-		if c.pipelineByRef[ref][p.Key()] == existingBuild {
+		if c.pipelineByRef[target][p.Key()] == existingBuild {
 			return ErrObsoleteBuild
 		}
-		c.pipelineByRef[ref][p.Key()] = existingBuild
+		c.pipelineByRef[target][p.Key()] = existingBuild
 		return nil
 	}
 
+	if exists {
+		// Logs fetched for finished steps remain valid and must not be lost
+		p.Step = p.Step.withLogsOf(existingBuild.Step)
+	}
+
 	c.pipelineByKey[p.Key()] = &p
-	// Point ref to new build
-	if _, exists := c.pipelineByRef[ref]; !exists {
-		c.pipelineByRef[ref] = make(map[PipelineKey]*Pipeline)
+	// Point target to new build
+	if _, exists := c.pipelineByRef[target]; !exists {
+		c.pipelineByRef[target] = make(map[PipelineKey]*Pipeline)
 	}
-	c.pipelineByRef[ref][p.Key()] = &p
+	c.pipelineByRef[target][p.Key()] = &p
+
+	return nil
+}
+
+// Store the content of the log of the step identified by the pipeline key 'key' and the path
+// 'stepIDs' from the pipeline step to the step. ErrUnknownStep is returned if there is no such
+// step in cache.
+func (c *Cache) SaveLog(key PipelineKey, stepIDs []string, log string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	p, exists := c.pipelineByKey[key]
+	if !exists || p == nil {
+		return ErrUnknownStep
+	}
+
+	step, exists := p.Step.withLog(stepIDs, log)
+	if !exists {
+		return ErrUnknownStep
+	}
+
+	// Update the pipeline in place since the same pointer may be associated to several targets
+	pipeline := *p
+	pipeline.Step = step
+	*p = pipeline
 
 	return nil
 }
 
 // Store commit in cache. If a commit with the same SHA exists, merge
 // both commits.
-func (c *Cache) SaveCommit(ref string, commit Commit) {
+func (c *Cache) SaveCommit(target Target, commit Commit) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if previousCommit, exists := c.commitsByRef[ref]; exists {
-		if previousCommit.Sha != commit.Sha {
-			delete(c.pipelineByRef, ref)
-		}
+	previousCommit, exists := c.commitsByRef[target]
+	if exists && previousCommit.Sha != commit.Sha {
+		// The reference now points to another commit (e.g. after a push to a branch or a pull
+		// request) so pipelines of the previous commit are no longer relevant
+		delete(c.pipelineByRef, target)
+		exists = false
+	}
 
+	if exists {
 		previousBranches := make(map[string]struct{})
 		for _, b := range previousCommit.Branches {
 			previousBranches[b] = struct{}{}
@@ -494,16 +938,16 @@ func (c *Cache) SaveCommit(ref string, commit Commit) {
 				previousCommit.Tags = append(previousCommit.Tags, t)
 			}
 		}
-		c.commitsByRef[ref] = previousCommit
+		c.commitsByRef[target] = previousCommit
 	} else {
-		c.commitsByRef[ref] = commit
+		c.commitsByRef[target] = commit
 	}
 }
 
-func (c Cache) Commit(ref string) (Commit, bool) {
+func (c Cache) Commit(target Target) (Commit, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	commit, exists := c.commitsByRef[ref]
+	commit, exists := c.commitsByRef[target]
 	return commit, exists
 }
 
@@ -518,23 +962,32 @@ func (c Cache) Pipelines() []Pipeline {
 	return pipelines
 }
 
-func (c Cache) PipelinesByRef(ref string) []Pipeline {
+func (c Cache) PipelinesByRef(target Target) []Pipeline {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	pipelines := make([]Pipeline, 0, len(c.pipelineByRef[ref]))
-	for _, p := range c.pipelineByRef[ref] {
+	pipelines := make([]Pipeline, 0, len(c.pipelineByRef[target]))
+	for _, p := range c.pipelineByRef[target] {
 		pipelines = append(pipelines, *p)
 	}
 
 	return pipelines
 }
 
+// Initial interval between two requests for the state of a pipeline
+var pipelinePollingInterval = 10 * time.Second
+
+// Number of additional requests for the state of an inactive pipeline that is expected to change
+// shortly, for example after a restart request, before concluding that it is not going to change
+const wakeUpPolls = 5
+
 // Poll provider at increasing interval for information about the CI pipeline identified by the URL
 // u. A message is sent on the channel 'updates' each time the cache is updated with new information
-// for this specific pipeline.
-func (c *Cache) monitorPipeline(ctx context.Context, p CIProvider, u string, ref string, updates chan<- time.Time) error {
+// for this specific pipeline. The monitoring stops once the pipeline is not active, unless
+// 'expectChange' is true in which case the pipeline is polled again until it changes, at most
+// wakeUpPolls more times.
+func (c *Cache) monitorPipeline(ctx context.Context, p CIProvider, u string, target Target, updates chan<- time.Time, expectChange bool) error {
 	b := backoff.ExponentialBackOff{
-		InitialInterval:     10 * time.Second,
+		InitialInterval:     pipelinePollingInterval,
 		RandomizationFactor: backoff.DefaultRandomizationFactor,
 		Multiplier:          backoff.DefaultMultiplier,
 		MaxInterval:         2 * time.Minute,
@@ -543,10 +996,21 @@ func (c *Cache) monitorPipeline(ctx context.Context, p CIProvider, u string, ref
 	}
 	b.Reset()
 
+	// Number of additional requests left before giving up on a change of the pipeline
+	polls := 0
+	if expectChange {
+		polls = wakeUpPolls
+	}
+	var key PipelineKey
+	var wakeup <-chan struct{}
 	for waitTime := time.Duration(0); waitTime != backoff.Stop; waitTime = b.NextBackOff() {
 		select {
 		case <-time.After(waitTime):
 			// Do nothing
+		case <-wakeup:
+			// The pipeline is expected to change shortly so go back to frequent updates
+			b.Reset()
+			polls = wakeUpPolls
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -555,45 +1019,153 @@ func (c *Cache) monitorPipeline(ctx context.Context, p CIProvider, u string, ref
 		if err != nil {
 			return err
 		}
-		pipeline.providerID = p.ID()
-		pipeline.providerHost = p.Host()
+		pipeline.SetProvider(p.ID(), p.Host())
+		if wakeup == nil {
+			key = pipeline.Key()
+			wakeup = c.startMonitor(key)
+		}
 
-		switch err := c.SavePipeline(ref, pipeline); err {
+		previous, exists := c.Pipeline(pipeline.Key())
+		switch err := c.SavePipeline(target, pipeline); err {
 		case nil:
+			if err := c.storePipeline(u, target, pipeline); err != nil {
+				return err
+			}
 			go func() {
 				select {
 				case updates <- time.Now():
 				case <-ctx.Done():
 				}
 			}()
+			if exists && c.transitions != nil {
+				// Transitions are sent before the next poll so that they are received in order
+				for _, transition := range stateTransitions(target, previous, pipeline) {
+					select {
+					case c.transitions <- transition:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
 			// If SavePipeline() does not return an error then the build object we just saved
 			// differs from the previous one. This most likely means the pipeline is
 			// currently running so reset the backoff object.
 			b.Reset()
+			polls = 0
 		case ErrObsoleteBuild:
 			// This error means that the build object we wanted to save was last updated by
 			// the CI provider at the same time or before the one already saved in cache with the
 			// same ID, so cache.SavePipeline rejected or request to store our build.
 			// It's OK. In particular, since builds returned by BuildFromURL never contain
 			// logs, it prevents us from overwriting a build that may have logs (these would have
-			// been committed to the cache by a call to cache.SaveJob() after the user asks to
+			// been committed to the cache by a call to cache.SaveLog() after the user asks to
 			// view the logs of a job) by a build with no log.
 		default:
 			return err
 		}
 
 		if !pipeline.State.IsActive() {
-			break
+			// Providers may take some time to process requests such as restarting a pipeline
+			if polls == 0 {
+				break
+			}
+			polls--
 		}
 	}
 
+	// A finished pipeline may still change if one of its steps is started manually
+	c.stopMonitor(key, func() {
+		// There is no one left to report errors to since the caller of this function will have
+		// returned by then, so the monitoring is on a best effort basis
+		_ = c.monitorPipeline(ctx, p, u, target, updates, true)
+	})
+
 	return nil
 }
 
+// Register the monitoring of the pipeline 'key' and return the channel waking it up
+func (c *Cache) startMonitor(key PipelineKey) <-chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m, exists := c.monitors[key]
+	if !exists {
+		m = &pipelineMonitor{
+			wakeup: make(chan struct{}, 1),
+		}
+		c.monitors[key] = m
+	}
+	m.resume = nil
+
+	return m.wakeup
+}
+
+// Record that the monitoring of the pipeline 'key' is over and that 'resume' restarts it
+func (c *Cache) stopMonitor(key PipelineKey, resume func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m, exists := c.monitors[key]
+	if !exists {
+		m = &pipelineMonitor{
+			wakeup: make(chan struct{}, 1),
+		}
+		c.monitors[key] = m
+	}
+	m.resume = resume
+}
+
+// Ask for an update of the pipeline 'key' right away, either by waking up its monitoring or by
+// resuming it if it is over
+func (c *Cache) wakeUpMonitor(key PipelineKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m, exists := c.monitors[key]
+	switch {
+	case !exists:
+		// The pipeline is not monitored
+	case m.resume != nil:
+		go m.resume()
+		m.resume = nil
+	default:
+		select {
+		case m.wakeup <- struct{}{}:
+		default:
+			// A wake up is already pending
+		}
+	}
+}
+
 // Ask all providers to monitor the CI pipeline identified by the URL u. A message is sent on the
 // channel 'updates' each time the cache is updated with new information for this specific pipeline.
 // If no provider is able to handle the specified URL, ErrUnknownPipelineURL is returned.
-func (c *Cache) broadcastMonitorPipeline(ctx context.Context, u string, ref string, updates chan<- time.Time) error {
+func (c *Cache) broadcastMonitorPipeline(ctx context.Context, u string, target Target, updates chan<- time.Time) error {
+	// Finished pipelines loaded from store won't change anymore so there is no need to ask
+	// providers about them
+	if p, exists := c.storedPipeline(u); exists {
+		c.stopMonitor(p.Key(), func() {
+			_ = c.broadcastMonitorPipeline(ctx, u, target, updates)
+		})
+		switch err := c.SavePipeline(target, p); err {
+		case nil:
+			if err := c.storePipeline(u, target, p); err != nil {
+				return err
+			}
+			go func() {
+				select {
+				case updates <- time.Now():
+				case <-ctx.Done():
+				}
+			}()
+		case ErrObsoleteBuild:
+			// The pipeline is already associated to target
+		default:
+			return err
+		}
+		return nil
+	}
+
 	wg := sync.WaitGroup{}
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
@@ -605,7 +1177,7 @@ func (c *Cache) broadcastMonitorPipeline(ctx context.Context, u string, ref stri
 			// meaning these providers can handle the URL they've been given. These calls
 			// will run longer or possibly never return unless their context is canceled or
 			// they encounter an error.
-			err := c.monitorPipeline(ctx, p, u, ref, updates)
+			err := c.monitorPipeline(ctx, p, u, target, updates, false)
 			if err != nil {
 				if err != ErrUnknownPipelineURL && err != context.Canceled {
 					err = fmt.Errorf("provider %s: monitorPipeline failed with %v (%s)", p.ID(), err, u)
@@ -643,12 +1215,21 @@ func (c *Cache) broadcastMonitorPipeline(ctx context.Context, u string, ref stri
 
 // Ask all providers to monitor the statuses of 'ref'. The URL of each status is written on the
 // channel urlc once. If no provider is able to handle the specified URL, ErrUnknownRepositoryURL
-// is returned.
-func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref string, commitc chan<- Commit) error {
+// is returned. If 'once' is true, this function returns nil once every provider able to handle
+// the URL has found statuses.
+func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref string, commitc chan<- Commit, once bool) error {
 	originURL, commit, err := GitOriginURL(repo, ref)
 	var repositoryURL string
 	switch err {
 	case nil:
+		// Pipeline URLs may be stored locally in git notes, in which case no SourceProvider
+		// is needed to find them
+		urls, err := GitNoteURLs(repo, commit.Sha)
+		if err != nil {
+			return err
+		}
+		sort.Strings(urls)
+		commit.Statuses = urls
 		select {
 		case commitc <- commit:
 		case <-ctx.Done():
@@ -667,6 +1248,11 @@ func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref
 		return err
 	}
 
+	// Without SourceProvider, the local repository is the only source of information
+	if len(c.sourceProviders) == 0 {
+		return err
+	}
+
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
 	wg := sync.WaitGroup{}
@@ -674,7 +1260,7 @@ func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref
 		wg.Add(1)
 		go func(p SourceProvider) {
 			defer wg.Done()
-			errc <- monitorRefStatuses(ctx, p, repositoryURL, ref, commitc)
+			errc <- monitorRefStatuses(ctx, p, repositoryURL, ref, commitc, once)
 		}(p)
 	}
 
@@ -685,11 +1271,15 @@ func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref
 
 	var n int
 	var canceled = false
+	var found = false
 	for e := range errc {
 		if !canceled {
 			// ErrUnknownRepositoryURL and ErrUnknownGitReference are returned if
 			// all providers fail with one of these errors
 			switch e {
+			case nil:
+				// The provider found the statuses of the commit
+				found = true
 			case ErrUnknownRepositoryURL:
 				n++
 				if err == nil {
@@ -715,17 +1305,37 @@ func (c *Cache) broadcastMonitorRefStatus(ctx context.Context, repo string, ref
 		}
 	}
 
+	if found && !canceled {
+		return nil
+	}
+
 	return err
 }
 
-// Monitor CI pipelines associated to the git reference 'ref'. Every time the cache is
-// updated with new data, a message is sent on the 'updates' channel.
+// Monitor CI pipelines associated to the git reference 'target.Ref' of the repository
+// 'target.Repository'. Every time the cache is updated with new data, a message is sent on the
+// 'updates' channel. This function may be called concurrently for different targets.
 // This function may return ErrUnknownRepositoryURL if none of the source providers is
-// able to handle 'repositoryURL'.
-func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURL string, ref string, updates chan<- time.Time) error {
+// able to handle 'target.Repository'.
+func (c *Cache) MonitorPipelines(ctx context.Context, target Target, updates chan<- time.Time) error {
+	return c.monitorPipelines(ctx, target, updates, false)
+}
+
+// Monitor CI pipelines associated to 'target' like MonitorPipelines, but return nil once every
+// source provider able to handle 'target.Repository' has found statuses for 'target.Ref' and
+// all the pipelines referenced by these statuses are finished.
+func (c *Cache) WaitPipelines(ctx context.Context, target Target, updates chan<- time.Time) error {
+	return c.monitorPipelines(ctx, target, updates, true)
+}
+
+func (c *Cache) monitorPipelines(ctx context.Context, target Target, updates chan<- time.Time, once bool) error {
 	commitc := make(chan Commit)
 	errc := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Context of the monitoring of the pipelines of the commit currently referenced by 'target'
+	commitCtx, commitCancel := context.WithCancel(ctx)
+	defer func() { commitCancel() }()
 	wg := sync.WaitGroup{}
 
 	wg.Add(1)
@@ -734,16 +1344,31 @@ func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURL string, ref
 		defer close(commitc)
 		// This gives us a stream of commits with a 'Statuses' attribute that may contain
 		// URLs refering to CI pipelines
-		errc <- c.broadcastMonitorRefStatus(ctx, repositoryURL, ref, commitc)
+		errc <- c.broadcastMonitorRefStatus(ctx, target.Repository, target.Ref, commitc, once)
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		urls := make(map[string]struct{})
+		sha := ""
 		// Ask for monitoring of each URL
 		for commit := range commitc {
-			c.SaveCommit(ref, commit)
+			if commit.Sha != sha {
+				if sha != "" {
+					// The reference now points to another commit, typically after a force-push
+					// to a pull request. Stop monitoring the pipelines of the previous commit.
+					commitCancel()
+					commitCtx, commitCancel = context.WithCancel(ctx)
+					urls = make(map[string]struct{})
+				}
+				sha = commit.Sha
+			}
+			c.SaveCommit(target, commit)
+			if err := c.storeCommit(target); err != nil {
+				errc <- err
+				return
+			}
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -757,17 +1382,21 @@ func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURL string, ref
 			for _, u := range commit.Statuses {
 				if _, exists := urls[u]; !exists {
 					wg.Add(1)
-					go func(u string) {
+					go func(commitCtx context.Context, u string) {
 						defer wg.Done()
-						err := c.broadcastMonitorPipeline(ctx, u, ref, updates)
+						err := c.broadcastMonitorPipeline(commitCtx, u, target, updates)
 						// Ignore ErrUnknownPipelineURL. This error means that we don't integrate
 						// with the application that created that particular URL. No need to report
 						// this up the chain, though it's nice to know our request couldn't be handled.
-						if err != ErrUnknownPipelineURL {
-							errc <- err
+						if err == ErrUnknownPipelineURL {
+							return
+						}
+						// Cancellation of the monitoring of a previous commit is not an error
+						if err == context.Canceled && ctx.Err() == nil {
 							return
 						}
-					}(u)
+						errc <- err
+					}(commitCtx, u)
 					urls[u] = struct{}{}
 				}
 			}
@@ -790,6 +1419,57 @@ func (c *Cache) MonitorPipelines(ctx context.Context, repositoryURL string, ref
 	return err
 }
 
+// Monitor the CI pipelines identified by 'urls' and associate them to 'target'. Unlike
+// MonitorPipelines, no SourceProvider is involved. The commit referenced by 'target.Ref' is
+// looked up in the local repository 'target.Repository' if there is one. Every time the cache
+// is updated with new data, a message is sent on the 'updates' channel. This function returns an
+// error if none of the CI providers is able to handle one of the URLs.
+func (c *Cache) MonitorPipelineURLs(ctx context.Context, target Target, urls []string, updates chan<- time.Time) error {
+	errc := make(chan error)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg := sync.WaitGroup{}
+
+	if _, commit, err := GitOriginURL(target.Repository, target.Ref); err == nil {
+		c.SaveCommit(target, commit)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case updates <- time.Now():
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			err := c.broadcastMonitorPipeline(ctx, u, target, updates)
+			if err == ErrUnknownPipelineURL {
+				err = fmt.Errorf("no CI provider is able to handle pipeline URL %q", u)
+			}
+			errc <- err
+		}(u)
+	}
+
+	go func() {
+		wg.Wait()
+		close(errc)
+	}()
+
+	var err error
+	for e := range errc {
+		if e != nil && err == nil {
+			cancel()
+			err = e
+		}
+	}
+
+	return err
+}
+
 func (c *Cache) Pipeline(key PipelineKey) (Pipeline, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -828,16 +1508,16 @@ func (c *Cache) Step(key PipelineKey, stepIDs []string) (Step, bool) {
 	return step, true
 }
 
-func (c *Cache) Log(ctx context.Context, key taskKey) (string, error) {
-	var err error
+// Return the key of the pipeline of the step identified by 'key' and the path from the pipeline
+// step to the step
+func (k taskKey) pipelineKeyAndPath() (PipelineKey, []string) {
 	pKey := PipelineKey{
-		ProviderHost: key.providerHost,
-		ID:           key.stepIDs[0].String,
+		ProviderHost: k.providerHost,
+		ID:           k.stepIDs[0].String,
 	}
 
-	// TODO Simplify all this
 	stepIDs := make([]string, 0)
-	for _, ID := range key.stepIDs[1:] {
+	for _, ID := range k.stepIDs[1:] {
 		if ID.Valid {
 			stepIDs = append(stepIDs, ID.String)
 		} else {
@@ -845,13 +1525,32 @@ func (c *Cache) Log(ctx context.Context, key taskKey) (string, error) {
 		}
 	}
 
+	return pKey, stepIDs
+}
+
+func (c *Cache) Log(ctx context.Context, key taskKey) (string, error) {
+	var err error
+	pKey, stepIDs := key.pipelineKeyAndPath()
+
 	step, exists := c.Step(pKey, stepIDs)
 	if !exists {
 		return "", fmt.Errorf("no matching step for %v %v", key, key.stepIDs)
 	}
 
 	log := step.Log.Content.String
-	if !step.Log.Content.Valid {
+	stored := false
+	if !step.Log.Content.Valid && c.store != nil && !step.State.IsActive() {
+		if log, stored, err = c.store.log(pKey, stepIDs); err != nil {
+			return "", err
+		}
+		if stored {
+			if err := c.SaveLog(pKey, stepIDs, log); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if !step.Log.Content.Valid && !stored {
 		pipeline, exists := c.Pipeline(pKey)
 		if !exists {
 
@@ -866,11 +1565,17 @@ func (c *Cache) Log(ctx context.Context, key taskKey) (string, error) {
 			return "", err
 		}
 
-		/*if !step.State.IsActive() {
-			if err = c.SaveStep(pKey, stepIDs,accountID, buildID, stageID, job); err != nil {
-				return err
+		// The log of an active step is incomplete so it must be fetched again next time
+		if !step.State.IsActive() {
+			if err := c.SaveLog(pKey, stepIDs, log); err != nil {
+				return "", err
 			}
-		}*/
+			if c.store != nil {
+				if err := c.store.saveLog(pKey, stepIDs, log); err != nil {
+					return "", err
+				}
+			}
+		}
 	}
 
 	if !strings.HasSuffix(log, "\n") {
@@ -879,3 +1584,66 @@ func (c *Cache) Log(ctx context.Context, key taskKey) (string, error) {
 
 	return log, err
 }
+
+// Interval between two requests for new content of the log of an active step
+const logPollingInterval = 5 * time.Second
+
+// Write the log of the step identified by 'key' to w, then keep writing new content as it is
+// produced until the step is no longer active or ctx is canceled. The state of the step is the
+// one stored in cache so the monitoring of the pipeline of the step must be running.
+func (c *Cache) StreamLog(ctx context.Context, key taskKey, w io.Writer) error {
+	pKey, stepIDs := key.pipelineKeyAndPath()
+
+	pipeline, exists := c.Pipeline(pKey)
+	if !exists {
+		return ErrUnknownStep
+	}
+	provider, exists := c.ciProvidersByID[pipeline.providerID]
+	if !exists {
+		return fmt.Errorf("no matching provider found in cache for account ID %q", pipeline.providerID)
+	}
+	streamer, isStreamer := provider.(LogStreamer)
+
+	offset := 0
+	for {
+		step, exists := c.Step(pKey, stepIDs)
+		if !exists {
+			return ErrUnknownStep
+		}
+		// The state must be read before requesting the log since the log is only known
+		// to be complete if the step was already finished at the time of the request
+		active := step.State.IsActive()
+
+		var err error
+		switch {
+		case !active && offset == 0:
+			// Let Log() make use of the logs stored in cache
+			var log string
+			if log, err = c.Log(ctx, key); err == nil {
+				_, err = io.WriteString(w, log)
+			}
+		case isStreamer:
+			offset, err = streamer.StreamLog(ctx, step, offset, w)
+		default:
+			var log string
+			if log, err = provider.Log(ctx, step); err == nil && len(log) > offset {
+				_, err = io.WriteString(w, log[offset:])
+				offset = len(log)
+			}
+		}
+		// The log of an active step may not be available yet
+		if err != nil && !(err == ErrNoLogHere && active) {
+			return err
+		}
+
+		if !active {
+			return nil
+		}
+
+		select {
+		case <-time.After(logPollingInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
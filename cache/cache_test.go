@@ -1,12 +1,18 @@
 package cache
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/utils"
 )
 
 func TestAggregateStatuses(t *testing.T) {
@@ -91,7 +97,7 @@ func TestCache_Save(t *testing.T) {
 				State: Failed,
 			},
 		}
-		if err := c.SavePipeline("", p); err != nil {
+		if err := c.SavePipeline(Target{}, p); err != nil {
 			t.Fatal(err)
 		}
 		savedPipeline, exists := c.Pipeline(p.Key())
@@ -122,10 +128,10 @@ func TestCache_Save(t *testing.T) {
 	t.Run("existing build must be overwritten if it's older than the current build", func(t *testing.T) {
 		c := NewCache(nil, nil)
 
-		if err := c.SavePipeline("", oldPipeline); err != nil {
+		if err := c.SavePipeline(Target{}, oldPipeline); err != nil {
 			t.Fatal(err)
 		}
-		if err := c.SavePipeline("", newPipeline); err != nil {
+		if err := c.SavePipeline(Target{}, newPipeline); err != nil {
 			t.Fatal(err)
 		}
 		savedPipeline, exists := c.Pipeline(oldPipeline.Key())
@@ -141,10 +147,10 @@ func TestCache_Save(t *testing.T) {
 	t.Run("cache.SavePipeline must return ErrObsoleteBuild if the build to save is older than the one in cache", func(t *testing.T) {
 		c := NewCache(nil, nil)
 
-		if err := c.SavePipeline("", newPipeline); err != nil {
+		if err := c.SavePipeline(Target{}, newPipeline); err != nil {
 			t.Fatal(err)
 		}
-		if err := c.SavePipeline("", oldPipeline); err != ErrObsoleteBuild {
+		if err := c.SavePipeline(Target{}, oldPipeline); err != ErrObsoleteBuild {
 			t.Fatalf("expected %v but got %v", ErrObsoleteBuild, err)
 		}
 	})
@@ -160,7 +166,7 @@ func TestCache_Builds(t *testing.T) {
 				ID: id,
 			},
 		}
-		if err := c.SavePipeline("", p); err != nil {
+		if err := c.SavePipeline(Target{}, p); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -177,6 +183,362 @@ func TestCache_Builds(t *testing.T) {
 	}
 }
 
+func TestGitNoteURLs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	commands := [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=citop", "-c", "user.email=citop@example.com", "commit", "-q", "--allow-empty", "-m", "first"},
+		{"-c", "user.name=citop", "-c", "user.email=citop@example.com", "commit", "-q", "--allow-empty", "-m", "second"},
+		{"-c", "user.name=citop", "-c", "user.email=citop@example.com", "notes", "--ref=ci", "add", "-m",
+			"https://circleci.com/gh/nbedos/citop/36\n\nhttps://travis-ci.org/nbedos/citop/builds/615358563\n", "HEAD"},
+	}
+	for _, args := range commands {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if bs, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v (%s)", strings.Join(args, " "), err, string(bs))
+		}
+	}
+
+	t.Run("commit with note", func(t *testing.T) {
+		urls, err := GitNoteURLs(dir, "HEAD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{
+			"https://circleci.com/gh/nbedos/citop/36",
+			"https://travis-ci.org/nbedos/citop/builds/615358563",
+		}
+		if diff := cmp.Diff(expected, urls); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("commit without note", func(t *testing.T) {
+		urls, err := GitNoteURLs(dir, "HEAD~1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(urls) > 0 {
+			t.Fatalf("expected no URL but got %v", urls)
+		}
+	})
+}
+
+func TestGitCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	commands := [][]string{{"init", "-q"}}
+	for _, message := range []string{"first", "second", "third"} {
+		commands = append(commands, []string{"-c", "user.name=citop", "-c", "user.email=citop@example.com", "commit", "-q", "--allow-empty", "-m", message})
+	}
+	for _, args := range commands {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		if bs, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v (%s)", strings.Join(args, " "), err, string(bs))
+		}
+	}
+
+	commits, err := GitCommits(dir, "HEAD", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		messages = append(messages, strings.TrimSpace(commit.Message))
+	}
+	if diff := cmp.Diff([]string{"third", "second"}, messages); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("unknown reference", func(t *testing.T) {
+		if _, err := GitCommits(dir, "does-not-exist", 2); err != ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", ErrUnknownGitReference, err)
+		}
+	})
+
+	t.Run("not a local repository", func(t *testing.T) {
+		if _, err := GitCommits("github.com/nbedos/citop", "master", 2); err != ErrUnknownRepositoryURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownRepositoryURL, err)
+		}
+	})
+}
+
+func TestCache_SaveLog(t *testing.T) {
+	target := Target{Repository: "repo", Ref: "master"}
+	pipeline := Pipeline{
+		Number: "1",
+		Step: Step{
+			ID:    "1",
+			State: Running,
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Passed,
+					Log:   Log{Key: "log2"},
+				},
+				{
+					ID:    "3",
+					State: Running,
+					Log:   Log{Key: "log3"},
+				},
+			},
+		},
+	}
+
+	t.Run("log of an existing step", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		if err := c.SavePipeline(target, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		previous, _ := c.Pipeline(pipeline.Key())
+
+		if err := c.SaveLog(pipeline.Key(), []string{"2"}, "log\n"); err != nil {
+			t.Fatal(err)
+		}
+
+		step, exists := c.Step(pipeline.Key(), []string{"2"})
+		if !exists {
+			t.Fatal("step not found")
+		}
+		expected := utils.NullString{Valid: true, String: "log\n"}
+		if diff := cmp.Diff(expected, step.Log.Content); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		// Copies of the pipeline returned earlier must not be modified
+		if previous.Children[0].Log.Content.Valid {
+			t.Fatal("copy of pipeline was modified")
+		}
+	})
+
+	t.Run("log of a non existing step", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		if err := c.SavePipeline(target, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SaveLog(pipeline.Key(), []string{"4"}, "log\n"); err != ErrUnknownStep {
+			t.Fatalf("expected %v but got %v", ErrUnknownStep, err)
+		}
+	})
+
+	t.Run("logs of finished steps survive updates of the pipeline", func(t *testing.T) {
+		c := NewCache(nil, nil)
+		if err := c.SavePipeline(target, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		for _, ID := range []string{"2", "3"} {
+			if err := c.SaveLog(pipeline.Key(), []string{ID}, "log\n"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := c.SavePipeline(target, pipeline); err != nil {
+			t.Fatal(err)
+		}
+
+		step, _ := c.Step(pipeline.Key(), []string{"2"})
+		if !step.Log.Content.Valid {
+			t.Fatal("log of finished step was lost")
+		}
+		step, _ = c.Step(pipeline.Key(), []string{"3"})
+		if step.Log.Content.Valid {
+			t.Fatal("log of running step was kept")
+		}
+	})
+}
+
+type logProvider struct {
+	log string
+}
+
+func (p logProvider) ID() string   { return "provider" }
+func (p logProvider) Host() string { return "example.com" }
+func (p logProvider) Name() string { return "provider" }
+func (p logProvider) Log(ctx context.Context, step Step) (string, error) {
+	return p.log, nil
+}
+func (p logProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	return Pipeline{}, ErrUnknownPipelineURL
+}
+
+type statusProvider struct {
+	statuses []string
+	calls    *int32
+}
+
+func (p statusProvider) ID() string { return "status" }
+func (p statusProvider) RefStatuses(ctx context.Context, url string, ref string, sha string) ([]string, error) {
+	atomic.AddInt32(p.calls, 1)
+	return p.statuses, nil
+}
+func (p statusProvider) Commit(ctx context.Context, repo string, sha string) (Commit, error) {
+	return Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"}, nil
+}
+
+type finishedPipelineProvider struct {
+	logProvider
+}
+
+func (p finishedPipelineProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	if u != "https://example.com/pipelines/1" {
+		return Pipeline{}, ErrUnknownPipelineURL
+	}
+	return Pipeline{
+		Step: Step{
+			ID:    "1",
+			State: Passed,
+		},
+	}, nil
+}
+
+func TestCache_WaitPipelines(t *testing.T) {
+	target := Target{Repository: "https://example.com/owner/repo", Ref: "master"}
+	updates := make(chan time.Time)
+	go func() {
+		for range updates {
+		}
+	}()
+
+	t.Run("finished pipelines", func(t *testing.T) {
+		provider := statusProvider{
+			statuses: []string{"https://example.com/pipelines/1"},
+			calls:    new(int32),
+		}
+		c := NewCache([]CIProvider{finishedPipelineProvider{}}, []SourceProvider{provider})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := c.WaitPipelines(ctx, target, updates); err != nil {
+			t.Fatal(err)
+		}
+		if pipelines := c.PipelinesByRef(target); len(pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+		}
+		if calls := atomic.LoadInt32(provider.calls); calls != 1 {
+			t.Fatalf("expected statuses to be fetched once but got %d calls", calls)
+		}
+	})
+
+	t.Run("no status", func(t *testing.T) {
+		provider := statusProvider{calls: new(int32)}
+		c := NewCache([]CIProvider{finishedPipelineProvider{}}, []SourceProvider{provider})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		if err := c.WaitPipelines(ctx, target, updates); err != context.DeadlineExceeded {
+			t.Fatalf("expected %v but got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestCache_StreamLog(t *testing.T) {
+	provider := logProvider{log: "log\n"}
+	c := NewCache([]CIProvider{provider}, nil)
+	pipeline := Pipeline{
+		providerID:   provider.ID(),
+		providerHost: provider.Host(),
+		Step: Step{
+			ID:    "1",
+			State: Failed,
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Failed,
+				},
+			},
+		},
+	}
+	if err := c.SavePipeline(Target{}, pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("log of a finished step", func(t *testing.T) {
+		key := taskKey{
+			providerHost: provider.Host(),
+			stepIDs: StepPath{
+				{Valid: true, String: "1"},
+				{Valid: true, String: "2"},
+			},
+		}
+		buf := strings.Builder{}
+		if err := c.StreamLog(context.Background(), key, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(provider.log, buf.String()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("non existing step", func(t *testing.T) {
+		key := taskKey{
+			providerHost: provider.Host(),
+			stepIDs: StepPath{
+				{Valid: true, String: "1"},
+				{Valid: true, String: "3"},
+			},
+		}
+		if err := c.StreamLog(context.Background(), key, &strings.Builder{}); err != ErrUnknownStep {
+			t.Fatalf("expected %v but got %v", ErrUnknownStep, err)
+		}
+	})
+}
+
+func TestPullRequestNumber(t *testing.T) {
+	testCases := []struct {
+		ref    string
+		number int
+		ok     bool
+	}{
+		{ref: "#1234", number: 1234, ok: true},
+		{ref: "pr:1234", number: 1234, ok: true},
+		{ref: "master"},
+		{ref: "#"},
+		{ref: "pr:abc"},
+		{ref: "#-1"},
+		{ref: "1234"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.ref, func(t *testing.T) {
+			number, ok := PullRequestNumber(testCase.ref)
+			if number != testCase.number || ok != testCase.ok {
+				t.Fatalf("expected (%d, %v) but got (%d, %v)", testCase.number, testCase.ok, number, ok)
+			}
+		})
+	}
+}
+
+func TestCache_SaveCommit(t *testing.T) {
+	c := NewCache(nil, nil)
+	target := Target{Repository: "repo", Ref: "#1234"}
+	c.SaveCommit(target, Commit{Sha: "a", Branches: []string{"feature"}})
+	if err := c.SavePipeline(target, Pipeline{Number: "1", GitReference: GitReference{SHA: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A force-push changes the commit referenced by the pull request
+	c.SaveCommit(target, Commit{Sha: "b"})
+
+	commit, exists := c.Commit(target)
+	if !exists {
+		t.Fatal("commit not found")
+	}
+	if diff := cmp.Diff(Commit{Sha: "b"}, commit); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if pipelines := c.PipelinesByRef(target); len(pipelines) > 0 {
+		t.Fatalf("expected no pipeline but got %v", pipelines)
+	}
+}
+
 /*
 type mockProvider struct {
 	id     string
@@ -455,7 +817,7 @@ func TestCache_BuildsByRef(t *testing.T) {
 		},
 	}
 	for _, p := range pipelines {
-		if err := c.SavePipeline("", p); err != nil {
+		if err := c.SavePipeline(Target{}, p); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -470,8 +832,8 @@ func TestCache_BuildsByRef(t *testing.T) {
 	}
 
 	// Build with ID 1 must have moved from ref1 to ref2
-	if len(c.PipelinesByRef("ref1")) != 0 {
-		t.Fatalf("expected empty list but got %+v", c.PipelinesByRef("ref1"))
+	if len(c.PipelinesByRef(Target{Ref: "ref1"})) != 0 {
+		t.Fatalf("expected empty list but got %+v", c.PipelinesByRef(Target{Ref: "ref1"}))
 	}
 }
 
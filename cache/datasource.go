@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"io"
 	"strings"
 	"time"
 
@@ -18,11 +19,42 @@ type HierarchicalTabularSourceRow interface {
 	utils.TreeNode
 }
 
+// Row whose cells can be used to sort rows
+type SortableRow interface {
+	// Return the value used to sort rows by 'column' and whether rows can be sorted by this
+	// column. Rows are sorted in ascending order of their values.
+	SortKey(column string) (int64, bool)
+}
+
+// Row whose cells can be used to filter rows
+type FilterableRow interface {
+	// Return the content of the cells of the row as returned by Tabular, except for the tree
+	// prefix which is left out of the NAME column
+	FilterValues(loc *time.Location) map[string]string
+}
+
+// Row that may be started by HierarchicalTabularDataSource.Play
+type PlayableRow interface {
+	// Return whether the row is a manual step waiting to be started
+	Playable() bool
+}
+
 type HierarchicalTabularDataSource interface {
 	Rows() []HierarchicalTabularSourceRow
 	Headers() []string
 	Alignment() map[string]text.Alignment
 	Log(ctx context.Context, key interface{}) (string, error)
+	// Write the log of the row identified by key to w as it is produced
+	StreamLog(ctx context.Context, key interface{}, w io.Writer) error
+	// Run again the pipeline or the step of the row identified by key
+	Restart(ctx context.Context, key interface{}) error
+	// Cancel the pipeline or the step of the row identified by key
+	Cancel(ctx context.Context, key interface{}) error
+	// Start new pipelines for the git reference of the row identified by key
+	Trigger(ctx context.Context, key interface{}) error
+	// Start the manual step of the row identified by key with the environment variables
+	// 'variables'
+	Play(ctx context.Context, key interface{}, variables map[string]string) error
 }
 
 func Prefix(row HierarchicalTabularSourceRow, indent string, last bool) {
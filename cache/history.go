@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/google/go-cmp/cmp"
+)
+
+// Return the last 'limit' commits of the history of 'target.Ref'. Source providers implementing
+// HistoryProvider are asked first. If none of them knows the repository, the history is read
+// from the local repository 'target.Repository'.
+func (c *Cache) listCommits(ctx context.Context, target Target, limit int) ([]Commit, error) {
+	repositoryURL := target.Repository
+	switch originURL, _, err := GitOriginURL(target.Repository, target.Ref); err {
+	case nil, ErrUnknownGitReference:
+		repositoryURL = originURL
+	case ErrUnknownRepositoryURL:
+		// target.Repository is already a URL
+	default:
+		return nil, err
+	}
+
+	var err error
+	for _, p := range c.sourceProviders {
+		historyProvider, ok := p.(HistoryProvider)
+		if !ok {
+			continue
+		}
+		ref := target.Ref
+		if number, ok := PullRequestNumber(ref); ok {
+			pullRequestProvider, ok := p.(PullRequestProvider)
+			if !ok {
+				continue
+			}
+			head, e := pullRequestProvider.PullRequestHead(ctx, repositoryURL, number)
+			if e != nil {
+				if e != ErrUnknownRepositoryURL && err != ErrUnknownGitReference {
+					err = e
+				}
+				continue
+			}
+			ref = head
+		}
+
+		commits, e := historyProvider.Commits(ctx, repositoryURL, ref, limit)
+		switch e {
+		case nil:
+			return commits, nil
+		case ErrUnknownRepositoryURL:
+			continue
+		case ErrUnknownGitReference:
+			// The repository was found but the reference was not, which is more specific than
+			// any other error
+			err = e
+		default:
+			if err != ErrUnknownGitReference {
+				err = e
+			}
+		}
+	}
+
+	commits, e := GitCommits(target.Repository, target.Ref, limit)
+	if e == ErrUnknownRepositoryURL && err != nil {
+		return nil, err
+	}
+
+	return commits, e
+}
+
+// Return the targets referring to the recent commits of 'target.Ref', most recent commit first.
+// The history of 'target.Ref' is only known while it is monitored by MonitorHistory.
+func (c Cache) History(target Target) []Target {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	targets := make([]Target, 0, len(c.historyByRef[target]))
+	for _, sha := range c.historyByRef[target] {
+		targets = append(targets, Target{
+			Repository: target.Repository,
+			Ref:        sha,
+		})
+	}
+
+	return targets
+}
+
+func (c *Cache) saveHistory(target Target, commits []Commit) {
+	shas := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		shas = append(shas, commit.Sha)
+		c.SaveCommit(Target{Repository: target.Repository, Ref: commit.Sha}, commit)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.historyByRef[target] = shas
+}
+
+// Monitor CI pipelines of the last 'limit' commits of the git reference 'target.Ref'. The history
+// of the reference is polled at increasing interval so that new commits are picked up. The
+// pipelines of the most recent commit are monitored as done by MonitorPipelines, those of older
+// commits only until they are finished, as done by WaitPipelines. Every time the cache is
+// updated with new data, a message is sent on the 'updates' channel.
+func (c *Cache) MonitorHistory(ctx context.Context, target Target, limit int, updates chan<- time.Time) error {
+	b := backoff.ExponentialBackOff{
+		InitialInterval:     30 * time.Second,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		MaxInterval:         5 * time.Minute,
+		MaxElapsedTime:      0,
+		Clock:               backoff.SystemClock,
+	}
+	b.Reset()
+
+	errc := make(chan error)
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// Cancel the monitoring of each commit currently in the history
+	cancels := make(map[string]context.CancelFunc)
+	var shas []string
+	// Most recent commit of the history, the only one whose pipelines are monitored continuously
+	var head string
+	for waitTime := time.Duration(0); waitTime != backoff.Stop; waitTime = b.NextBackOff() {
+		select {
+		case <-time.After(waitTime):
+			// Do nothing
+		case err := <-errc:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		commits, err := c.listCommits(ctx, target, limit)
+		if err != nil {
+			return err
+		}
+		current := make([]string, 0, len(commits))
+		for _, commit := range commits {
+			current = append(current, commit.Sha)
+		}
+		if diff := cmp.Diff(shas, current); len(diff) == 0 {
+			continue
+		}
+		shas = current
+		c.saveHistory(target, commits)
+		b.Reset()
+
+		stillThere := make(map[string]struct{}, len(shas))
+		for i, sha := range shas {
+			stillThere[sha] = struct{}{}
+			if commitCancel, exists := cancels[sha]; exists {
+				if sha != head || i == 0 {
+					continue
+				}
+				// A new commit was pushed so the previous head is now part of the history
+				commitCancel()
+			}
+			monitor := c.WaitPipelines
+			if i == 0 {
+				monitor = c.MonitorPipelines
+			}
+			var commitCtx context.Context
+			commitCtx, cancels[sha] = context.WithCancel(ctx)
+			wg.Add(1)
+			go func(ctx context.Context, commitTarget Target) {
+				defer wg.Done()
+				err := monitor(ctx, commitTarget, updates)
+				switch err {
+				case nil, context.Canceled, ErrUnknownGitReference, ErrUnknownRepositoryURL:
+					// A commit that no longer belongs to the history, or that is only known to
+					// the local repository, is not an error
+					return
+				}
+				select {
+				case errc <- err:
+				case <-ctx.Done():
+				}
+			}(commitCtx, Target{Repository: target.Repository, Ref: sha})
+		}
+		// Commits that no longer belong to the last 'limit' commits are not monitored anymore
+		for sha, commitCancel := range cancels {
+			if _, exists := stillThere[sha]; !exists {
+				commitCancel()
+				delete(cancels, sha)
+			}
+		}
+		if len(shas) > 0 {
+			head = shas[0]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case updates <- time.Now():
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type historyProvider struct {
+	commits []Commit
+}
+
+func (p historyProvider) ID() string { return "history" }
+func (p historyProvider) RefStatuses(ctx context.Context, url string, ref string, sha string) ([]string, error) {
+	return nil, nil
+}
+func (p historyProvider) Commit(ctx context.Context, repo string, sha string) (Commit, error) {
+	return Commit{}, ErrUnknownGitReference
+}
+func (p historyProvider) Commits(ctx context.Context, repo string, ref string, limit int) ([]Commit, error) {
+	if repo != "https://example.com/owner/repo" {
+		return nil, ErrUnknownRepositoryURL
+	}
+	if ref != "master" {
+		return nil, ErrUnknownGitReference
+	}
+	if limit < len(p.commits) {
+		return p.commits[:limit], nil
+	}
+	return p.commits, nil
+}
+
+func TestCache_listCommits(t *testing.T) {
+	provider := historyProvider{
+		commits: []Commit{{Sha: "3"}, {Sha: "2"}, {Sha: "1"}},
+	}
+	c := NewCache(nil, []SourceProvider{provider})
+
+	t.Run("commits listed by source provider", func(t *testing.T) {
+		target := Target{Repository: "https://example.com/owner/repo", Ref: "master"}
+		commits, err := c.listCommits(context.Background(), target, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(provider.commits[:2], commits); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("unknown reference", func(t *testing.T) {
+		target := Target{Repository: "https://example.com/owner/repo", Ref: "feature"}
+		if _, err := c.listCommits(context.Background(), target, 2); err != ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", ErrUnknownGitReference, err)
+		}
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		target := Target{Repository: "https://example.com/owner/other", Ref: "master"}
+		if _, err := c.listCommits(context.Background(), target, 2); err != ErrUnknownRepositoryURL {
+			t.Fatalf("expected %v but got %v", ErrUnknownRepositoryURL, err)
+		}
+	})
+}
+
+func TestBuildsByHistory_Rows(t *testing.T) {
+	c := NewCache(nil, nil)
+	target := Target{Repository: "repo", Ref: "master"}
+	commits := []Commit{
+		{Sha: "0123456789abcdef", Message: "second\n\nbody"},
+		{Sha: "fedcba9876543210", Message: "first"},
+	}
+	c.saveHistory(target, commits)
+	pipeline := Pipeline{
+		providerHost: "host",
+		Step: Step{
+			ID:        "1",
+			State:     Failed,
+			UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	if err := c.SavePipeline(Target{Repository: "repo", Ref: commits[1].Sha}, pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := c.HistoryOfRef(target).Rows()
+	if len(rows) != len(commits) {
+		t.Fatalf("expected %d rows but got %d", len(commits), len(rows))
+	}
+	expectedStates := []State{Unknown, Failed}
+	for i, row := range rows {
+		if key := row.Key(); key != (Target{Repository: "repo", Ref: commits[i].Sha}) {
+			t.Fatalf("unexpected key %v", key)
+		}
+		if state := row.(*targetRow).state; state != expectedStates[i] {
+			t.Fatalf("expected state %q but got %q", expectedStates[i], state)
+		}
+	}
+
+	values := rows[0].Tabular(time.UTC)
+	if ref := values["REF"].String(); ref != "0123456" {
+		t.Fatalf("expected abbreviated SHA but got %q", ref)
+	}
+	if name := values["NAME"].String(); name != "second" {
+		t.Fatalf("expected first line of commit message but got %q", name)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
@@ -24,22 +27,24 @@ type taskKey struct {
 }
 
 type task struct {
-	key         taskKey
-	ref         GitReference
-	number      string
-	type_       string
-	state       State
-	name        string
-	provider    string
-	prefix      string
-	createdAt   utils.NullTime
-	startedAt   utils.NullTime
-	finishedAt  utils.NullTime
-	updatedAt   utils.NullTime
-	duration    utils.NullDuration
-	children    []*task
-	traversable bool
-	url         utils.NullString
+	key          taskKey
+	ref          GitReference
+	number       string
+	type_        string
+	state        State
+	name         string
+	provider     string
+	author       string
+	allowFailure bool
+	prefix       string
+	createdAt    utils.NullTime
+	startedAt    utils.NullTime
+	finishedAt   utils.NullTime
+	updatedAt    utils.NullTime
+	duration     utils.NullDuration
+	children     []*task
+	traversable  bool
+	url          utils.NullString
 }
 
 func (t task) Diff(other task) string {
@@ -70,18 +75,6 @@ func (t task) Tabular(loc *time.Location) map[string]text.StyledString {
 		return text.NewStyledString(s)
 	}
 
-	state := text.NewStyledString(string(t.state))
-	switch t.state {
-	case Failed, Canceled:
-		state.Add(text.StatusFailed)
-	case Passed:
-		state.Add(text.StatusPassed)
-	case Running:
-		state.Add(text.StatusRunning)
-	case Pending, Skipped, Manual:
-		state.Add(text.StatusSkipped)
-	}
-
 	name := text.NewStyledString(t.prefix)
 	if t.type_ == "P" {
 		name.Append(t.provider, text.Provider)
@@ -97,20 +90,101 @@ func (t task) Tabular(loc *time.Location) map[string]text.StyledString {
 		refClass = text.GitTag
 	}
 
+	allowFailure := ""
+	if t.allowFailure {
+		allowFailure = "yes"
+	}
+
 	return map[string]text.StyledString{
-		"REF":      text.NewStyledString(t.ref.Ref, refClass),
-		"PIPELINE": text.NewStyledString(t.number),
-		"TYPE":     text.NewStyledString(t.type_),
-		"STATE":    state,
-		"NAME":     name,
-		"CREATED":  nullTimeToString(t.createdAt),
-		"STARTED":  nullTimeToString(t.startedAt),
-		"FINISHED": nullTimeToString(t.finishedAt),
-		"UPDATED":  nullTimeToString(t.updatedAt),
-		"DURATION": text.NewStyledString(t.duration.String()),
+		"REF":           text.NewStyledString(t.ref.Ref, refClass),
+		"PIPELINE":      text.NewStyledString(t.number),
+		"TYPE":          text.NewStyledString(t.type_),
+		"STATE":         stateString(t.state),
+		"NAME":          name,
+		"PROVIDER":      text.NewStyledString(t.provider, text.Provider),
+		"AUTHOR":        text.NewStyledString(t.author),
+		"CREATED":       nullTimeToString(t.createdAt),
+		"QUEUED":        text.NewStyledString(utils.NullSub(t.startedAt, t.createdAt).String()),
+		"STARTED":       nullTimeToString(t.startedAt),
+		"FINISHED":      nullTimeToString(t.finishedAt),
+		"UPDATED":       nullTimeToString(t.updatedAt),
+		"DURATION":      text.NewStyledString(t.duration.String()),
+		"ALLOW FAILURE": text.NewStyledString(allowFailure),
 	}
 }
 
+func stateString(s State) text.StyledString {
+	state := text.NewStyledString(string(s))
+	switch s {
+	case Failed, Canceled:
+		state.Add(text.StatusFailed)
+	case Passed:
+		state.Add(text.StatusPassed)
+	case Running:
+		state.Add(text.StatusRunning)
+	case Pending, Skipped, Manual:
+		state.Add(text.StatusSkipped)
+	}
+
+	return state
+}
+
+// Rank of states when sorting rows by state: failures first, successes last
+var stateRank = map[State]int64{
+	Failed:   0,
+	Canceled: 1,
+	Running:  2,
+	Pending:  3,
+	Manual:   4,
+	Skipped:  5,
+	Passed:   6,
+	Unknown:  7,
+}
+
+// Return the value used to sort rows by 'column' among STATE, STARTED and DURATION. Rows that
+// have not started come after the others, rows without duration before the others.
+func sortKey(column string, state State, startedAt utils.NullTime, duration utils.NullDuration) (int64, bool) {
+	switch column {
+	case "STATE":
+		return stateRank[state], true
+	case "STARTED":
+		if !startedAt.Valid {
+			return math.MaxInt64, true
+		}
+		return startedAt.Time.UnixNano(), true
+	case "DURATION":
+		if !duration.Valid {
+			return -1, true
+		}
+		return int64(duration.Duration), true
+	}
+
+	return 0, false
+}
+
+func (t task) SortKey(column string) (int64, bool) {
+	return sortKey(column, t.state, t.startedAt, t.duration)
+}
+
+func (t task) FilterValues(loc *time.Location) map[string]string {
+	return filterValues(t.Tabular(loc), t.prefix)
+}
+
+// Return the content of the cells 'values' without the tree prefix 'prefix' of the NAME column
+func filterValues(values map[string]text.StyledString, prefix string) map[string]string {
+	contents := make(map[string]string, len(values))
+	for column, value := range values {
+		contents[column] = value.String()
+	}
+	contents["NAME"] = strings.TrimPrefix(contents["NAME"], prefix)
+
+	return contents
+}
+
+func (t task) Playable() bool {
+	return t.state == Manual
+}
+
 func (t task) Key() interface{} {
 	return t.key
 }
@@ -132,7 +206,7 @@ func (t *task) SetPrefix(s string) {
 	t.prefix = s
 }
 
-func taskFromPipeline(p Pipeline, providerByID map[string]CIProvider) task {
+func taskFromPipeline(p Pipeline, providerByID map[string]CIProvider, author string) task {
 	key := taskKey{
 		providerHost: p.providerHost,
 		stepIDs:      [maxStepIDs]utils.NullString{},
@@ -151,10 +225,10 @@ func taskFromPipeline(p Pipeline, providerByID map[string]CIProvider) task {
 		number = "#" + number
 	}
 
-	return taskFromStep(p.Step, p.GitReference, key, providerName, number)
+	return taskFromStep(p.Step, p.GitReference, key, providerName, author, number)
 }
 
-func taskFromStep(s Step, ref GitReference, key taskKey, provider string, number string) task {
+func taskFromStep(s Step, ref GitReference, key taskKey, provider string, author string, number string) task {
 	keySet := false
 	for i, ID := range key.stepIDs {
 		if !ID.Valid {
@@ -174,15 +248,17 @@ func taskFromStep(s Step, ref GitReference, key taskKey, provider string, number
 	}
 
 	t := task{
-		key:        key,
-		ref:        ref,
-		number:     number,
-		state:      s.State,
-		name:       s.Name,
-		provider:   provider,
-		createdAt:  s.CreatedAt,
-		startedAt:  s.StartedAt,
-		finishedAt: s.FinishedAt,
+		key:          key,
+		ref:          ref,
+		number:       number,
+		state:        s.State,
+		name:         s.Name,
+		provider:     provider,
+		author:       author,
+		allowFailure: s.AllowFailure,
+		createdAt:    s.CreatedAt,
+		startedAt:    s.StartedAt,
+		finishedAt:   s.FinishedAt,
 		updatedAt: utils.NullTime{
 			Time:  s.UpdatedAt,
 			Valid: true,
@@ -203,52 +279,238 @@ func taskFromStep(s Step, ref GitReference, key taskKey, provider string, number
 	}
 
 	for _, childStep := range s.Children {
-		childTask := taskFromStep(childStep, ref, t.key, provider, number)
+		childTask := taskFromStep(childStep, ref, t.key, provider, author, number)
 		t.children = append(t.children, &childTask)
 	}
 
 	return t
 }
 
+// Top-level row grouping the pipelines of a target
+type targetRow struct {
+	target Target
+	// Commit referenced by target if the row is part of the history of a git reference, nil
+	// otherwise
+	commit      *Commit
+	author      string
+	isTag       bool
+	state       State
+	prefix      string
+	startedAt   utils.NullTime
+	duration    utils.NullDuration
+	children    []*task
+	traversable bool
+}
+
+func (t targetRow) Traversable() bool {
+	return t.traversable
+}
+
+func (t targetRow) Children() []utils.TreeNode {
+	children := make([]utils.TreeNode, len(t.children))
+	for i := range t.children {
+		children[i] = t.children[i]
+	}
+	return children
+}
+
+func (t targetRow) Tabular(loc *time.Location) map[string]text.StyledString {
+	started := text.NewStyledString("-")
+	if t.startedAt.Valid {
+		started = text.NewStyledString(t.startedAt.Time.In(loc).Truncate(time.Second).Format("Jan 2 15:04"))
+	}
+
+	refClass := text.GitBranch
+	if t.isTag {
+		refClass = text.GitTag
+	}
+
+	ref := text.NewStyledString(t.target.Ref, refClass)
+	name := text.NewStyledString(t.prefix)
+	if t.commit != nil {
+		sha := t.commit.Sha
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		ref = text.NewStyledString(sha, text.GitSha)
+		name.Append(strings.SplitN(t.commit.Message, "\n", 2)[0])
+	} else {
+		name.Append(t.target.Repository, text.Provider)
+	}
+
+	return map[string]text.StyledString{
+		"REF":      ref,
+		"STATE":    stateString(t.state),
+		"NAME":     name,
+		"AUTHOR":   text.NewStyledString(t.author),
+		"STARTED":  started,
+		"DURATION": text.NewStyledString(t.duration.String()),
+	}
+}
+
+func (t targetRow) SortKey(column string) (int64, bool) {
+	return sortKey(column, t.state, t.startedAt, t.duration)
+}
+
+func (t targetRow) FilterValues(loc *time.Location) map[string]string {
+	return filterValues(t.Tabular(loc), t.prefix)
+}
+
+func (t targetRow) Key() interface{} {
+	return t.target
+}
+
+func (t targetRow) URL() utils.NullString {
+	return utils.NullString{}
+}
+
+func (t *targetRow) SetTraversable(traversable bool, recursive bool) {
+	t.traversable = traversable
+	if recursive {
+		for _, child := range t.children {
+			child.SetTraversable(traversable, recursive)
+		}
+	}
+}
+
+func (t *targetRow) SetPrefix(s string) {
+	t.prefix = s
+}
+
 type BuildsByCommit struct {
-	cache Cache
-	ref   string
+	cache   Cache
+	targets []Target
+	grouped bool
+}
+
+// Return a data source listing the pipelines of a single target
+func (c Cache) BuildsOfRef(target Target) HierarchicalTabularDataSource {
+	return BuildsByCommit{
+		cache:   c,
+		targets: []Target{target},
+	}
 }
 
-func (c Cache) BuildsOfRef(ref string) HierarchicalTabularDataSource {
+// Return a data source listing the pipelines of each target under a top-level row
+func (c Cache) BuildsOfRefs(targets []Target) HierarchicalTabularDataSource {
 	return BuildsByCommit{
-		cache: c,
-		ref:   ref,
+		cache:   c,
+		targets: targets,
+		grouped: true,
+	}
+}
+
+// Columns of the tables listing pipelines along with their alignment
+var buildsColumns = []struct {
+	name      string
+	alignment text.Alignment
+}{
+	{"REF", text.Left},
+	{"PIPELINE", text.Right},
+	{"TYPE", text.Right},
+	{"STATE", text.Left},
+	{"PROVIDER", text.Left},
+	{"AUTHOR", text.Left},
+	{"CREATED", text.Left},
+	{"QUEUED", text.Right},
+	{"STARTED", text.Left},
+	{"FINISHED", text.Left},
+	{"UPDATED", text.Left},
+	{"DURATION", text.Right},
+	{"ALLOW FAILURE", text.Left},
+	{"NAME", text.Left},
+}
+
+// Return the names of all the columns of the tables listing pipelines
+func BuildsColumns() []string {
+	names := make([]string, 0, len(buildsColumns))
+	for _, column := range buildsColumns {
+		names = append(names, column.name)
 	}
+	return names
 }
 
+// Return the columns shown by default. The git reference is left out if there is only one.
 func (s BuildsByCommit) Headers() []string {
-	return []string{"REF", "PIPELINE", "TYPE", "STATE", "STARTED", "DURATION", "NAME"}
+	headers := []string{"REF", "PIPELINE", "TYPE", "STATE", "STARTED", "DURATION", "NAME"}
+	if !s.grouped {
+		headers = headers[1:]
+	}
+	return headers
 }
 
 func (s BuildsByCommit) Alignment() map[string]text.Alignment {
-	return map[string]text.Alignment{
-		"REF":      text.Left,
-		"PIPELINE": text.Right,
-		"TYPE":     text.Right,
-		"STATE":    text.Left,
-		"CREATED":  text.Left,
-		"STARTED":  text.Left,
-		"UPDATED":  text.Left,
-		"DURATION": text.Right,
-		"NAME":     text.Left,
+	alignment := make(map[string]text.Alignment, len(buildsColumns))
+	for _, column := range buildsColumns {
+		alignment[column.name] = column.alignment
 	}
+	return alignment
 }
 
 func (s BuildsByCommit) Rows() []HierarchicalTabularSourceRow {
 	rows := make([]HierarchicalTabularSourceRow, 0)
-	for _, p := range s.cache.PipelinesByRef(s.ref) {
-		t := taskFromPipeline(p, s.cache.ciProvidersByID)
-		rows = append(rows, &t)
+	for _, target := range s.targets {
+		if s.grouped {
+			rows = append(rows, s.targetRow(target))
+			continue
+		}
+		for _, t := range s.tasks(target) {
+			rows = append(rows, t)
+		}
 	}
 
-	sort.Slice(rows, func(i, j int) bool {
-		ri, rj := rows[i].(*task), rows[j].(*task)
+	return rows
+}
+
+// Return the pipelines of target sorted by ascending creation date
+func (s BuildsByCommit) tasks(target Target) []*task {
+	pipelines := s.cache.PipelinesByRef(target)
+	author := ""
+	if commit, exists := s.cache.Commit(target); exists {
+		author = commit.Author
+	}
+	tasks := make([]*task, 0, len(pipelines))
+	for _, p := range pipelines {
+		t := taskFromPipeline(p, s.cache.ciProvidersByID, author)
+		tasks = append(tasks, &t)
+	}
+	sortTasks(tasks)
+
+	return tasks
+}
+
+// Return the row grouping the pipelines of target
+func (s BuildsByCommit) targetRow(target Target) *targetRow {
+	pipelines := s.cache.PipelinesByRef(target)
+	steps := make([]Step, 0, len(pipelines))
+	for _, p := range pipelines {
+		steps = append(steps, p.Step)
+	}
+	aggregate := Aggregate(steps)
+
+	row := targetRow{
+		target:    target,
+		state:     aggregate.State,
+		startedAt: aggregate.StartedAt,
+		duration:  aggregate.Duration,
+		children:  s.tasks(target),
+	}
+	if commit, exists := s.cache.Commit(target); exists {
+		row.author = commit.Author
+		for _, tag := range commit.Tags {
+			if tag == target.Ref {
+				row.isTag = true
+			}
+		}
+	}
+
+	return &row
+}
+
+// Sort tasks by ascending creation date
+func sortTasks(tasks []*task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		ri, rj := tasks[i], tasks[j]
 		ti := utils.MinNullTime(
 			ri.createdAt,
 			ri.startedAt,
@@ -263,13 +525,14 @@ func (s BuildsByCommit) Rows() []HierarchicalTabularSourceRow {
 
 		return ti.Time.Before(tj.Time)
 	})
-
-	return rows
 }
 
 var ErrNoLogHere = errors.New("no log is associated to this row")
 
 func (s BuildsByCommit) Log(ctx context.Context, key interface{}) (string, error) {
+	if _, ok := key.(Target); ok {
+		return "", ErrNoLogHere
+	}
 	stepKey, ok := key.(taskKey)
 	if !ok {
 		return "", fmt.Errorf("key conversion to taskKey failed: '%v'", key)
@@ -277,3 +540,167 @@ func (s BuildsByCommit) Log(ctx context.Context, key interface{}) (string, error
 
 	return s.cache.Log(ctx, stepKey)
 }
+
+func (s BuildsByCommit) StreamLog(ctx context.Context, key interface{}, w io.Writer) error {
+	if _, ok := key.(Target); ok {
+		return ErrNoLogHere
+	}
+	stepKey, ok := key.(taskKey)
+	if !ok {
+		return fmt.Errorf("key conversion to taskKey failed: '%v'", key)
+	}
+
+	return s.cache.StreamLog(ctx, stepKey, w)
+}
+
+var ErrNoStepHere = errors.New("no pipeline or step is associated to this row")
+
+func (s BuildsByCommit) Restart(ctx context.Context, key interface{}) error {
+	if _, ok := key.(Target); ok {
+		return ErrNoStepHere
+	}
+	stepKey, ok := key.(taskKey)
+	if !ok {
+		return fmt.Errorf("key conversion to taskKey failed: '%v'", key)
+	}
+
+	return s.cache.Restart(ctx, stepKey)
+}
+
+func (s BuildsByCommit) Cancel(ctx context.Context, key interface{}) error {
+	if _, ok := key.(Target); ok {
+		return ErrNoStepHere
+	}
+	stepKey, ok := key.(taskKey)
+	if !ok {
+		return fmt.Errorf("key conversion to taskKey failed: '%v'", key)
+	}
+
+	return s.cache.Cancel(ctx, stepKey)
+}
+
+// Ask the provider of the pipeline of the row to start a new pipeline. On a row listing the
+// pipelines of a target, all providers that ran a pipeline for this target are asked to.
+func (s BuildsByCommit) Trigger(ctx context.Context, key interface{}) error {
+	if target, ok := key.(Target); ok {
+		triggered := false
+		// Several pipelines of the same provider must not lead to several new pipelines
+		type providerRef struct{ providerID, ref string }
+		seen := make(map[providerRef]struct{})
+		for _, p := range s.cache.PipelinesByRef(target) {
+			if _, exists := seen[providerRef{p.providerID, p.Ref}]; exists {
+				continue
+			}
+			seen[providerRef{p.providerID, p.Ref}] = struct{}{}
+			switch err := s.cache.Trigger(ctx, target, p.Key()); err {
+			case nil:
+				triggered = true
+			case ErrNotSupported:
+				continue
+			default:
+				return err
+			}
+		}
+		if !triggered {
+			return ErrNotSupported
+		}
+		return nil
+	}
+
+	stepKey, ok := key.(taskKey)
+	if !ok {
+		return fmt.Errorf("key conversion to taskKey failed: '%v'", key)
+	}
+	pKey, _ := stepKey.pipelineKeyAndPath()
+	for _, target := range s.targets {
+		for _, p := range s.cache.PipelinesByRef(target) {
+			if p.Key() == pKey {
+				return s.cache.Trigger(ctx, target, pKey)
+			}
+		}
+	}
+
+	return ErrUnknownStep
+}
+
+func (s BuildsByCommit) Play(ctx context.Context, key interface{}, variables map[string]string) error {
+	if _, ok := key.(Target); ok {
+		return ErrNoStepHere
+	}
+	stepKey, ok := key.(taskKey)
+	if !ok {
+		return fmt.Errorf("key conversion to taskKey failed: '%v'", key)
+	}
+
+	return s.cache.Play(ctx, stepKey, variables)
+}
+
+// Data source listing the recent commits of a git reference, each commit grouping its pipelines
+type BuildsByHistory struct {
+	cache  Cache
+	target Target
+}
+
+// Return a data source listing the pipelines of the recent commits of target. The history of
+// target must be monitored by Cache.MonitorHistory.
+func (c Cache) HistoryOfRef(target Target) HierarchicalTabularDataSource {
+	return BuildsByHistory{
+		cache:  c,
+		target: target,
+	}
+}
+
+// Return the data source listing the pipelines of each commit currently in the history
+func (s BuildsByHistory) commits() BuildsByCommit {
+	return BuildsByCommit{
+		cache:   s.cache,
+		targets: s.cache.History(s.target),
+		grouped: true,
+	}
+}
+
+func (s BuildsByHistory) Headers() []string {
+	return s.commits().Headers()
+}
+
+func (s BuildsByHistory) Alignment() map[string]text.Alignment {
+	return s.commits().Alignment()
+}
+
+func (s BuildsByHistory) Rows() []HierarchicalTabularSourceRow {
+	builds := s.commits()
+	rows := make([]HierarchicalTabularSourceRow, 0, len(builds.targets))
+	for _, target := range builds.targets {
+		row := builds.targetRow(target)
+		if commit, exists := s.cache.Commit(target); exists {
+			row.commit = &commit
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func (s BuildsByHistory) Log(ctx context.Context, key interface{}) (string, error) {
+	return s.commits().Log(ctx, key)
+}
+
+func (s BuildsByHistory) StreamLog(ctx context.Context, key interface{}, w io.Writer) error {
+	return s.commits().StreamLog(ctx, key, w)
+}
+
+func (s BuildsByHistory) Restart(ctx context.Context, key interface{}) error {
+	return s.commits().Restart(ctx, key)
+}
+
+func (s BuildsByHistory) Cancel(ctx context.Context, key interface{}) error {
+	return s.commits().Cancel(ctx, key)
+}
+
+func (s BuildsByHistory) Trigger(ctx context.Context, key interface{}) error {
+	return s.commits().Trigger(ctx, key)
+}
+
+func (s BuildsByHistory) Play(ctx context.Context, key interface{}, variables map[string]string) error {
+	return s.commits().Play(ctx, key, variables)
+}
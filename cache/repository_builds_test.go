@@ -1 +1,156 @@
 package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbedos/citop/utils"
+)
+
+func TestBuildsByCommit_Rows(t *testing.T) {
+	c := NewCache(nil, nil)
+	targets := []Target{
+		{Repository: "repo", Ref: "master"},
+		{Repository: "repo", Ref: "feature"},
+	}
+	pipelines := map[Target][]Pipeline{
+		targets[0]: {
+			{
+				providerHost: "host",
+				Step: Step{
+					ID:        "1",
+					State:     Passed,
+					UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+			{
+				providerHost: "host",
+				Step: Step{
+					ID:        "2",
+					State:     Failed,
+					UpdatedAt: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		targets[1]: {
+			{
+				providerHost: "host",
+				Step: Step{
+					ID:        "3",
+					State:     Running,
+					UpdatedAt: time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+	for target, ps := range pipelines {
+		for _, p := range ps {
+			if err := c.SavePipeline(target, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("single target", func(t *testing.T) {
+		rows := c.BuildsOfRef(targets[0]).Rows()
+		if len(rows) != 2 {
+			t.Fatalf("expected 2 rows but got %d", len(rows))
+		}
+		for i, ID := range []string{"1", "2"} {
+			if key := rows[i].Key().(taskKey); key.stepIDs[0].String != ID {
+				t.Fatalf("expected row #%d to have ID %q but got %q", i, ID, key.stepIDs[0].String)
+			}
+		}
+	})
+
+	t.Run("several targets", func(t *testing.T) {
+		rows := c.BuildsOfRefs(targets).Rows()
+		if len(rows) != len(targets) {
+			t.Fatalf("expected %d rows but got %d", len(targets), len(rows))
+		}
+
+		expectedStates := []State{Failed, Running}
+		expectedChildren := []int{2, 1}
+		for i, row := range rows {
+			if key := row.Key(); key != targets[i] {
+				t.Fatalf("expected key %v but got %v", targets[i], key)
+			}
+			if state := row.(*targetRow).state; state != expectedStates[i] {
+				t.Fatalf("expected state %q but got %q", expectedStates[i], state)
+			}
+			if n := len(row.Children()); n != expectedChildren[i] {
+				t.Fatalf("expected %d children but got %d", expectedChildren[i], n)
+			}
+		}
+	})
+}
+
+func TestTask_Tabular(t *testing.T) {
+	created := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	pipeline := Pipeline{
+		GitReference: GitReference{Ref: "master"},
+		Step: Step{
+			ID:           "1",
+			Type:         StepPipeline,
+			State:        Failed,
+			AllowFailure: true,
+			CreatedAt:    utils.NullTime{Valid: true, Time: created},
+			StartedAt:    utils.NullTime{Valid: true, Time: created.Add(90 * time.Second)},
+		},
+	}
+	task := taskFromPipeline(pipeline, nil, "Jane Doe")
+
+	expected := map[string]string{
+		"PROVIDER":      "unknown",
+		"AUTHOR":        "Jane Doe",
+		"QUEUED":        "1m30s",
+		"ALLOW FAILURE": "yes",
+	}
+	values := task.Tabular(time.UTC)
+	for column, value := range expected {
+		if s := values[column].String(); s != value {
+			t.Fatalf("expected %s to be %q but got %q", column, value, s)
+		}
+	}
+	for _, column := range BuildsColumns() {
+		if _, exists := values[column]; !exists {
+			t.Fatalf("missing column %q", column)
+		}
+	}
+}
+
+func TestBuildsByCommit_Headers(t *testing.T) {
+	c := NewCache(nil, nil)
+	targets := []Target{
+		{Repository: "repo", Ref: "master"},
+		{Repository: "repo", Ref: "feature"},
+	}
+
+	if headers := c.BuildsOfRef(targets[0]).Headers(); headers[0] == "REF" {
+		t.Fatalf("expected git reference to be hidden but got %v", headers)
+	}
+	if headers := c.BuildsOfRefs(targets).Headers(); headers[0] != "REF" {
+		t.Fatalf("expected git reference to be shown but got %v", headers)
+	}
+}
+
+func TestTask_SortKey(t *testing.T) {
+	failed := task{state: Failed}
+	passed := task{state: Passed, startedAt: utils.NullTime{Valid: true, Time: time.Now()}}
+
+	for _, column := range []string{"STATE", "STARTED"} {
+		kf, ok := failed.SortKey(column)
+		if !ok {
+			t.Fatalf("expected rows to be sortable by %s", column)
+		}
+		kp, _ := passed.SortKey(column)
+		// Failures come first and rows that have not started come last
+		if (column == "STATE") != (kf < kp) {
+			t.Fatalf("unexpected order of keys for %s: %d, %d", column, kf, kp)
+		}
+	}
+
+	if _, ok := failed.SortKey("NAME"); ok {
+		t.Fatal("expected rows not to be sortable by name")
+	}
+}
@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbedos/citop/utils"
+)
+
+const (
+	storePipelinesDir = "pipelines"
+	storeCommitsDir   = "commits"
+	storeLogsDir      = "logs"
+)
+
+// Store is an on-disk copy of the cache so that finished pipelines, commits and the logs of
+// finished steps survive restarts of citop. Each record is stored in its own file under dir.
+// Records are evicted from the store based on their age and on the total size of the store.
+type Store struct {
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+	mutex   *sync.Mutex
+}
+
+// Pipeline as stored on disk. Pipelines are only stored once they are finished.
+type pipelineRecord struct {
+	// URL used for looking up the pipeline
+	URL          string
+	ProviderID   string
+	ProviderHost string
+	Target       Target
+	Pipeline     Pipeline
+}
+
+func (r pipelineRecord) pipeline() Pipeline {
+	p := r.Pipeline
+	p.SetProvider(r.ProviderID, r.ProviderHost)
+	return p
+}
+
+// Return a copy of s without the content of the logs of s and its descendants. Logs are
+// stored separately.
+func withoutLogContent(s Step) Step {
+	s.Log.Content = utils.NullString{}
+	if len(s.Children) > 0 {
+		children := make([]Step, len(s.Children))
+		for i, child := range s.Children {
+			children[i] = withoutLogContent(child)
+		}
+		s.Children = children
+	}
+
+	return s
+}
+
+type commitRecord struct {
+	Target Target
+	Commit Commit
+}
+
+// Open the store located in the directory 'dir', creating it if needed, and evict old
+// records. Records older than maxAge are removed, then the oldest records are removed until
+// the total size of the store is below maxSize bytes. A value of zero disables the
+// corresponding limit.
+func NewStore(dir string, maxAge time.Duration, maxSize int64) (*Store, error) {
+	for _, subdir := range []string{storePipelinesDir, storeCommitsDir, storeLogsDir} {
+		if err := os.MkdirAll(path.Join(dir, subdir), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{
+		dir:     dir,
+		maxAge:  maxAge,
+		maxSize: maxSize,
+		mutex:   &sync.Mutex{},
+	}
+
+	return s, s.evict(time.Now())
+}
+
+// Name of the file storing the record identified by 'keys' in 'subdir'
+func (s Store) filename(subdir string, keys ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\x00")))
+	return path.Join(s.dir, subdir, hex.EncodeToString(sum[:]))
+}
+
+// Atomically write 'bs' to the file 'filename'
+func (s Store) write(filename string, bs []byte) error {
+	f, err := ioutil.TempFile(path.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(bs); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+func (s Store) writeJSON(filename string, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write(filename, bs)
+}
+
+// Call f on the content of each record of 'subdir'. Records that cannot be decoded are removed
+// from the store since they can only be the result of an older version of citop or of a
+// corrupted file.
+func (s Store) forEach(subdir string, f func(bs []byte) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	infos, err := ioutil.ReadDir(path.Join(s.dir, subdir))
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		filename := path.Join(s.dir, subdir, info.Name())
+		bs, err := ioutil.ReadFile(filename)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := f(bs); err != nil {
+			if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) savePipeline(record pipelineRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record.Pipeline.Step = withoutLogContent(record.Pipeline.Step)
+
+	filename := s.filename(storePipelinesDir, record.Target.Repository, record.Target.Ref,
+		record.ProviderHost, record.Pipeline.ID)
+	return s.writeJSON(filename, record)
+}
+
+func (s *Store) pipelines() ([]pipelineRecord, error) {
+	records := make([]pipelineRecord, 0)
+	err := s.forEach(storePipelinesDir, func(bs []byte) error {
+		var record pipelineRecord
+		if err := json.Unmarshal(bs, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+
+	return records, err
+}
+
+func (s *Store) saveCommit(record commitRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	filename := s.filename(storeCommitsDir, record.Target.Repository, record.Target.Ref)
+	return s.writeJSON(filename, record)
+}
+
+func (s *Store) commits() ([]commitRecord, error) {
+	records := make([]commitRecord, 0)
+	err := s.forEach(storeCommitsDir, func(bs []byte) error {
+		var record commitRecord
+		if err := json.Unmarshal(bs, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+
+	return records, err
+}
+
+func (s *Store) logFilename(key PipelineKey, stepIDs []string) string {
+	keys := append([]string{key.ProviderHost, key.ID}, stepIDs...)
+	return s.filename(storeLogsDir, keys...)
+}
+
+func (s *Store) saveLog(key PipelineKey, stepIDs []string, log string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.write(s.logFilename(key, stepIDs), []byte(log))
+}
+
+// Remove the log of the step identified by 'key' and 'stepIDs' if it is in store
+func (s *Store) deleteLog(key PipelineKey, stepIDs []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(s.logFilename(key, stepIDs)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Return the log of the step identified by 'key' and 'stepIDs' and whether it was found in store
+func (s *Store) log(key PipelineKey, stepIDs []string) (string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bs, err := ioutil.ReadFile(s.logFilename(key, stepIDs))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return string(bs), true, nil
+}
+
+// Remove records older than s.maxAge, then remove the oldest records until the size of the
+// store is below s.maxSize
+func (s *Store) evict(now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	files := make([]file, 0)
+	for _, subdir := range []string{storePipelinesDir, storeCommitsDir, storeLogsDir} {
+		infos, err := ioutil.ReadDir(path.Join(s.dir, subdir))
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			files = append(files, file{
+				name:    path.Join(s.dir, subdir, info.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+		}
+	}
+
+	// Most recent files first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+
+	var size int64
+	for _, f := range files {
+		size += f.size
+		tooOld := s.maxAge > 0 && now.Sub(f.modTime) > s.maxAge
+		tooLarge := s.maxSize > 0 && size > s.maxSize
+		if tooOld || tooLarge {
+			if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			size -= f.size
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/utils"
+)
+
+func TestNewPersistentCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := Target{Repository: "https://github.com/nbedos/citop", Ref: "master"}
+	commit := Commit{
+		Sha:      "a24840cf94b395af69da4a1001d32e3694637e20",
+		Message:  "Fix typos",
+		Date:     time.Date(2019, 12, 16, 18, 6, 43, 0, time.UTC),
+		Branches: []string{"master"},
+	}
+	pipeline := Pipeline{
+		Number:       "42",
+		providerID:   "github-0",
+		providerHost: "github.com",
+		GitReference: GitReference{
+			SHA: commit.Sha,
+			Ref: "master",
+		},
+		Step: Step{
+			ID:    "1",
+			State: Passed,
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2019, 12, 16, 18, 10, 0, 0, time.UTC),
+			},
+			Children: []Step{
+				{
+					ID:    "2",
+					State: Passed,
+				},
+			},
+		},
+	}
+	u := "https://github.com/nbedos/citop/actions/runs/1"
+
+	c, err := NewPersistentCache(nil, nil, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SaveCommit(target, commit)
+	if err := c.storeCommit(target); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SavePipeline(target, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.storePipeline(u, target, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.saveLog(pipeline.Key(), []string{"2"}, "log\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a new instance of citop
+	c, err = NewPersistentCache(nil, nil, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("commits are loaded from store", func(t *testing.T) {
+		loadedCommit, exists := c.Commit(target)
+		if !exists {
+			t.Fatal("commit not found")
+		}
+		if diff := cmp.Diff(commit, loadedCommit); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("pipelines are loaded from store", func(t *testing.T) {
+		pipelines := c.PipelinesByRef(target)
+		if len(pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+		}
+		if diff := pipeline.Diff(pipelines[0]); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("finished pipelines are not requested from providers", func(t *testing.T) {
+		// Without any CI provider, broadcastMonitorPipeline would return ErrUnknownPipelineURL
+		other := Target{Repository: target.Repository, Ref: commit.Sha}
+		updates := make(chan time.Time, 1)
+		if err := c.broadcastMonitorPipeline(context.Background(), u, other, updates); err != nil {
+			t.Fatal(err)
+		}
+		if pipelines := c.PipelinesByRef(other); len(pipelines) != 1 {
+			t.Fatalf("expected 1 pipeline but got %d", len(pipelines))
+		}
+	})
+
+	t.Run("logs of finished steps are loaded from store", func(t *testing.T) {
+		key := taskKey{
+			providerHost: pipeline.providerHost,
+			stepIDs: StepPath{
+				{Valid: true, String: "1"},
+				{Valid: true, String: "2"},
+			},
+		}
+		log, err := c.Log(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log != "log\n" {
+			t.Fatalf("expected %q but got %q", "log\n", log)
+		}
+	})
+}
+
+func TestStore_evict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "citop-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir, 24*time.Hour, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	files := []struct {
+		name    string
+		modTime time.Time
+		evicted bool
+	}{
+		{name: "recent", modTime: now.Add(-time.Minute)},
+		{name: "older", modTime: now.Add(-time.Hour)},
+		// Evicted since the size of the store would exceed 15 bytes otherwise
+		{name: "oldest", modTime: now.Add(-2 * time.Hour), evicted: true},
+		// Evicted since it is more than 24 hours old
+		{name: "obsolete", modTime: now.Add(-48 * time.Hour), evicted: true},
+	}
+	for _, f := range files {
+		filename := path.Join(dir, storeLogsDir, f.name)
+		if err := ioutil.WriteFile(filename, []byte("0123456"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, f.modTime, f.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.evict(now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		_, err := os.Stat(path.Join(dir, storeLogsDir, f.name))
+		if evicted := os.IsNotExist(err); evicted != f.evicted {
+			t.Fatalf("file %q: expected evicted=%v but got %v (%v)", f.name, f.evicted, evicted, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"fmt"
+
+	"github.com/nbedos/citop/utils"
+)
+
+// Change of the state of a pipeline or of one of its steps
+type StateTransition struct {
+	Target   Target
+	Pipeline PipelineKey
+	Number   string
+	// IDs of the steps leading from the pipeline to the step that changed state. Empty if the
+	// state of the pipeline itself changed.
+	StepIDs  []string
+	Name     string
+	Type     StepType
+	Previous State
+	State    State
+	WebURL   utils.NullString
+}
+
+func (t StateTransition) String() string {
+	name := fmt.Sprintf("%s #%s", t.Pipeline.ProviderHost, t.Number)
+	if t.Name != "" {
+		name = fmt.Sprintf("%s: %s", name, t.Name)
+	}
+	previous := t.Previous
+	if previous == Unknown {
+		previous = "unknown"
+	}
+	return fmt.Sprintf("%s %s -> %s", name, previous, t.State)
+}
+
+// Return the state transitions of a pipeline and of its steps between 'previous' and 'current'.
+// Steps that do not exist in 'previous' are considered to have transitioned from Unknown.
+func stateTransitions(target Target, previousPipeline Pipeline, currentPipeline Pipeline) []StateTransition {
+	transitions := make([]StateTransition, 0)
+
+	var compare func(previous *Step, current Step, stepIDs []string)
+	compare = func(previous *Step, current Step, stepIDs []string) {
+		previousState := Unknown
+		if previous != nil {
+			previousState = previous.State
+		}
+		if previousState != current.State {
+			transitions = append(transitions, StateTransition{
+				Target:   target,
+				Pipeline: currentPipeline.Key(),
+				Number:   currentPipeline.Number,
+				StepIDs:  stepIDs,
+				Name:     current.Name,
+				Type:     current.Type,
+				Previous: previousState,
+				State:    current.State,
+				WebURL:   current.WebURL,
+			})
+		}
+
+		previousChildren := make(map[string]*Step)
+		if previous != nil {
+			for i := range previous.Children {
+				previousChildren[previous.Children[i].ID] = &previous.Children[i]
+			}
+		}
+		for _, child := range current.Children {
+			childIDs := append(stepIDs[:len(stepIDs):len(stepIDs)], child.ID)
+			compare(previousChildren[child.ID], child, childIDs)
+		}
+	}
+	compare(&previousPipeline.Step, currentPipeline.Step, []string{})
+
+	return transitions
+}
+
+// Ask the cache to send the state transitions of pipelines and steps on the channel
+// 'transitions' as they are noticed while monitoring pipelines. Pipelines seen for the first
+// time do not produce transitions.
+func (c *Cache) NotifyTransitions(transitions chan<- StateTransition) {
+	c.transitions = transitions
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStateTransitions(t *testing.T) {
+	target := Target{Repository: "https://gitlab.com/nbedos/citop", Ref: "master"}
+	previous := Pipeline{
+		Number:       "42",
+		providerID:   "gitlab-0",
+		providerHost: "gitlab.com",
+		Step: Step{
+			ID:    "1",
+			State: Running,
+			Children: []Step{
+				{ID: "2", Name: "tests", Type: StepJob, State: Running},
+				{ID: "3", Name: "lint", Type: StepJob, State: Passed},
+			},
+		},
+	}
+	current := previous
+	current.Step = Step{
+		ID:    "1",
+		State: Failed,
+		Children: []Step{
+			{ID: "2", Name: "tests", Type: StepJob, State: Failed},
+			{ID: "3", Name: "lint", Type: StepJob, State: Passed},
+			{ID: "4", Name: "deploy", Type: StepJob, State: Skipped},
+		},
+	}
+
+	expected := []StateTransition{
+		{
+			Target:   target,
+			Pipeline: current.Key(),
+			Number:   "42",
+			StepIDs:  []string{},
+			Previous: Running,
+			State:    Failed,
+		},
+		{
+			Target:   target,
+			Pipeline: current.Key(),
+			Number:   "42",
+			StepIDs:  []string{"2"},
+			Name:     "tests",
+			Type:     StepJob,
+			Previous: Running,
+			State:    Failed,
+		},
+		{
+			Target:   target,
+			Pipeline: current.Key(),
+			Number:   "42",
+			StepIDs:  []string{"4"},
+			Name:     "deploy",
+			Type:     StepJob,
+			Previous: Unknown,
+			State:    Skipped,
+		},
+	}
+
+	transitions := stateTransitions(target, previous, current)
+	if diff := cmp.Diff(expected, transitions); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	if s := transitions[1].String(); s != "gitlab.com #42: tests running -> failed" {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
+
+// Provider returning a pipeline that moves on to the next state at each request
+type statesProvider struct {
+	logProvider
+	states []State
+	calls  *int32
+}
+
+func (p statesProvider) BuildFromURL(ctx context.Context, u string) (Pipeline, error) {
+	i := int(atomic.AddInt32(p.calls, 1)) - 1
+	if i >= len(p.states) {
+		i = len(p.states) - 1
+	}
+	return Pipeline{
+		Number: "42",
+		Step: Step{
+			ID:        "1",
+			State:     p.states[i],
+			UpdatedAt: time.Date(2020, 1, 1, 0, i, 0, 0, time.UTC),
+		},
+	}, nil
+}
+
+func TestCache_NotifyTransitions(t *testing.T) {
+	interval := pipelinePollingInterval
+	pipelinePollingInterval = time.Millisecond
+	defer func() { pipelinePollingInterval = interval }()
+
+	provider := statesProvider{
+		states: []State{Pending, Running, Failed},
+		calls:  new(int32),
+	}
+	c := NewCache([]CIProvider{provider}, nil)
+	transitions := make(chan StateTransition)
+	c.NotifyTransitions(transitions)
+	updates := make(chan time.Time)
+	go func() {
+		for range updates {
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = c.monitorPipeline(ctx, provider, "https://example.com/1", Target{}, updates, false)
+	}()
+
+	states := make([]string, 0)
+	for len(states) < 2 {
+		// Slow notifier
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case transition := <-transitions:
+			states = append(states, fmt.Sprintf("%s -> %s", transition.Previous, transition.State))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	expected := []string{"pending -> running", "running -> failed"}
+	if diff := cmp.Diff(expected, states); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
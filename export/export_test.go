@@ -0,0 +1,118 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func testPipelines() []cache.Pipeline {
+	startedAt := time.Date(2019, 12, 16, 18, 6, 43, 0, time.UTC)
+	finishedAt := startedAt.Add(5 * time.Minute)
+	at := func(t time.Time) utils.NullTime {
+		return utils.NullTime{Valid: true, Time: t}
+	}
+
+	pipeline := cache.Pipeline{
+		Number: "42",
+		GitReference: cache.GitReference{
+			SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+			Ref: "master",
+		},
+		Step: cache.Step{
+			ID:         "1",
+			Type:       cache.StepPipeline,
+			State:      cache.Failed,
+			CreatedAt:  at(startedAt),
+			StartedAt:  at(startedAt),
+			FinishedAt: at(finishedAt),
+			UpdatedAt:  finishedAt,
+			Duration:   utils.NullSub(at(finishedAt), at(startedAt)),
+			WebURL:     utils.NullString{Valid: true, String: "https://gitlab.com/nbedos/citop/pipelines/1"},
+			Children: []cache.Step{
+				{
+					ID:    "test",
+					Name:  "test",
+					Type:  cache.StepStage,
+					State: cache.Failed,
+					Children: []cache.Step{
+						{
+							ID:         "2",
+							Name:       "unit tests",
+							Type:       cache.StepJob,
+							State:      cache.Passed,
+							StartedAt:  at(startedAt),
+							FinishedAt: at(startedAt.Add(90 * time.Second)),
+							Duration:   utils.NullDuration{Valid: true, Duration: 90 * time.Second},
+							WebURL:     utils.NullString{Valid: true, String: "https://gitlab.com/nbedos/citop/-/jobs/2"},
+						},
+						{
+							ID:    "3",
+							Name:  "integration tests",
+							Type:  cache.StepJob,
+							State: cache.Failed,
+						},
+						{
+							ID:           "4",
+							Name:         "lint",
+							Type:         cache.StepJob,
+							State:        cache.Failed,
+							AllowFailure: true,
+						},
+						{
+							ID:    "5",
+							Name:  "deploy",
+							Type:  cache.StepJob,
+							State: cache.Manual,
+						},
+					},
+				},
+			},
+		},
+	}
+	pipeline.SetProvider("gitlab-0", "gitlab.com")
+
+	return []cache.Pipeline{pipeline}
+}
+
+// Compare the output of 'encode' to the golden file 'filename'. The golden file is overwritten
+// instead if the -update flag is set.
+func testGolden(t *testing.T, filename string, encode func(w io.Writer, pipelines []cache.Pipeline) error) {
+	buf := bytes.Buffer{}
+	if err := encode(&buf, testPipelines()); err != nil {
+		t.Fatal(err)
+	}
+
+	filename = path.Join("test_data", filename)
+	if *update {
+		if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(expected), buf.String()); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testGolden(t, "pipelines.json", JSON)
+}
+
+func TestJUnit(t *testing.T) {
+	testGolden(t, "pipelines.xml", JUnit)
+}
@@ -0,0 +1,90 @@
+// Package export encodes pipelines in formats meant for other programs such as dashboards or
+// test reporters
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+type jsonStep struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	State        string     `json:"state"`
+	AllowFailure bool       `json:"allow_failure"`
+	CreatedAt    *time.Time `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	// Duration in seconds
+	Duration *float64   `json:"duration"`
+	WebURL   *string    `json:"web_url"`
+	Children []jsonStep `json:"children"`
+}
+
+type jsonPipeline struct {
+	Provider string `json:"provider"`
+	Number   string `json:"number"`
+	SHA      string `json:"sha"`
+	Ref      string `json:"ref"`
+	IsTag    bool   `json:"is_tag"`
+	jsonStep
+}
+
+func nullTime(t utils.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func newJSONStep(s cache.Step) jsonStep {
+	step := jsonStep{
+		ID:           s.ID,
+		Name:         s.Name,
+		Type:         s.Type.String(),
+		State:        string(s.State),
+		AllowFailure: s.AllowFailure,
+		CreatedAt:    nullTime(s.CreatedAt),
+		StartedAt:    nullTime(s.StartedAt),
+		FinishedAt:   nullTime(s.FinishedAt),
+		UpdatedAt:    s.UpdatedAt,
+		Children:     make([]jsonStep, 0, len(s.Children)),
+	}
+	if s.Duration.Valid {
+		seconds := s.Duration.Duration.Seconds()
+		step.Duration = &seconds
+	}
+	if s.WebURL.Valid {
+		step.WebURL = &s.WebURL.String
+	}
+	for _, child := range s.Children {
+		step.Children = append(step.Children, newJSONStep(child))
+	}
+
+	return step
+}
+
+// Write pipelines to w as a JSON array. Logs are not included.
+func JSON(w io.Writer, pipelines []cache.Pipeline) error {
+	values := make([]jsonPipeline, 0, len(pipelines))
+	for _, p := range pipelines {
+		values = append(values, jsonPipeline{
+			Provider: p.Key().ProviderHost,
+			Number:   p.Number,
+			SHA:      p.SHA,
+			Ref:      p.Ref,
+			IsTag:    p.IsTag,
+			jsonStep: newJSONStep(p.Step),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nbedos/citop/cache"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr,omitempty"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr,omitempty"`
+	URL       string        `xml:"url,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// Append a test case to the suite for 'step' and each of its descendants that are jobs or
+// tasks. 'parents' are the names of the ancestors of 'step'.
+func (s *junitTestSuite) addCases(step cache.Step, parents []string) {
+	if step.Type == cache.StepJob || step.Type == cache.StepTask {
+		testCase := junitTestCase{
+			Name:      step.Name,
+			ClassName: strings.Join(parents, " / "),
+		}
+		if step.Duration.Valid {
+			testCase.Time = fmt.Sprintf("%.3f", step.Duration.Duration.Seconds())
+		}
+		if step.WebURL.Valid {
+			testCase.URL = step.WebURL.String
+		}
+
+		switch {
+		case step.State == cache.Failed && !step.AllowFailure:
+			testCase.Failure = &junitMessage{Message: string(step.State)}
+			s.Failures++
+		case step.State == cache.Failed:
+			testCase.Skipped = &junitMessage{Message: "failed (allowed to fail)"}
+			s.Skipped++
+		case step.State != cache.Passed:
+			testCase.Skipped = &junitMessage{Message: string(step.State)}
+			s.Skipped++
+		}
+		s.Cases = append(s.Cases, testCase)
+		s.Tests++
+	}
+
+	parents = append(parents[:len(parents):len(parents)], step.Name)
+	for _, child := range step.Children {
+		s.addCases(child, parents)
+	}
+}
+
+// Write pipelines to w as a JUnit XML report. Each pipeline is a test suite and each job or task
+// of the pipeline is a test case. Failed jobs and tasks are reported as failures unless they are
+// allowed to fail, and jobs and tasks that did not pass are reported as skipped.
+func JUnit(w io.Writer, pipelines []cache.Pipeline) error {
+	suites := junitTestSuites{
+		Suites: make([]junitTestSuite, 0, len(pipelines)),
+	}
+	for _, p := range pipelines {
+		name := fmt.Sprintf("%s #%s", p.Key().ProviderHost, p.Number)
+		if p.Name != "" {
+			name = fmt.Sprintf("%s: %s", name, p.Name)
+		}
+		suite := junitTestSuite{
+			Name:  name,
+			Cases: make([]junitTestCase, 0),
+		}
+		if p.Duration.Valid {
+			suite.Time = fmt.Sprintf("%.3f", p.Duration.Duration.Seconds())
+		}
+		if p.StartedAt.Valid {
+			suite.Timestamp = p.StartedAt.Time.UTC().Format("2006-01-02T15:04:05")
+		}
+		for _, child := range p.Children {
+			suite.addCases(child, []string{name})
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
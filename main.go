@@ -17,6 +17,7 @@ import (
 
 	"github.com/gdamore/tcell"
 	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/notify"
 	"github.com/nbedos/citop/providers"
 	"github.com/nbedos/citop/tui"
 	"github.com/nbedos/citop/utils"
@@ -46,6 +47,8 @@ token = ""
 
 [[providers.azure]]
 
+[[providers.bitbucket]]
+
 `
 
 type ProvidersConfiguration struct {
@@ -56,10 +59,13 @@ type ProvidersConfiguration struct {
 		RequestsPerSecond float64 `toml:"max_requests_per_second"`
 	}
 	GitHub []struct {
+		Name  string `toml:"name"`
+		URL   string `toml:"url"`
 		Token string `toml:"token"`
 	}
 	CircleCI []struct {
 		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
 		Token             string  `toml:"token"`
 		RequestsPerSecond float64 `toml:"max_requests_per_second"`
 	}
@@ -75,14 +81,203 @@ type ProvidersConfiguration struct {
 		RequestsPerSecond float64 `toml:"max_requests_per_second"`
 	}
 	Azure []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+	Bitbucket []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Username          string  `toml:"username"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+	Gitea []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+	Jenkins []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Username          string  `toml:"username"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+	Buildkite []struct {
 		Name              string  `toml:"name"`
 		Token             string  `toml:"token"`
 		RequestsPerSecond float64 `toml:"max_requests_per_second"`
 	}
+	Drone []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+	Woodpecker []struct {
+		Name              string  `toml:"name"`
+		URL               string  `toml:"url"`
+		Token             string  `toml:"token"`
+		RequestsPerSecond float64 `toml:"max_requests_per_second"`
+	}
+}
+
+type CacheConfiguration struct {
+	Disabled   bool `toml:"disabled"`
+	MaxAgeDays int  `toml:"max_age_days"`
+	MaxSizeMB  int  `toml:"max_size_mb"`
+}
+
+const defaultCacheMaxAgeDays = 30
+const defaultCacheMaxSizeMB = 100
+
+// Return the on-disk store of the cache, or nil if persistence of the cache is disabled
+func (c CacheConfiguration) Store() (*cache.Store, error) {
+	if c.Disabled {
+		return nil, nil
+	}
+
+	maxAgeDays := c.MaxAgeDays
+	if maxAgeDays <= 0 {
+		maxAgeDays = defaultCacheMaxAgeDays
+	}
+	maxSizeMB := c.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultCacheMaxSizeMB
+	}
+
+	dir := utils.XDGCacheLocation(ConfDir)
+	return cache.NewStore(dir, time.Duration(maxAgeDays)*24*time.Hour, int64(maxSizeMB)<<20)
+}
+
+type NotificationsConfiguration struct {
+	Bell struct {
+		Enabled bool     `toml:"enabled"`
+		States  []string `toml:"states"`
+		Steps   bool     `toml:"steps"`
+	} `toml:"bell"`
+	Command []struct {
+		Command []string `toml:"command"`
+		States  []string `toml:"states"`
+		Steps   bool     `toml:"steps"`
+	} `toml:"command"`
+	Webhook []struct {
+		URL    string   `toml:"url"`
+		States []string `toml:"states"`
+		Steps  bool     `toml:"steps"`
+	} `toml:"webhook"`
+}
+
+var defaultNotificationStates = []cache.State{cache.Passed, cache.Failed, cache.Canceled}
+
+func notificationFilter(states []string, steps bool) (notify.Filter, error) {
+	filter := notify.Filter{
+		States: defaultNotificationStates,
+		Steps:  steps,
+	}
+	if len(states) > 0 {
+		filter.States = make([]cache.State, 0, len(states))
+	}
+	for _, s := range states {
+		state := cache.State(s)
+		switch state {
+		case cache.Pending, cache.Running, cache.Passed, cache.Failed, cache.Canceled, cache.Manual, cache.Skipped:
+			filter.States = append(filter.States, state)
+		default:
+			return filter, fmt.Errorf("invalid state %q", s)
+		}
+	}
+
+	return filter, nil
+}
+
+// Return the notifiers described by the configuration, each one wrapped by its filter
+func (c NotificationsConfiguration) Notifiers() ([]notify.Notifier, error) {
+	notifiers := make([]notify.Notifier, 0)
+
+	if c.Bell.Enabled {
+		filter, err := notificationFilter(c.Bell.States, c.Bell.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.bell: %v", err)
+		}
+		notifiers = append(notifiers, notify.WithFilter(notify.NewBell(os.Stdout), filter))
+	}
+
+	for i, conf := range c.Command {
+		filter, err := notificationFilter(conf.States, conf.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.command[%d]: %v", i, err)
+		}
+		command, err := notify.NewCommand(conf.Command)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.command[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, notify.WithFilter(command, filter))
+	}
+
+	for i, conf := range c.Webhook {
+		filter, err := notificationFilter(conf.States, conf.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.webhook[%d]: %v", i, err)
+		}
+		u, err := url.Parse(conf.URL)
+		if err != nil {
+			return nil, fmt.Errorf("notifications.webhook[%d]: %v", i, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("notifications.webhook[%d]: invalid URL %q", i, conf.URL)
+		}
+		notifiers = append(notifiers, notify.WithFilter(notify.NewWebhook(conf.URL), filter))
+	}
+
+	return notifiers, nil
+}
+
+type ThemeConfiguration struct {
+	Name string `toml:"name"`
+	// Styles overriding those of the theme, indexed by class name
+	Styles map[string]struct {
+		Foreground string   `toml:"foreground"`
+		Background string   `toml:"background"`
+		Attributes []string `toml:"attributes"`
+	} `toml:"styles"`
+}
+
+// Return the theme of the user interface
+func (c ThemeConfiguration) Theme() (tui.Theme, error) {
+	styles := make(map[string]tui.Style, len(c.Styles))
+	for class, style := range c.Styles {
+		styles[class] = tui.Style{
+			Foreground: style.Foreground,
+			Background: style.Background,
+			Attributes: style.Attributes,
+		}
+	}
+
+	return tui.NewTheme(c.Name, styles)
+}
+
+type TableConfiguration struct {
+	Columns   []string       `toml:"columns"`
+	MaxWidths map[string]int `toml:"max_widths"`
+}
+
+// Return the columns of the table of pipelines
+func (c TableConfiguration) TableColumns() (tui.Columns, error) {
+	return tui.NewColumns(c.Columns, c.MaxWidths)
 }
 
 type Configuration struct {
-	Providers ProvidersConfiguration
+	Providers     ProvidersConfiguration
+	Cache         CacheConfiguration
+	Notifications NotificationsConfiguration
+	// Keys bound to each command of the user interface, indexed by command name
+	Keys  map[string][]string
+	Theme ThemeConfiguration
+	Table TableConfiguration
 }
 
 var ErrMissingConf = errors.New("missing configuration file")
@@ -145,8 +340,16 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 
 	for i, conf := range c.GitHub {
 		id := fmt.Sprintf("github-%d", i)
-		client := providers.NewGitHubClient(ctx, id, &conf.Token)
+		name := "github"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client, err := providers.NewGitHubClient(ctx, id, name, conf.URL, &conf.Token)
+		if err != nil {
+			return nil, nil, err
+		}
 		source = append(source, client)
+		ci = append(ci, client)
 	}
 
 	for i, conf := range c.CircleCI {
@@ -155,11 +358,23 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
 		}
 		id := fmt.Sprintf("circleci-%d", i)
+		var u url.URL
+		switch strings.ToLower(conf.URL) {
+		case "", "com":
+			u = providers.CircleCIURL
+		default:
+			serverURL, err := url.Parse(conf.URL)
+			if err != nil {
+				return nil, nil, err
+			}
+			u = providers.CircleCIServerAPIURL(*serverURL)
+		}
+
 		name := "circleci"
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewCircleCIClient(id, name, conf.Token, providers.CircleCIURL, rateLimit)
+		client := providers.NewCircleCIClient(id, name, conf.Token, u, rateLimit)
 		ci = append(ci, client)
 	}
 
@@ -211,21 +426,163 @@ func (c ProvidersConfiguration) Providers(ctx context.Context) ([]cache.SourcePr
 			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
 		}
 		id := fmt.Sprintf("azure-%d", i)
+		var err error
+		var u *url.URL
+		switch strings.ToLower(conf.URL) {
+		case "", "com":
+			u = &providers.AzureURL
+		default:
+			u, err = url.Parse(conf.URL)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
 		name := "azure"
 		if conf.Name != "" {
 			name = conf.Name
 		}
-		client := providers.NewAzurePipelinesClient(id, name, conf.Token, rateLimit)
+		client := providers.NewAzurePipelinesClient(id, name, conf.Token, *u, rateLimit)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Bitbucket {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("bitbucket-%d", i)
+		u := &providers.BitbucketCloudURL
+		if conf.URL != "" {
+			var err error
+			if u, err = url.Parse(conf.URL); err != nil {
+				return nil, nil, err
+			}
+		}
+		name := "bitbucket"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewBitbucketClient(id, name, *u, conf.Username, conf.Token, rateLimit)
+		source = append(source, client)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Gitea {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("gitea-%d", i)
+		if conf.URL == "" {
+			return nil, nil, fmt.Errorf("missing URL for Gitea provider #%d", i)
+		}
+		u, err := url.Parse(conf.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := "gitea"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewGiteaClient(id, name, *u, conf.Token, rateLimit)
+		source = append(source, client)
+	}
+
+	for i, conf := range c.Jenkins {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("jenkins-%d", i)
+		if conf.URL == "" {
+			return nil, nil, fmt.Errorf("missing URL for Jenkins provider #%d", i)
+		}
+		u, err := url.Parse(conf.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := "jenkins"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewJenkinsClient(id, name, *u, conf.Username, conf.Token, rateLimit)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Buildkite {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("buildkite-%d", i)
+		name := "buildkite"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewBuildkiteClient(id, name, conf.Token, providers.BuildkiteURL, rateLimit)
 		ci = append(ci, client)
 	}
+
+	for i, conf := range c.Drone {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("drone-%d", i)
+		if conf.URL == "" {
+			return nil, nil, fmt.Errorf("missing URL for Drone provider #%d", i)
+		}
+		u, err := url.Parse(conf.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := "drone"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewDroneClient(id, name, conf.Token, *u, rateLimit)
+		ci = append(ci, client)
+	}
+
+	for i, conf := range c.Woodpecker {
+		rateLimit := time.Second / 10
+		if conf.RequestsPerSecond > 0 {
+			rateLimit = time.Second / time.Duration(conf.RequestsPerSecond)
+		}
+		id := fmt.Sprintf("woodpecker-%d", i)
+		if conf.URL == "" {
+			return nil, nil, fmt.Errorf("missing URL for Woodpecker provider #%d", i)
+		}
+		u, err := url.Parse(conf.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := "woodpecker"
+		if conf.Name != "" {
+			name = conf.Name
+		}
+		client := providers.NewWoodpeckerClient(id, name, conf.Token, *u, rateLimit)
+		ci = append(ci, client)
+	}
+
 	return source, ci, nil
 }
 
-const usage = `usage: citop [-r REPOSITORY | --repository REPOSITORY] [COMMIT]
+const usage = `usage: citop [--pipeline URL]... [COMMIT]... [-r REPOSITORY [COMMIT]...]...
+       citop status [--timeout DURATION] [--format FORMAT] [--pipeline URL]...
+                    [COMMIT]... [-r REPOSITORY [COMMIT]...]...
        citop -h | --help
        citop --version
 
-Monitor CI pipelines associated to a specific commit of a git repository
+Monitor CI pipelines associated to specific commits of git repositories
+
+Commands:
+  status        Do not start the user interface. Wait until all the
+                pipelines are finished, print them and exit with
+                status 0 if they passed, 1 if one of them failed or
+                was canceled and 2 otherwise (timeout, error or
+                unknown status).
 
 Positional arguments:
   COMMIT        Specify the commit to monitor. COMMIT is expected to be
@@ -233,26 +590,148 @@ Positional arguments:
                 a branch. If this option is missing citop will monitor
                 the commit referenced by HEAD.
 
+                COMMIT may also refer to a pull request (or merge
+                request on GitLab) written '#1234' or 'pr:1234', in
+                which case the head commit of the pull request is
+                monitored, even after a force-push.
+
+                Several commits can be specified, in which case their
+                pipelines are monitored at the same time and grouped
+                by commit.
+
 Options:
   -r REPOSITORY, --repository REPOSITORY
                 Specify the git repository to work with. REPOSITORY can
                 be either a path to a local git repository, or the URL
-                of an online repository hosted at GitHub or GitLab.
+                of an online repository hosted at GitHub, GitLab,
+                Bitbucket or Gitea.
                 Both web URLs and git URLs are accepted.
 
                 In the absence of this option, citop will work with the
                 git repository located in the current directory. If
                 there is no such repository, citop will fail.
 
+                This option can be repeated. Each commit applies to the
+                repository specified by the last preceding repository
+                option.
+
+  --pipeline URL
+                Monitor the CI pipeline located at URL instead of
+                looking for the pipelines associated to COMMIT. This
+                option can be repeated and does not require any source
+                provider to be configured.
+
+  --wait        Same as the status command
+
+  --timeout DURATION
+                Stop waiting for pipelines after DURATION, for example
+                '90s' or '1h30m', and exit with status 2. Only valid
+                with the status command.
+
+  --format FORMAT
+                Print pipelines as a tree ('text', the default), as JSON
+                ('json') or as a JUnit XML report ('junit'). Only valid
+                with the status command.
+
   -h, --help    Show usage
 
   --version     Print the version of citop being run`
 
-func main() {
-	signal.Ignore(syscall.SIGINT)
-	// FIXME Do not ignore SIGTSTP/SIGCONT
-	signal.Ignore(syscall.SIGTSTP)
+// Value of a command line option that may be repeated
+type stringList []string
 
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Git references to monitor, as specified on the command line. Each commit refers to the
+// repository given by the last repository option that precedes it, or to defaultRepository
+// if there is no such option.
+type targetList struct {
+	defaultRepository string
+	defaultCommit     string
+	targets           []cache.Target
+	// True if the last target was created by a repository option not yet followed by a commit
+	pending bool
+}
+
+func (l *targetList) String() string {
+	targets := make([]string, 0, len(l.targets))
+	for _, target := range l.targets {
+		targets = append(targets, target.String())
+	}
+	return strings.Join(targets, ", ")
+}
+
+func (l *targetList) Set(repository string) error {
+	l.targets = append(l.targets, cache.Target{
+		Repository: repository,
+		Ref:        l.defaultCommit,
+	})
+	l.pending = true
+	return nil
+}
+
+func (l *targetList) addCommit(commit string) {
+	if l.pending {
+		l.targets[len(l.targets)-1].Ref = commit
+		l.pending = false
+		return
+	}
+
+	repository := l.defaultRepository
+	if len(l.targets) > 0 {
+		repository = l.targets[len(l.targets)-1].Repository
+	}
+	l.targets = append(l.targets, cache.Target{
+		Repository: repository,
+		Ref:        commit,
+	})
+}
+
+func (l targetList) Targets() []cache.Target {
+	if len(l.targets) == 0 {
+		return []cache.Target{{
+			Repository: l.defaultRepository,
+			Ref:        l.defaultCommit,
+		}}
+	}
+	return l.targets
+}
+
+// Exit status of the status command for pipelines in the state 'state'
+func statusExitCode(state cache.State) int {
+	switch state {
+	case cache.Passed, cache.Skipped:
+		return 0
+	case cache.Failed, cache.Canceled:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Parse arguments with f. Unlike f.Parse, options may follow positional arguments.
+// Positional arguments are passed to addArg in order.
+func parseInterspersed(f *flag.FlagSet, args []string, addArg func(string)) error {
+	for {
+		if err := f.Parse(args); err != nil {
+			return err
+		}
+		if args = f.Args(); len(args) == 0 {
+			return nil
+		}
+		addArg(args[0])
+		args = args[1:]
+	}
+}
+
+func main() {
 	f := flag.NewFlagSet("citop", flag.ContinueOnError)
 	null := bytes.NewBuffer(nil)
 	f.SetOutput(null)
@@ -266,10 +745,25 @@ func main() {
 	versionFlag := f.Bool("version", false, "")
 	helpFlagShort := f.Bool("h", false, "")
 	helpFlag := f.Bool("help", false, "")
-	repoFlag := f.String("repository", defaultRepository, "")
-	repoFlagShort := f.String("r", defaultRepository, "")
+	targets := targetList{
+		defaultRepository: defaultRepository,
+		defaultCommit:     defaultCommit,
+	}
+	f.Var(&targets, "repository", "")
+	f.Var(&targets, "r", "")
+	var pipelineURLs stringList
+	f.Var(&pipelineURLs, "pipeline", "")
+	waitFlag := f.Bool("wait", false, "")
+	timeout := f.Duration("timeout", 0, "")
+	format := f.String("format", "", "")
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "status" {
+		*waitFlag = true
+		args = args[1:]
+	}
 
-	if err := f.Parse(os.Args[1:]); err != nil {
+	if err := parseInterspersed(f, args, targets.addCommit); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
@@ -285,18 +779,37 @@ func main() {
 		os.Exit(0)
 	}
 
-	sha := defaultCommit
-	if commits := f.Args(); len(commits) == 1 {
-		sha = commits[0]
-	} else if len(commits) > 1 {
-		fmt.Fprintln(os.Stderr, "Error: at most one commit can be specified")
+	if len(pipelineURLs) > 0 && len(targets.Targets()) > 1 {
+		fmt.Fprintln(os.Stderr, "Error: --pipeline cannot be used with more than one commit")
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
 
-	repo := *repoFlag
-	if repo == defaultRepository {
-		repo = *repoFlagShort
+	if *timeout != 0 && !*waitFlag {
+		fmt.Fprintln(os.Stderr, "Error: --timeout can only be used with the status command")
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	if *timeout < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --timeout must not be negative")
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	if *format != "" && !*waitFlag {
+		fmt.Fprintln(os.Stderr, "Error: --format can only be used with the status command")
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if *format == "" {
+		*format = "text"
+	}
+
+	// Errors of the status command must not be mistaken for failed pipelines
+	errorExitCode := 1
+	if *waitFlag {
+		errorExitCode = 2
 	}
 
 	paths := utils.XDGConfigLocations(path.Join(ConfDir, ConfFilename))
@@ -320,16 +833,65 @@ To lift these restrictions, create a configuration file containing your credenti
 		fmt.Fprintf(os.Stderr, msgFormat, paths[0])
 	default:
 		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(1)
+		os.Exit(errorExitCode)
 	}
 
 	ctx := context.Background()
 	sourceProviders, ciProviders, err := config.Providers.Providers(ctx)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, fmt.Sprintf("configuration error: %s", err.Error()))
-		os.Exit(1)
+		os.Exit(errorExitCode)
+	}
+	store, err := config.Cache.Store()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("cache error: %s", err.Error()))
+		os.Exit(errorExitCode)
+	}
+	notifiers, err := config.Notifications.Notifiers()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("configuration error: %s", err.Error()))
+		os.Exit(errorExitCode)
 	}
-	if err := tui.RunApplication(ctx, tcell.NewScreen, repo, sha, ciProviders, sourceProviders, time.Local, manualPage()); err != nil {
+	keys, err := tui.NewKeyBindings(config.Keys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("configuration error: keys: %s", err.Error()))
+		os.Exit(errorExitCode)
+	}
+	theme, err := config.Theme.Theme()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("configuration error: theme: %s", err.Error()))
+		os.Exit(errorExitCode)
+	}
+	columns, err := config.Table.TableColumns()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Sprintf("configuration error: table: %s", err.Error()))
+		os.Exit(errorExitCode)
+	}
+
+	if *waitFlag {
+		cancel := func() {}
+		if *timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+		}
+		state, err := tui.RunStatus(ctx, os.Stdout, *format, targets.Targets(), pipelineURLs, ciProviders, sourceProviders, store, time.Local)
+		// Deferred functions do not run on os.Exit
+		cancel()
+		switch err {
+		case nil:
+			os.Exit(statusExitCode(state))
+		case context.DeadlineExceeded:
+			fmt.Fprintf(os.Stderr, "error: pipelines still running after %s\n", *timeout)
+		default:
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		os.Exit(errorExitCode)
+	}
+
+	signal.Ignore(syscall.SIGINT)
+	// FIXME Do not ignore SIGTSTP/SIGCONT
+	signal.Ignore(syscall.SIGTSTP)
+
+	if err := tui.RunApplication(ctx, tcell.NewScreen, targets.Targets(), pipelineURLs, ciProviders, sourceProviders, store, notifiers, keys, theme, columns, time.Local, manualPage()); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
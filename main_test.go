@@ -1 +1,262 @@
 package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/notify"
+	"github.com/nbedos/citop/tui"
+	"github.com/pelletier/go-toml"
+)
+
+func TestTargetList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []cache.Target
+	}{
+		{
+			name: "no argument",
+			args: nil,
+			expected: []cache.Target{
+				{Repository: "/cwd", Ref: "HEAD"},
+			},
+		},
+		{
+			name: "commits of the default repository",
+			args: []string{"master", "0.1.0"},
+			expected: []cache.Target{
+				{Repository: "/cwd", Ref: "master"},
+				{Repository: "/cwd", Ref: "0.1.0"},
+			},
+		},
+		{
+			name: "repository without commit",
+			args: []string{"-r", "github.com/nbedos/citop"},
+			expected: []cache.Target{
+				{Repository: "github.com/nbedos/citop", Ref: "HEAD"},
+			},
+		},
+		{
+			name: "several repositories",
+			args: []string{"master", "-r", "github.com/nbedos/citop", "master", "feature", "--repository", "/repo", "-r", "/other"},
+			expected: []cache.Target{
+				{Repository: "/cwd", Ref: "master"},
+				{Repository: "github.com/nbedos/citop", Ref: "master"},
+				{Repository: "github.com/nbedos/citop", Ref: "feature"},
+				{Repository: "/repo", Ref: "HEAD"},
+				{Repository: "/other", Ref: "HEAD"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := flag.NewFlagSet("citop", flag.ContinueOnError)
+			f.SetOutput(bytes.NewBuffer(nil))
+			targets := targetList{
+				defaultRepository: "/cwd",
+				defaultCommit:     "HEAD",
+			}
+			f.Var(&targets, "repository", "")
+			f.Var(&targets, "r", "")
+
+			if err := parseInterspersed(f, testCase.args, targets.addCommit); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(testCase.expected, targets.Targets()); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestStatusExitCode(t *testing.T) {
+	testCases := []struct {
+		state cache.State
+		code  int
+	}{
+		{state: cache.Passed, code: 0},
+		{state: cache.Skipped, code: 0},
+		{state: cache.Failed, code: 1},
+		{state: cache.Canceled, code: 1},
+		{state: cache.Running, code: 2},
+		{state: cache.Manual, code: 2},
+		{state: cache.Unknown, code: 2},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.state), func(t *testing.T) {
+			if code := statusExitCode(testCase.state); code != testCase.code {
+				t.Fatalf("expected %d but got %d", testCase.code, code)
+			}
+		})
+	}
+}
+
+func TestNotificationsConfiguration_Notifiers(t *testing.T) {
+	t.Run("valid configuration", func(t *testing.T) {
+		tree, err := toml.Load(`
+[notifications.bell]
+enabled = true
+
+[[notifications.command]]
+command = ["notify-send", "citop", "{{.}}"]
+states = ["failed"]
+steps = true
+
+[[notifications.webhook]]
+url = "https://example.com/hook"
+`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c Configuration
+		if err := tree.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+
+		notifiers, err := c.Notifications.Notifiers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifiers) != 3 {
+			t.Fatalf("expected 3 notifiers but got %d", len(notifiers))
+		}
+	})
+
+	t.Run("default states", func(t *testing.T) {
+		filter, err := notificationFilter(nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := notify.Filter{States: defaultNotificationStates}
+		if diff := cmp.Diff(expected, filter); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		if _, err := notificationFilter([]string{"broken"}, false); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestConfiguration_Keys(t *testing.T) {
+	tree, err := toml.Load(`
+[keys]
+scroll-down = ["j", "Ctrl-N"]
+scroll-up = ["k", "Ctrl-P"]
+quit = ["x"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Configuration
+	if err := tree.Unmarshal(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"scroll-down": {"j", "Ctrl-N"},
+		"scroll-up":   {"k", "Ctrl-P"},
+		"quit":        {"x"},
+	}
+	if diff := cmp.Diff(expected, c.Keys); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if _, err := tui.NewKeyBindings(c.Keys); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfiguration_Theme(t *testing.T) {
+	t.Run("valid theme", func(t *testing.T) {
+		tree, err := toml.Load(`
+[theme]
+name = "colorblind"
+
+[theme.styles.status_failed]
+foreground = "#ff0000"
+attributes = ["bold", "underline"]
+
+[theme.styles.active_row]
+background = "238"
+`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c Configuration
+		if err := tree.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Theme.Name != "colorblind" {
+			t.Fatalf("expected theme %q but got %q", "colorblind", c.Theme.Name)
+		}
+		style := c.Theme.Styles["status_failed"]
+		if style.Foreground != "#ff0000" {
+			t.Fatalf("expected foreground %q but got %q", "#ff0000", style.Foreground)
+		}
+		if diff := cmp.Diff([]string{"bold", "underline"}, style.Attributes); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if _, err := c.Theme.Theme(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("invalid color", func(t *testing.T) {
+		tree, err := toml.Load(`
+[theme.styles.git_tag]
+foreground = "not a color"
+`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c Configuration
+		if err := tree.Unmarshal(&c); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Theme.Theme(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestConfiguration_Table(t *testing.T) {
+	tree, err := toml.Load(`
+[table]
+columns = ["state", "pipeline", "provider", "allow_failure", "name"]
+
+[table.max_widths]
+name = 40
+provider = 10
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Configuration
+	if err := tree.Unmarshal(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := TableConfiguration{
+		Columns: []string{"state", "pipeline", "provider", "allow_failure", "name"},
+		MaxWidths: map[string]int{
+			"name":     40,
+			"provider": 10,
+		},
+	}
+	if diff := cmp.Diff(expected, c.Table); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if _, err := c.Table.TableColumns(); err != nil {
+		t.Fatal(err)
+	}
+}
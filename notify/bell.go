@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"context"
+	"io"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Bell rings the bell of the terminal
+type Bell struct {
+	w io.Writer
+}
+
+// Return a notifier ringing the bell of the terminal by writing to w
+func NewBell(w io.Writer) Bell {
+	return Bell{w: w}
+}
+
+func (b Bell) Notify(ctx context.Context, t cache.StateTransition) error {
+	_, err := io.WriteString(b.w, "\a")
+	return err
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Command runs a local program such as notify-send for each state transition
+type Command struct {
+	argv []*template.Template
+}
+
+// Return a notifier running the command 'argv'. Each argument is a template of the text/template
+// package executed with the state transition as data, so that "{{.}}" is replaced by a
+// description of the transition and "{{.State}}" by the new state for example.
+func NewCommand(argv []string) (Command, error) {
+	if len(argv) == 0 {
+		return Command{}, errors.New("command must not be empty")
+	}
+
+	c := Command{
+		argv: make([]*template.Template, 0, len(argv)),
+	}
+	for _, arg := range argv {
+		tmpl, err := template.New("").Parse(arg)
+		if err != nil {
+			return Command{}, err
+		}
+		c.argv = append(c.argv, tmpl)
+	}
+
+	return c, nil
+}
+
+// Return the arguments of the command for the transition t
+func (c Command) args(t cache.StateTransition) ([]string, error) {
+	args := make([]string, 0, len(c.argv))
+	for _, tmpl := range c.argv {
+		builder := strings.Builder{}
+		if err := tmpl.Execute(&builder, t); err != nil {
+			return nil, err
+		}
+		args = append(args, builder.String())
+	}
+
+	return args, nil
+}
+
+func (c Command) Notify(ctx context.Context, t cache.StateTransition) error {
+	args, err := c.args(t)
+	if err != nil {
+		return err
+	}
+
+	if output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("command %q failed: %v (%s)", args[0], err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package notify tells the user about state transitions of pipelines while citop is running
+package notify
+
+import (
+	"context"
+
+	"github.com/nbedos/citop/cache"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, t cache.StateTransition) error
+}
+
+// Filter selects the state transitions worth a notification
+type Filter struct {
+	// States that trigger a notification when reached. All states do if States is empty.
+	States []cache.State
+	// Whether transitions of stages, jobs and tasks trigger notifications. If false, only
+	// transitions of pipelines do.
+	Steps bool
+}
+
+func (f Filter) Match(t cache.StateTransition) bool {
+	if len(t.StepIDs) > 0 && !f.Steps {
+		return false
+	}
+	if len(f.States) == 0 {
+		return true
+	}
+	for _, state := range f.States {
+		if state == t.State {
+			return true
+		}
+	}
+	return false
+}
+
+type filteredNotifier struct {
+	filter   Filter
+	notifier Notifier
+}
+
+// Return a notifier calling n only for state transitions matched by f
+func WithFilter(n Notifier, f Filter) Notifier {
+	return filteredNotifier{
+		filter:   f,
+		notifier: n,
+	}
+}
+
+func (n filteredNotifier) Notify(ctx context.Context, t cache.StateTransition) error {
+	if !n.filter.Match(t) {
+		return nil
+	}
+	return n.notifier.Notify(ctx, t)
+}
+
+// Pass each state transition received on 'transitions' to all notifiers until ctx is canceled.
+// Errors returned by notifiers are sent on errc.
+func Run(ctx context.Context, transitions <-chan cache.StateTransition, notifiers []Notifier, errc chan<- error) {
+	for {
+		select {
+		case t := <-transitions:
+			for _, n := range notifiers {
+				if err := n.Notify(ctx, t); err != nil {
+					select {
+					case errc <- err:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
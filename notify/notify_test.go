@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+var transition = cache.StateTransition{
+	Target:   cache.Target{Repository: "https://gitlab.com/nbedos/citop", Ref: "master"},
+	Pipeline: cache.PipelineKey{ProviderHost: "gitlab.com", ID: "1"},
+	Number:   "42",
+	StepIDs:  []string{"2"},
+	Name:     "tests",
+	Type:     cache.StepJob,
+	Previous: cache.Running,
+	State:    cache.Failed,
+	WebURL:   utils.NullString{Valid: true, String: "https://gitlab.com/nbedos/citop/-/jobs/2"},
+}
+
+func TestFilter_Match(t *testing.T) {
+	pipelineTransition := transition
+	pipelineTransition.StepIDs = nil
+
+	testCases := []struct {
+		name       string
+		filter     Filter
+		transition cache.StateTransition
+		match      bool
+	}{
+		{
+			name:       "empty filter matches pipelines",
+			filter:     Filter{},
+			transition: pipelineTransition,
+			match:      true,
+		},
+		{
+			name:       "steps are excluded by default",
+			filter:     Filter{},
+			transition: transition,
+			match:      false,
+		},
+		{
+			name:       "matching state",
+			filter:     Filter{States: []cache.State{cache.Passed, cache.Failed}, Steps: true},
+			transition: transition,
+			match:      true,
+		},
+		{
+			name:       "other state",
+			filter:     Filter{States: []cache.State{cache.Passed}, Steps: true},
+			transition: transition,
+			match:      false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if match := testCase.filter.Match(testCase.transition); match != testCase.match {
+				t.Fatalf("expected %v but got %v", testCase.match, match)
+			}
+		})
+	}
+}
+
+func TestBell_Notify(t *testing.T) {
+	buf := strings.Builder{}
+	if err := NewBell(&buf).Notify(context.Background(), transition); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "\a" {
+		t.Fatalf("expected %q but got %q", "\a", buf.String())
+	}
+}
+
+func TestCommand_args(t *testing.T) {
+	c, err := NewCommand([]string{"notify-send", "citop", "{{.}}", "{{.State}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := c.args(transition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"notify-send", "citop", "gitlab.com #42: tests running -> failed", "failed"}
+	if diff := cmp.Diff(expected, args); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	var payload map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/404" {
+			w.WriteHeader(404)
+			return
+		}
+		bs, err := ioutil.ReadAll(r.Body)
+		if err != nil || r.Method != "POST" {
+			w.WriteHeader(400)
+			return
+		}
+		if err := json.Unmarshal(bs, &payload); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+	defer ts.Close()
+
+	webhook := NewWebhook(ts.URL)
+	webhook.httpClient = ts.Client()
+	if err := webhook.Notify(context.Background(), transition); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"repository":     "https://gitlab.com/nbedos/citop",
+		"ref":            "master",
+		"provider":       "gitlab.com",
+		"pipeline":       "42",
+		"step_ids":       []interface{}{"2"},
+		"name":           "tests",
+		"type":           "job",
+		"previous_state": "running",
+		"state":          "failed",
+		"web_url":        "https://gitlab.com/nbedos/citop/-/jobs/2",
+		"message":        "gitlab.com #42: tests running -> failed",
+	}
+	if diff := cmp.Diff(expected, payload); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("error status", func(t *testing.T) {
+		webhook := NewWebhook(ts.URL + "/404")
+		webhook.httpClient = ts.Client()
+		if err := webhook.Notify(context.Background(), transition); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("endpoint that never responds", func(t *testing.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer ts.Close()
+		defer close(done)
+
+		webhook := NewWebhook(ts.URL)
+		if webhook.httpClient.Timeout <= 0 {
+			t.Fatal("expected the HTTP client to have a timeout")
+		}
+		webhook.httpClient.Timeout = 100 * time.Millisecond
+
+		errc := make(chan error)
+		go func() {
+			errc <- webhook.Notify(context.Background(), transition)
+		}()
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request did not time out")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Webhook sends an HTTP POST request with a JSON description of each state transition
+type Webhook struct {
+	url        string
+	httpClient *http.Client
+}
+
+type webhookPayload struct {
+	Repository    string   `json:"repository"`
+	Ref           string   `json:"ref"`
+	Provider      string   `json:"provider"`
+	Pipeline      string   `json:"pipeline"`
+	StepIDs       []string `json:"step_ids"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	PreviousState string   `json:"previous_state"`
+	State         string   `json:"state"`
+	WebURL        *string  `json:"web_url"`
+	Message       string   `json:"message"`
+}
+
+func NewWebhook(url string) Webhook {
+	return Webhook{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w Webhook) Notify(ctx context.Context, t cache.StateTransition) error {
+	payload := webhookPayload{
+		Repository:    t.Target.Repository,
+		Ref:           t.Target.Ref,
+		Provider:      t.Pipeline.ProviderHost,
+		Pipeline:      t.Number,
+		StepIDs:       t.StepIDs,
+		Name:          t.Name,
+		Type:          t.Type.String(),
+		PreviousState: string(t.Previous),
+		State:         string(t.State),
+		Message:       t.String(),
+	}
+	if t.WebURL.Valid {
+		payload.WebURL = &t.WebURL.String
+	}
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: unexpected status %q", w.url, resp.Status)
+	}
+
+	return nil
+}
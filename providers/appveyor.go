@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -108,10 +109,27 @@ func (c AppVeyorClient) getJSON(ctx context.Context, u url.URL, v interface{}) e
 }
 
 func (c AppVeyorClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", u.String(), nil)
+	return c.request(ctx, "GET", u, nil)
+}
+
+// Send an HTTP request and return the body of the response. If v is not nil, it is sent as the
+// JSON body of the request.
+func (c AppVeyorClient) request(ctx context.Context, method string, u url.URL, v interface{}) (io.ReadCloser, error) {
+	var reqBody io.Reader
+	if v != nil {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if v != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 	if c.token != "" {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
 	}
@@ -144,12 +162,18 @@ func (c AppVeyorClient) get(ctx context.Context, u url.URL) (io.ReadCloser, erro
 	return resp.Body, err
 }
 
-func (c AppVeyorClient) fetchPipeline(ctx context.Context, owner string, repoName string, id int) (cache.Pipeline, error) {
-	// We only have the build ID and need a build object. We have to query two endpoints:
-	// 		1. /projects/owner/repoName/history with startBuildId = id gives us a build object with
-	//      an empty job list but with a version number
-	//      2. /projects/owner/repoName/build/<version> using the version number from the last call
-	//      gives us a build with a complete job list
+type appVeyorHistory struct {
+	Project struct {
+		ID    int    `json:"projectId"`
+		Owner string `json:"accountName"`
+		Name  string `json:"name"`
+	}
+	Builds []appVeyorBuild `json:"builds"`
+}
+
+// Return the history of the project owner/repoName starting at the build 'id'. The only build
+// of the history is the build 'id' but without its list of jobs.
+func (c AppVeyorClient) buildHistory(ctx context.Context, owner string, repoName string, id int) (appVeyorHistory, error) {
 	history := c.url
 	historyFormat := "/projects/%s/%s/history"
 	history.Path += fmt.Sprintf(historyFormat, owner, repoName)
@@ -159,23 +183,30 @@ func (c AppVeyorClient) fetchPipeline(ctx context.Context, owner string, repoNam
 	params.Add("startBuildId", strconv.Itoa(id+1))
 	history.RawQuery = params.Encode()
 
-	var b struct {
-		Project struct {
-			ID    int    `json:"projectId"`
-			Owner string `json:"accountName"`
-			Name  string `json:"name"`
-		}
-		Builds []appVeyorBuild `json:"builds"`
-	}
+	var b appVeyorHistory
 	if err := c.getJSON(ctx, history, &b); err != nil {
-		return cache.Pipeline{}, err
+		return b, err
 	}
 
 	if len(b.Builds) != 1 {
-		return cache.Pipeline{}, fmt.Errorf("found no build with id %d", id)
+		return b, fmt.Errorf("found no build with id %d", id)
 	}
 	if b.Builds[0].ID != id {
-		return cache.Pipeline{}, fmt.Errorf("expected build #%d but got %d", id, b.Builds[0].ID)
+		return b, fmt.Errorf("expected build #%d but got %d", id, b.Builds[0].ID)
+	}
+
+	return b, nil
+}
+
+func (c AppVeyorClient) fetchPipeline(ctx context.Context, owner string, repoName string, id int) (cache.Pipeline, error) {
+	// We only have the build ID and need a build object. We have to query two endpoints:
+	// 		1. /projects/owner/repoName/history with startBuildId = id gives us a build object with
+	//      an empty job list but with a version number
+	//      2. /projects/owner/repoName/build/<version> using the version number from the last call
+	//      gives us a build with a complete job list
+	b, err := c.buildHistory(ctx, owner, repoName, id)
+	if err != nil {
+		return cache.Pipeline{}, err
 	}
 	version := b.Builds[0].Version
 
@@ -358,3 +389,75 @@ func (j appVeyorJob) toCacheStep(id string, buildURL string) (cache.Step, error)
 
 	return step, nil
 }
+
+// Only builds, which are shown as pipelines, can be acted upon with the API of AppVeyor
+func (c AppVeyorClient) Restart(ctx context.Context, step cache.Step) error {
+	if step.Type != cache.StepPipeline {
+		return cache.ErrNotSupported
+	}
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.url
+	endpoint.Path += "/builds"
+	body := map[string]interface{}{
+		"buildId":         id,
+		"reRunIncomplete": false,
+	}
+	r, err := c.request(ctx, "PUT", endpoint, body)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+func (c AppVeyorClient) Cancel(ctx context.Context, step cache.Step) error {
+	if step.Type != cache.StepPipeline {
+		return cache.ErrNotSupported
+	}
+	owner, repoName, id, err := parseAppVeyorURL(step.WebURL.String)
+	if err != nil {
+		return err
+	}
+	// Builds are canceled by version, not by ID
+	b, err := c.buildHistory(ctx, owner, repoName, id)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.url
+	pathFormat := "/builds/%s/%s/%s"
+	version := b.Builds[0].Version
+	endpoint.Path += fmt.Sprintf(pathFormat, owner, repoName, version)
+	endpoint.RawPath += fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repoName),
+		url.PathEscape(version))
+	r, err := c.request(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// Start a new build of the project named after the repository 'repo' in the account named after
+// the owner of the repository, which is the default naming used by AppVeyor
+func (c AppVeyorClient) Trigger(ctx context.Context, repo string, ref string) error {
+	_, owner, repoName, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil {
+		return cache.ErrUnknownRepositoryURL
+	}
+
+	endpoint := c.url
+	endpoint.Path += "/builds"
+	body := map[string]string{
+		"accountName": owner,
+		"projectSlug": repoName,
+		"branch":      ref,
+	}
+	r, err := c.request(ctx, "POST", endpoint, body)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -254,3 +255,82 @@ func TestAppVeyorClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestAppVeyorClient_Actions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body)))
+
+		if r.Method == "GET" && r.URL.Path == "/api/projects/nbedos/citop/history" {
+			bs, err := ioutil.ReadFile("test_data/appveyor/appveyor_history_29070120.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(bs); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	tsu, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsu.Path += "/api"
+	tsu.RawPath += "/api"
+
+	client := AppVeyorClient{
+		url:         *tsu,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(time.Millisecond),
+		token:       "token",
+		provider: cache.Provider{
+			ID:   "id",
+			Name: "name",
+		},
+	}
+
+	pipeline := cache.Step{
+		ID:   "29070120",
+		Type: cache.StepPipeline,
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: "https://ci.appveyor.com/project/nbedos/citop/builds/29070120",
+		},
+	}
+	ctx := context.Background()
+	if err := client.Restart(ctx, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(ctx, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Trigger(ctx, "https://github.com/nbedos/citop", "feature/doc"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`PUT /api/builds {"buildId":29070120,"reRunIncomplete":false}`,
+		"GET /api/projects/nbedos/citop/history?recordsNumber=1&startBuildId=29070121",
+		"DELETE /api/builds/nbedos/citop/1.0.22",
+		`POST /api/builds {"accountName":"nbedos","branch":"feature/doc","projectSlug":"citop"}`,
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("job", func(t *testing.T) {
+		job := cache.Step{ID: "jobId", Type: cache.StepJob}
+		if err := client.Restart(ctx, job); err != cache.ErrNotSupported {
+			t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+		}
+		if err := client.Cancel(ctx, job); err != cache.ErrNotSupported {
+			t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+		}
+	})
+}
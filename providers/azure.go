@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -29,14 +30,18 @@ type AzurePipelinesClient struct {
 	mux         *sync.Mutex
 }
 
-var azureURL = url.URL{
+var AzureURL = url.URL{
 	Scheme: "https",
 	Host:   "dev.azure.com",
 }
 
-func NewAzurePipelinesClient(id string, name string, token string, rateLimit time.Duration) AzurePipelinesClient {
+func NewAzurePipelinesClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) AzurePipelinesClient {
+	// Azure DevOps Server is usually located under a path prefix (e.g. "/tfs")
+	URL.Path = strings.TrimSuffix(URL.Path, "/")
+	URL.RawPath = ""
+
 	return AzurePipelinesClient{
-		baseURL:     azureURL,
+		baseURL:     URL,
 		httpClient:  &http.Client{Timeout: 10 * time.Second},
 		rateLimiter: time.Tick(rateLimit),
 		token:       token,
@@ -63,6 +68,7 @@ func (c AzurePipelinesClient) Name() string {
 
 func (c AzurePipelinesClient) parseAzureWebURL(s string) (string, string, string, error) {
 	// https://dev.azure.com/nicolasbedos/5190ee7b-d826-445e-b19e-6dc098be0436/_build/results?buildId=16
+	// https://azure.example.com/tfs/DefaultCollection/citop/_build/results?buildId=16
 	u, err := url.Parse(s)
 	if err != nil {
 		return "", "", "", err
@@ -71,7 +77,12 @@ func (c AzurePipelinesClient) parseAzureWebURL(s string) (string, string, string
 		return "", "", "", cache.ErrUnknownPipelineURL
 	}
 
-	cs := strings.Split(u.EscapedPath(), "/")
+	prefix := c.baseURL.EscapedPath()
+	if prefix != "" && !strings.HasPrefix(u.EscapedPath(), prefix+"/") {
+		return "", "", "", cache.ErrUnknownPipelineURL
+	}
+
+	cs := strings.Split(strings.TrimPrefix(u.EscapedPath(), prefix), "/")
 	if len(cs) < 5 || cs[3] != "_build" || cs[4] != "results" {
 		return "", "", "", cache.ErrUnknownPipelineURL
 	}
@@ -122,6 +133,50 @@ func (c AzurePipelinesClient) Log(ctx context.Context, step cache.Step) (string,
 	return string(log), nil
 }
 
+// Write to w the lines of the log of 'step' that follow the first 'offset' lines. Only the new
+// lines are requested from Azure DevOps. While the step is active, the last line is left out
+// until it is terminated by a newline character.
+func (c AzurePipelinesClient) StreamLog(ctx context.Context, step cache.Step, offset int, w io.Writer) (int, error) {
+	if step.Log.Key == "" {
+		return offset, cache.ErrNoLogHere
+	}
+
+	u, err := url.Parse(step.Log.Key)
+	if err != nil {
+		return offset, err
+	}
+	params := u.Query()
+	// Line numbers start at 1
+	params.Set("startLine", strconv.Itoa(offset+1))
+	u.RawQuery = params.Encode()
+
+	r, err := c.get(ctx, *u)
+	if err != nil {
+		return offset, err
+	}
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return offset, err
+	}
+
+	end := len(content)
+	if step.State.IsActive() {
+		// Keep incomplete lines for the next call
+		end = strings.LastIndex(string(content), "\n") + 1
+	}
+	if _, err := w.Write(content[:end]); err != nil {
+		return offset, err
+	}
+
+	offset += strings.Count(string(content[:end]), "\n")
+	if end > 0 && content[end-1] != '\n' {
+		offset++
+	}
+	return offset, nil
+}
+
 type azureBuild struct {
 	ID            int    `json:"id"`
 	Number        string `json:"buildNumber"`
@@ -427,6 +482,12 @@ func (c AzurePipelinesClient) getJSON(ctx context.Context, u url.URL, v interfac
 }
 
 func (c AzurePipelinesClient) get(ctx context.Context, u url.URL) (io.ReadCloser, error) {
+	return c.request(ctx, "GET", u, nil)
+}
+
+// Send an HTTP request and return the body of the response. If v is not nil, it is sent as the
+// JSON body of the request.
+func (c AzurePipelinesClient) request(ctx context.Context, method string, u url.URL, v interface{}) (io.ReadCloser, error) {
 	if u.Hostname() != c.baseURL.Hostname() {
 		return nil, fmt.Errorf("expected URL host to be %q but got %q", u.Hostname(), c.baseURL.Hostname())
 	}
@@ -434,11 +495,22 @@ func (c AzurePipelinesClient) get(ctx context.Context, u url.URL) (io.ReadCloser
 	params.Add("api-version", c.version)
 	u.RawQuery = params.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	var reqBody io.Reader
+	if v != nil {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
-	req.WithContext(ctx)
+	if v != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req = req.WithContext(ctx)
 
 	if c.token != "" {
 		req.SetBasicAuth("", c.token)
@@ -501,3 +573,38 @@ func fromAzureState(result string, status string) cache.State {
 
 	return cache.Unknown
 }
+
+// Update the build 'step' with the body v. Only builds, which are shown as pipelines, can be
+// acted upon with the API of Azure Pipelines.
+func (c AzurePipelinesClient) updateBuild(ctx context.Context, step cache.Step, params url.Values, v interface{}) error {
+	if step.Type != cache.StepPipeline {
+		return cache.ErrNotSupported
+	}
+	owner, repo, buildID, err := c.parseAzureWebURL(step.WebURL.String)
+	if err != nil {
+		return err
+	}
+
+	u := c.baseURL
+	u.Path += fmt.Sprintf("/%s/%s/_apis/build/builds/%s", owner, repo, buildID)
+	u.RawQuery = params.Encode()
+	r, err := c.request(ctx, "PATCH", u, v)
+	if err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+// Run again the failed jobs of the build 'step'
+func (c AzurePipelinesClient) Restart(ctx context.Context, step cache.Step) error {
+	params := url.Values{}
+	params.Add("retry", "true")
+	return c.updateBuild(ctx, step, params, struct{}{})
+}
+
+func (c AzurePipelinesClient) Cancel(ctx context.Context, step cache.Step) error {
+	body := map[string]string{
+		"status": "cancelling",
+	}
+	return c.updateBuild(ctx, step, url.Values{}, body)
+}
@@ -26,6 +26,8 @@ func Setup() (AzurePipelinesClient, func(), error) {
 			filename = "azure_build_16.json"
 		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/Timeline":
 			filename = "azure_build_16_timeline.json"
+		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/logs/1234" && r.URL.Query().Get("startLine") == "2":
+			filename = "azure_build_16_job_log_2.txt"
 		case r.Method == "GET" && r.URL.Path == "/owner/repo/_apis/build/builds/16/logs/1234":
 			filename = "azure_build_16_job_log.txt"
 		default:
@@ -270,13 +272,40 @@ func setWebURLs(p cache.Pipeline, webURL utils.NullString) cache.Pipeline {
 
 func TestAzurePipelinesClient_parseAzureWebURL(t *testing.T) {
 	webURL := "https://dev.azure.com/owner/repo/_build/results?buildId=16"
-	client := NewAzurePipelinesClient("azure", "azure", "", time.Second)
+	client := NewAzurePipelinesClient("azure", "azure", "", AzureURL, time.Second)
 	owner, repo, id, err := client.parseAzureWebURL(webURL)
 	if err != nil || owner != "owner" || repo != "repo" || id != "16" {
 		t.Fatalf("invalid result")
 	}
 }
 
+func TestAzurePipelinesClient_parseAzureWebURL_server(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "azure.example.com", Path: "/tfs/"}
+	client := NewAzurePipelinesClient("azure", "azure", "", u, time.Second)
+
+	t.Run("build of the server", func(t *testing.T) {
+		webURL := "https://azure.example.com/tfs/DefaultCollection/repo/_build/results?buildId=16"
+		owner, repo, id, err := client.parseAzureWebURL(webURL)
+		if err != nil || owner != "DefaultCollection" || repo != "repo" || id != "16" {
+			t.Fatalf("invalid result")
+		}
+	})
+
+	t.Run("build of another host", func(t *testing.T) {
+		webURL := "https://dev.azure.com/owner/repo/_build/results?buildId=16"
+		if _, _, _, err := client.parseAzureWebURL(webURL); err != cache.ErrUnknownPipelineURL {
+			t.Fatalf("expected error %v but got %v", cache.ErrUnknownPipelineURL, err)
+		}
+	})
+
+	t.Run("build outside of the path prefix", func(t *testing.T) {
+		webURL := "https://azure.example.com/DefaultCollection/repo/_build/results?buildId=16"
+		if _, _, _, err := client.parseAzureWebURL(webURL); err != cache.ErrUnknownPipelineURL {
+			t.Fatalf("expected error %v but got %v", cache.ErrUnknownPipelineURL, err)
+		}
+	})
+}
+
 func TestAzurePipelinesClient_fetchBuild(t *testing.T) {
 	client, teardown, err := Setup()
 	if err != nil {
@@ -350,3 +379,136 @@ func TestAzurePipelinesClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestAzurePipelinesClient_StreamLog(t *testing.T) {
+	client, teardown, err := Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	logURL := url.URL{
+		Scheme: client.baseURL.Scheme,
+		Host:   client.baseURL.Host,
+		Path:   "/owner/repo/_apis/build/builds/16/logs/1234",
+	}
+	job := cache.Step{
+		ID:    "1234",
+		Type:  cache.StepJob,
+		State: cache.Running,
+		Log: cache.Log{
+			Key: logURL.String(),
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		state          cache.State
+		offset         int
+		expectedOffset int
+		expectedLog    string
+	}{
+		{
+			name:           "start of the log",
+			state:          cache.Running,
+			offset:         0,
+			expectedOffset: 1,
+			expectedLog:    "log\n",
+		},
+		{
+			// The last line is incomplete and must be left out
+			name:           "incomplete last line",
+			state:          cache.Running,
+			offset:         1,
+			expectedOffset: 3,
+			expectedLog:    "second line\nthird line\n",
+		},
+		{
+			// The log is complete so its last line is written even without newline character
+			name:           "finished step",
+			state:          cache.Passed,
+			offset:         1,
+			expectedOffset: 4,
+			expectedLog:    "second line\nthird line\nfour",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			job.State = testCase.state
+			buf := strings.Builder{}
+			offset, err := client.StreamLog(context.Background(), job, testCase.offset, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != testCase.expectedOffset {
+				t.Fatalf("expected offset %d but got %d", testCase.expectedOffset, offset)
+			}
+			if diff := cmp.Diff(testCase.expectedLog, buf.String()); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestAzurePipelinesClient_Actions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body))
+	}))
+	defer ts.Close()
+
+	baseURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := AzurePipelinesClient{
+		baseURL:     *baseURL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		provider: cache.Provider{
+			ID:   "azure",
+			Name: "azure",
+		},
+		version: "5.1",
+		mux:     &sync.Mutex{},
+	}
+
+	pipeline := cache.Step{
+		ID:   "16",
+		Type: cache.StepPipeline,
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: ts.URL + "/owner/repo/_build/results?buildId=16",
+		},
+	}
+	ctx := context.Background()
+	if err := client.Restart(ctx, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(ctx, pipeline); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"PATCH /owner/repo/_apis/build/builds/16?api-version=5.1&retry=true {}",
+		`PATCH /owner/repo/_apis/build/builds/16?api-version=5.1 {"status":"cancelling"}`,
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("job", func(t *testing.T) {
+		job := cache.Step{ID: "1234", Type: cache.StepJob}
+		if err := client.Restart(ctx, job); err != cache.ErrNotSupported {
+			t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+		}
+		if err := client.Cancel(ctx, job); err != cache.ErrNotSupported {
+			t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+		}
+	})
+}
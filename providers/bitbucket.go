@@ -0,0 +1,624 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// BitbucketClient is a source provider for Bitbucket Cloud and Bitbucket Server. It is also a
+// CI provider for Bitbucket Pipelines which is only available on Bitbucket Cloud.
+type BitbucketClient struct {
+	baseURL     url.URL
+	webURL      url.URL
+	server      bool
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	username    string
+	token       string
+	provider    cache.Provider
+}
+
+var BitbucketCloudURL = url.URL{Scheme: "https", Host: "bitbucket.org"}
+
+var bitbucketCloudAPIURL = url.URL{
+	Scheme: "https",
+	Host:   "api.bitbucket.org",
+	Path:   "/2.0",
+}
+
+func NewBitbucketClient(id string, name string, URL url.URL, username string, token string, rateLimit time.Duration) BitbucketClient {
+	client := BitbucketClient{
+		baseURL:     bitbucketCloudAPIURL,
+		webURL:      URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		username:    username,
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+
+	// Bitbucket Server exposes its REST API on the same host as its web interface
+	if URL.Hostname() != BitbucketCloudURL.Hostname() {
+		client.server = true
+		client.baseURL = URL
+		client.baseURL.Path = strings.TrimSuffix(client.baseURL.Path, "/")
+	}
+
+	return client
+}
+
+func (c BitbucketClient) ID() string {
+	return c.provider.ID
+}
+
+func (c BitbucketClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c BitbucketClient) Name() string {
+	return c.provider.Name
+}
+
+// Rate-limited HTTP GET request with authentication
+func (c BitbucketClient) get(ctx context.Context, resourceURL string) (*bytes.Buffer, error) {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.token)
+	case c.token != "":
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return body, nil
+}
+
+func (c BitbucketClient) getJSON(ctx context.Context, resourceURL string, v interface{}) error {
+	body, err := c.get(ctx, resourceURL)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// Call f on each page of a paginated resource of the Bitbucket Cloud API
+func (c BitbucketClient) forEachCloudPage(ctx context.Context, resourceURL string, f func(values json.RawMessage) error) error {
+	for resourceURL != "" {
+		var page struct {
+			Values json.RawMessage `json:"values"`
+			Next   string          `json:"next"`
+		}
+		if err := c.getJSON(ctx, resourceURL, &page); err != nil {
+			return err
+		}
+		if err := f(page.Values); err != nil {
+			return err
+		}
+		resourceURL = page.Next
+	}
+
+	return nil
+}
+
+// Call f on each page of a paginated resource of the Bitbucket Server API
+func (c BitbucketClient) forEachServerPage(ctx context.Context, resourceURL url.URL, f func(values json.RawMessage) error) error {
+	for start := 0; ; {
+		params := resourceURL.Query()
+		params.Set("start", strconv.Itoa(start))
+		resourceURL.RawQuery = params.Encode()
+
+		var page struct {
+			Values        json.RawMessage `json:"values"`
+			IsLastPage    bool            `json:"isLastPage"`
+			NextPageStart int             `json:"nextPageStart"`
+		}
+		if err := c.getJSON(ctx, resourceURL.String(), &page); err != nil {
+			return err
+		}
+		if err := f(page.Values); err != nil {
+			return err
+		}
+		if page.IsLastPage || page.NextPageStart <= start {
+			return nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// Extract owner and name of repository from its URL. For Bitbucket Server, the owner is the key
+// of the project containing the repository.
+func (c BitbucketClient) parseRepositoryURL(u string) (string, string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(u)
+	if err != nil || host != c.webURL.Hostname() {
+		return "", "", cache.ErrUnknownRepositoryURL
+	}
+
+	if c.server {
+		// Bitbucket Server URL formats:
+		//    https://example.com/projects/KEY/repos/repo/browse
+		//    https://example.com/users/user/repos/repo/browse
+		//    https://example.com/scm/key/repo.git
+		//    ssh://git@example.com:7999/key/repo.git
+		v, err := url.Parse(strings.TrimSuffix(u, ".git"))
+		if err != nil || v.Host == "" {
+			return owner, repo, nil
+		}
+		cs := strings.FieldsFunc(v.Path, func(c rune) bool { return c == '/' })
+		switch {
+		case len(cs) >= 4 && cs[0] == "projects" && cs[2] == "repos":
+			return cs[1], cs[3], nil
+		case len(cs) >= 4 && cs[0] == "users" && cs[2] == "repos":
+			return "~" + cs[1], cs[3], nil
+		case len(cs) >= 3 && cs[0] == "scm":
+			return cs[1], cs[2], nil
+		}
+	}
+
+	return owner, repo, nil
+}
+
+func (c BitbucketClient) repositoryEndpoint(owner string, repo string) url.URL {
+	endpoint := c.baseURL
+	pathFormat := "/repositories/%s/%s"
+	if c.server {
+		pathFormat = "/rest/api/1.0/projects/%s/repos/%s"
+	}
+	endpoint.Path += fmt.Sprintf(pathFormat, owner, repo)
+	endpoint.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repo))
+
+	return endpoint
+}
+
+func (c BitbucketClient) Commit(ctx context.Context, repo string, ref string) (cache.Commit, error) {
+	owner, name, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return cache.Commit{}, err
+	}
+
+	if c.server {
+		return c.serverCommit(ctx, owner, name, ref)
+	}
+	return c.cloudCommit(ctx, owner, name, ref)
+}
+
+func (c BitbucketClient) cloudCommit(ctx context.Context, owner string, repo string, ref string) (cache.Commit, error) {
+	endpoint := c.repositoryEndpoint(owner, repo)
+	endpoint.Path += "/commit/" + ref
+	endpoint.RawPath += "/commit/" + url.PathEscape(ref)
+
+	var bitbucketCommit struct {
+		Hash    string `json:"hash"`
+		Date    string `json:"date"`
+		Message string `json:"message"`
+		Author  struct {
+			Raw string `json:"raw"`
+		} `json:"author"`
+	}
+	if err := c.getJSON(ctx, endpoint.String(), &bitbucketCommit); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return cache.Commit{}, cache.ErrUnknownGitReference
+		}
+		return cache.Commit{}, err
+	}
+
+	commit := cache.Commit{
+		Sha:     bitbucketCommit.Hash,
+		Author:  bitbucketCommit.Author.Raw,
+		Message: bitbucketCommit.Message,
+	}
+	var err error
+	if commit.Date, err = time.Parse(time.RFC3339, bitbucketCommit.Date); err != nil {
+		return cache.Commit{}, err
+	}
+
+	// Find references pointing to the commit
+	for _, refType := range []string{"branches", "tags"} {
+		refsURL := c.repositoryEndpoint(owner, repo)
+		refsURL.Path += "/refs/" + refType
+		refsURL.RawPath += "/refs/" + refType
+		params := refsURL.Query()
+		params.Add("q", fmt.Sprintf("target.hash=%q", commit.Sha))
+		refsURL.RawQuery = params.Encode()
+
+		err := c.forEachCloudPage(ctx, refsURL.String(), func(values json.RawMessage) error {
+			var refs []struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(values, &refs); err != nil {
+				return err
+			}
+			for _, r := range refs {
+				if refType == "tags" {
+					commit.Tags = append(commit.Tags, r.Name)
+				} else {
+					commit.Branches = append(commit.Branches, r.Name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return cache.Commit{}, err
+		}
+	}
+
+	return commit, nil
+}
+
+func (c BitbucketClient) serverCommit(ctx context.Context, owner string, repo string, ref string) (cache.Commit, error) {
+	endpoint := c.repositoryEndpoint(owner, repo)
+	endpoint.Path += "/commits/" + ref
+	endpoint.RawPath += "/commits/" + url.PathEscape(ref)
+
+	var bitbucketCommit struct {
+		ID     string `json:"id"`
+		Author struct {
+			Name  string `json:"name"`
+			Email string `json:"emailAddress"`
+		} `json:"author"`
+		AuthorTimestamp int64  `json:"authorTimestamp"`
+		Message         string `json:"message"`
+	}
+	if err := c.getJSON(ctx, endpoint.String(), &bitbucketCommit); err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return cache.Commit{}, cache.ErrUnknownGitReference
+		}
+		return cache.Commit{}, err
+	}
+
+	commit := cache.Commit{
+		Sha:     bitbucketCommit.ID,
+		Author:  fmt.Sprintf("%s <%s>", bitbucketCommit.Author.Name, bitbucketCommit.Author.Email),
+		Date:    time.Unix(0, bitbucketCommit.AuthorTimestamp*int64(time.Millisecond)).UTC(),
+		Message: bitbucketCommit.Message,
+	}
+
+	// Bitbucket Server can list branches containing a commit but offers no such thing for tags
+	branchesURL := c.baseURL
+	pathFormat := "/rest/branch-utils/1.0/projects/%s/repos/%s/branches/info/%s"
+	branchesURL.Path += fmt.Sprintf(pathFormat, owner, repo, commit.Sha)
+	branchesURL.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repo), commit.Sha)
+	err := c.forEachServerPage(ctx, branchesURL, func(values json.RawMessage) error {
+		var branches []struct {
+			DisplayID    string `json:"displayId"`
+			LatestCommit string `json:"latestCommit"`
+		}
+		if err := json.Unmarshal(values, &branches); err != nil {
+			return err
+		}
+		for _, branch := range branches {
+			if branch.LatestCommit == commit.Sha {
+				commit.Branches = append(commit.Branches, branch.DisplayID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cache.Commit{}, err
+	}
+
+	return commit, nil
+}
+
+func (c BitbucketClient) RefStatuses(ctx context.Context, u string, ref string, sha string) ([]string, error) {
+	owner, repo, err := c.parseRepositoryURL(u)
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		sha = ref
+	}
+
+	urls := make([]string, 0)
+	addURLs := func(values json.RawMessage) error {
+		var statuses []struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(values, &statuses); err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.URL != "" {
+				urls = append(urls, status.URL)
+			}
+		}
+		return nil
+	}
+
+	if c.server {
+		statusesURL := c.baseURL
+		statusesURL.Path += fmt.Sprintf("/rest/build-status/1.0/commits/%s", sha)
+		statusesURL.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf("/rest/build-status/1.0/commits/%s", url.PathEscape(sha))
+		err = c.forEachServerPage(ctx, statusesURL, addURLs)
+	} else {
+		statusesURL := c.repositoryEndpoint(owner, repo)
+		statusesURL.Path += fmt.Sprintf("/commit/%s/statuses", sha)
+		statusesURL.RawPath += fmt.Sprintf("/commit/%s/statuses", url.PathEscape(sha))
+		err = c.forEachCloudPage(ctx, statusesURL.String(), addURLs)
+	}
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return nil, cache.ErrUnknownRepositoryURL
+		}
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// Extract owner, repository and build number from the web URL of a Bitbucket pipeline
+func (c BitbucketClient) parsePipelineURL(u string) (string, string, string, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if c.server || v.Hostname() != c.webURL.Hostname() {
+		return "", "", "", cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//    https://bitbucket.org/owner/repo/addon/pipelines/home#!/results/42
+	//    https://bitbucket.org/owner/repo/pipelines/results/42
+	//    https://bitbucket.org/owner/repo/pipelines/results/42/steps/{uuid}
+	cs := strings.FieldsFunc(v.EscapedPath(), func(c rune) bool { return c == '/' })
+	var number string
+	switch {
+	case len(cs) == 5 && cs[2] == "addon" && cs[3] == "pipelines" && cs[4] == "home":
+		fragment := strings.FieldsFunc(v.Fragment, func(c rune) bool { return c == '/' })
+		if len(fragment) < 3 || fragment[0] != "!" || fragment[1] != "results" {
+			return "", "", "", cache.ErrUnknownPipelineURL
+		}
+		number = fragment[2]
+	case len(cs) >= 5 && cs[2] == "pipelines" && cs[3] == "results":
+		number = cs[4]
+	default:
+		return "", "", "", cache.ErrUnknownPipelineURL
+	}
+
+	if _, err := strconv.Atoi(number); err != nil {
+		return "", "", "", cache.ErrUnknownPipelineURL
+	}
+
+	return cs[0], cs[1], number, nil
+}
+
+func (c BitbucketClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	owner, repo, number, err := c.parsePipelineURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, owner, repo, number)
+}
+
+func (c BitbucketClient) fetchPipeline(ctx context.Context, owner string, repo string, number string) (cache.Pipeline, error) {
+	endpoint := c.repositoryEndpoint(owner, repo)
+	endpoint.Path += "/pipelines/" + number
+	endpoint.RawPath += "/pipelines/" + url.PathEscape(number)
+
+	var pipeline bitbucketPipeline
+	if err := c.getJSON(ctx, endpoint.String(), &pipeline); err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	stepsURL := c.repositoryEndpoint(owner, repo)
+	stepsURL.Path += fmt.Sprintf("/pipelines/%s/steps/", pipeline.UUID)
+	stepsURL.RawPath += fmt.Sprintf("/pipelines/%s/steps/", url.PathEscape(pipeline.UUID))
+	steps := make([]bitbucketStep, 0)
+	err := c.forEachCloudPage(ctx, stepsURL.String(), func(values json.RawMessage) error {
+		var pageSteps []bitbucketStep
+		if err := json.Unmarshal(values, &pageSteps); err != nil {
+			return err
+		}
+		steps = append(steps, pageSteps...)
+		return nil
+	})
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	webURL := c.webURL
+	webURL.Path += fmt.Sprintf("/%s/%s/addon/pipelines/home", owner, repo)
+	webURL.Fragment = fmt.Sprintf("!/results/%d", pipeline.BuildNumber)
+
+	return pipeline.toPipeline(steps, webURL.String(), stepsURL.String())
+}
+
+func (c BitbucketClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	body, err := c.get(ctx, step.Log.Key)
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			// Steps that did not run have no log
+			return "", nil
+		}
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+func fromBitbucketState(state bitbucketState) cache.State {
+	switch strings.ToUpper(state.Name) {
+	case "PENDING", "READY", "PARSING":
+		return cache.Pending
+	case "IN_PROGRESS", "RUNNING":
+		if stage := strings.ToUpper(state.Stage.Name); stage == "PAUSED" || stage == "HALTED" {
+			return cache.Manual
+		}
+		return cache.Running
+	case "PAUSED", "HALTED":
+		return cache.Manual
+	case "NOT_RUN":
+		return cache.Skipped
+	case "COMPLETED":
+		switch strings.ToUpper(state.Result.Name) {
+		case "SUCCESSFUL":
+			return cache.Passed
+		case "FAILED", "ERROR", "EXPIRED":
+			return cache.Failed
+		case "STOPPED":
+			return cache.Canceled
+		case "NOT_RUN", "SKIPPED":
+			return cache.Skipped
+		}
+	}
+
+	return cache.Unknown
+}
+
+type bitbucketState struct {
+	Name   string `json:"name"`
+	Result struct {
+		Name string `json:"name"`
+	} `json:"result"`
+	Stage struct {
+		Name string `json:"name"`
+	} `json:"stage"`
+}
+
+type bitbucketPipeline struct {
+	UUID        string         `json:"uuid"`
+	BuildNumber int            `json:"build_number"`
+	CreatedOn   string         `json:"created_on"`
+	CompletedOn string         `json:"completed_on"`
+	State       bitbucketState `json:"state"`
+	Target      struct {
+		RefType string `json:"ref_type"`
+		RefName string `json:"ref_name"`
+		Commit  struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+	} `json:"target"`
+}
+
+func (p bitbucketPipeline) toPipeline(steps []bitbucketStep, webURL string, stepsURL string) (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(p.BuildNumber),
+		GitReference: cache.GitReference{
+			SHA:   p.Target.Commit.Hash,
+			Ref:   p.Target.RefName,
+			IsTag: p.Target.RefType == "tag",
+		},
+		Step: cache.Step{
+			ID:    p.UUID,
+			Type:  cache.StepPipeline,
+			State: fromBitbucketState(p.State),
+			WebURL: utils.NullString{
+				String: webURL,
+				Valid:  true,
+			},
+		},
+	}
+
+	var err error
+	if pipeline.CreatedAt, err = utils.NullTimeFromString(p.CreatedOn); err != nil {
+		return pipeline, err
+	}
+	if pipeline.FinishedAt, err = utils.NullTimeFromString(p.CompletedOn); err != nil {
+		return pipeline, err
+	}
+
+	for _, s := range steps {
+		step, err := s.toStep(webURL, stepsURL)
+		if err != nil {
+			return pipeline, err
+		}
+		pipeline.StartedAt = utils.MinNullTime(pipeline.StartedAt, step.StartedAt)
+		pipeline.Children = append(pipeline.Children, step)
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	updatedAt := utils.MaxNullTime(pipeline.FinishedAt, pipeline.StartedAt, pipeline.CreatedAt)
+	for _, step := range pipeline.Children {
+		updatedAt = utils.MaxNullTime(updatedAt, step.StartedAt, step.FinishedAt)
+	}
+	if !updatedAt.Valid {
+		return pipeline, errors.New("updatedAt attribute cannot be null")
+	}
+	pipeline.UpdatedAt = updatedAt.Time
+
+	return pipeline, nil
+}
+
+type bitbucketStep struct {
+	UUID        string         `json:"uuid"`
+	Name        string         `json:"name"`
+	StartedOn   string         `json:"started_on"`
+	CompletedOn string         `json:"completed_on"`
+	State       bitbucketState `json:"state"`
+}
+
+func (s bitbucketStep) toStep(webURL string, stepsURL string) (cache.Step, error) {
+	step := cache.Step{
+		ID:    s.UUID,
+		Name:  s.Name,
+		Type:  cache.StepJob,
+		State: fromBitbucketState(s.State),
+		Log: cache.Log{
+			Key: fmt.Sprintf("%s%s/log", stepsURL, url.PathEscape(s.UUID)),
+		},
+		WebURL: utils.NullString{
+			String: webURL,
+			Valid:  true,
+		},
+	}
+
+	var err error
+	if step.StartedAt, err = utils.NullTimeFromString(s.StartedOn); err != nil {
+		return step, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(s.CompletedOn); err != nil {
+		return step, err
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step, nil
+}
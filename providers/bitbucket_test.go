@@ -0,0 +1,392 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+const (
+	bitbucketSha          = "c2c1b3ee7a2a2f2f57a3c8ad0dd1b8f29d4e4b6a"
+	bitbucketPipelineUUID = "{d3b7a1a2-5c45-4a4f-93d3-6d1fc1b3a2e7}"
+)
+
+func setupBitbucketTestServer(t *testing.T, server bool) (BitbucketClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		repoPath := "/2.0/repositories/nbedos/citop"
+		switch {
+		case r.URL.Path == repoPath+"/commit/master":
+			filename = "bitbucket_commit.json"
+		case r.URL.Path == repoPath+"/refs/branches" && r.URL.Query().Get("q") == fmt.Sprintf("target.hash=%q", bitbucketSha):
+			filename = "bitbucket_branches.json"
+		case r.URL.Path == repoPath+"/refs/tags" && r.URL.Query().Get("q") == fmt.Sprintf("target.hash=%q", bitbucketSha):
+			filename = "bitbucket_tags.json"
+		case r.URL.Path == repoPath+"/commit/"+bitbucketSha+"/statuses" && r.URL.Query().Get("page") == "":
+			filename = "bitbucket_statuses.json"
+		case r.URL.Path == repoPath+"/commit/"+bitbucketSha+"/statuses" && r.URL.Query().Get("page") == "2":
+			filename = "bitbucket_statuses_2.json"
+		case r.URL.Path == repoPath+"/pipelines/12":
+			filename = "bitbucket_pipeline.json"
+		case r.URL.Path == repoPath+"/pipelines/"+bitbucketPipelineUUID+"/steps/":
+			filename = "bitbucket_steps.json"
+		case r.URL.Path == repoPath+"/pipelines/"+bitbucketPipelineUUID+"/steps/{9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4}/log":
+			filename = "bitbucket_log"
+		case r.URL.Path == "/rest/build-status/1.0/commits/"+bitbucketSha:
+			filename = "bitbucket_server_statuses.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "bitbucket", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		// Rewrite URLs in the file to match the scheme and host of the query
+		s := strings.Replace(string(bs), "https://example.com", "http://"+r.Host, -1)
+		if _, err := fmt.Fprint(w, s); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	webURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL := *webURL
+	if !server {
+		baseURL.Path = "/2.0"
+	}
+
+	client := BitbucketClient{
+		baseURL:     baseURL,
+		webURL:      *webURL,
+		server:      server,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+		provider: cache.Provider{
+			ID:   "bitbucket",
+			Name: "bitbucket",
+		},
+	}
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestBitbucketClient_parsePipelineURL(t *testing.T) {
+	client := NewBitbucketClient("bitbucket", "bitbucket", BitbucketCloudURL, "", "", time.Millisecond)
+
+	urls := []string{
+		"https://bitbucket.org/nbedos/citop/addon/pipelines/home#!/results/12",
+		"https://bitbucket.org/nbedos/citop/pipelines/results/12",
+		"https://bitbucket.org/nbedos/citop/pipelines/results/12/steps/%7B9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4%7D",
+	}
+	for _, u := range urls {
+		t.Run(u, func(t *testing.T) {
+			owner, repo, number, err := client.parsePipelineURL(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != "nbedos" || repo != "citop" || number != "12" {
+				t.Fatalf("unexpected result: %q, %q, %q", owner, repo, number)
+			}
+		})
+	}
+
+	invalidURLs := []string{
+		"https://bitbucket.org/nbedos/citop/addon/pipelines/home",
+		"https://bitbucket.org/nbedos/citop/pipelines/results/abc",
+		"https://github.com/nbedos/citop/pipelines/results/12",
+	}
+	for _, u := range invalidURLs {
+		t.Run(u, func(t *testing.T) {
+			if _, _, _, err := client.parsePipelineURL(u); err != cache.ErrUnknownPipelineURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownPipelineURL, err)
+			}
+		})
+	}
+}
+
+func TestBitbucketClient_parseRepositoryURL(t *testing.T) {
+	serverURL := url.URL{Scheme: "https", Host: "bitbucket.example.com"}
+	client := NewBitbucketClient("bitbucket", "bitbucket", serverURL, "", "", time.Millisecond)
+
+	testCases := []struct {
+		url   string
+		owner string
+		repo  string
+	}{
+		{
+			url:   "https://bitbucket.example.com/projects/KEY/repos/citop/browse",
+			owner: "KEY",
+			repo:  "citop",
+		},
+		{
+			url:   "https://bitbucket.example.com/users/nbedos/repos/citop/browse",
+			owner: "~nbedos",
+			repo:  "citop",
+		},
+		{
+			url:   "https://bitbucket.example.com/scm/key/citop.git",
+			owner: "key",
+			repo:  "citop",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			owner, repo, err := client.parseRepositoryURL(testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != testCase.owner || repo != testCase.repo {
+				t.Fatalf("expected %q, %q but got %q, %q", testCase.owner, testCase.repo, owner, repo)
+			}
+		})
+	}
+
+	if _, _, err := client.parseRepositoryURL("https://bitbucket.org/nbedos/citop"); err != cache.ErrUnknownRepositoryURL {
+		t.Fatalf("expected %v but got %v", cache.ErrUnknownRepositoryURL, err)
+	}
+}
+
+func TestBitbucketClient_Commit(t *testing.T) {
+	t.Run("existing reference", func(t *testing.T) {
+		client, testURL, teardown := setupBitbucketTestServer(t, false)
+		defer teardown()
+
+		commit, err := client.Commit(context.Background(), testURL+"/nbedos/citop", "master")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedCommit := cache.Commit{
+			Sha:      bitbucketSha,
+			Author:   "nbedos <nicolas.bedos@gmail.com>",
+			Date:     time.Date(2020, 1, 5, 15, 10, 32, 0, time.UTC),
+			Message:  "Add Bitbucket integration\n",
+			Branches: []string{"master"},
+			Tags:     []string{"0.2.0"},
+		}
+
+		if diff := cmp.Diff(expectedCommit, commit); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("non existing commit", func(t *testing.T) {
+		client, testURL, teardown := setupBitbucketTestServer(t, false)
+		defer teardown()
+
+		_, err := client.Commit(context.Background(), testURL+"/nbedos/citop", "0000000")
+		if err != cache.ErrUnknownGitReference {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestBitbucketClient_RefStatuses(t *testing.T) {
+	t.Run("Bitbucket Cloud", func(t *testing.T) {
+		client, testURL, teardown := setupBitbucketTestServer(t, false)
+		defer teardown()
+
+		statuses, err := client.RefStatuses(context.Background(), testURL+"/nbedos/citop", "", bitbucketSha)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedStatuses := []string{
+			"https://bitbucket.org/nbedos/citop/addon/pipelines/home#!/results/12",
+			"https://travis-ci.org/nbedos/citop/builds/615087280",
+		}
+		if diff := cmp.Diff(expectedStatuses, statuses); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("Bitbucket Server", func(t *testing.T) {
+		client, testURL, teardown := setupBitbucketTestServer(t, true)
+		defer teardown()
+
+		statuses, err := client.RefStatuses(context.Background(), testURL+"/projects/KEY/repos/citop/browse", "", bitbucketSha)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedStatuses := []string{
+			"https://jenkins.example.com/job/repo/job/master/42/",
+		}
+		if diff := cmp.Diff(expectedStatuses, statuses); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestBitbucketClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupBitbucketTestServer(t, false)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL+"/nbedos/citop/addon/pipelines/home#!/results/12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webURL := utils.NullString{
+		String: testURL + "/nbedos/citop/addon/pipelines/home#!/results/12",
+		Valid:  true,
+	}
+	stepsURL := testURL + "/2.0/repositories/nbedos/citop/pipelines/%7Bd3b7a1a2-5c45-4a4f-93d3-6d1fc1b3a2e7%7D/steps/"
+
+	expectedPipeline := cache.Pipeline{
+		Number: "12",
+		GitReference: cache.GitReference{
+			SHA:   bitbucketSha,
+			Ref:   "master",
+			IsTag: false,
+		},
+		Step: cache.Step{
+			ID:    bitbucketPipelineUUID,
+			Type:  cache.StepPipeline,
+			State: cache.Failed,
+			CreatedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 5, 15, 11, 1, 856000000, time.UTC),
+			},
+			StartedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 5, 15, 11, 10, 201000000, time.UTC),
+			},
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 5, 15, 13, 10, 105000000, time.UTC),
+			},
+			UpdatedAt: time.Date(2020, 1, 5, 15, 13, 10, 105000000, time.UTC),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: time.Minute + 59*time.Second + 904000000*time.Nanosecond,
+			},
+			WebURL: webURL,
+			Children: []cache.Step{
+				{
+					ID:    "{5f4a2b0e-3bd4-4bd1-a3cd-1bc4d6e1a9f0}",
+					Name:  "Build",
+					Type:  cache.StepJob,
+					State: cache.Passed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 5, 15, 11, 10, 201000000, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 5, 15, 12, 3, 417000000, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 53*time.Second + 216000000*time.Nanosecond,
+					},
+					WebURL: webURL,
+					Log: cache.Log{
+						Key: stepsURL + "%7B5f4a2b0e-3bd4-4bd1-a3cd-1bc4d6e1a9f0%7D/log",
+					},
+				},
+				{
+					ID:    "{9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4}",
+					Name:  "Test",
+					Type:  cache.StepJob,
+					State: cache.Failed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 5, 15, 12, 4, 13000000, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 5, 15, 13, 9, 880000000, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute + 5*time.Second + 867000000*time.Nanosecond,
+					},
+					WebURL: webURL,
+					Log: cache.Log{
+						Key: stepsURL + "%7B9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4%7D/log",
+					},
+				},
+				{
+					ID:     "{a8e3f0c1-6d29-4f57-8c1a-7b5e3d9c2f10}",
+					Name:   "Deploy",
+					Type:   cache.StepJob,
+					State:  cache.Pending,
+					WebURL: webURL,
+					Log: cache.Log{
+						Key: stepsURL + "%7Ba8e3f0c1-6d29-4f57-8c1a-7b5e3d9c2f10%7D/log",
+					},
+				},
+			},
+		},
+	}
+
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBitbucketClient_Log(t *testing.T) {
+	client, testURL, teardown := setupBitbucketTestServer(t, false)
+	defer teardown()
+
+	stepsURL := testURL + "/2.0/repositories/nbedos/citop/pipelines/%7Bd3b7a1a2-5c45-4a4f-93d3-6d1fc1b3a2e7%7D/steps/"
+
+	t.Run("step with log", func(t *testing.T) {
+		step := cache.Step{
+			ID:   "{9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4}",
+			Type: cache.StepJob,
+			Log: cache.Log{
+				Key: stepsURL + "%7B9e0c1d3a-8f62-4b11-9a8e-2e7c0e2bd3c4%7D/log",
+			},
+		}
+
+		log, err := client.Log(context.Background(), step)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff("+ go test ./...\nFAIL\n", log); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("step that did not run", func(t *testing.T) {
+		step := cache.Step{
+			ID:   "{a8e3f0c1-6d29-4f57-8c1a-7b5e3d9c2f10}",
+			Type: cache.StepJob,
+			Log: cache.Log{
+				Key: stepsURL + "%7Ba8e3f0c1-6d29-4f57-8c1a-7b5e3d9c2f10%7D/log",
+			},
+		}
+
+		log, err := client.Log(context.Background(), step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log != "" {
+			t.Fatalf("expected empty log but got %q", log)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+type BuildkiteClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+}
+
+var BuildkiteURL = url.URL{
+	Scheme: "https",
+	Host:   "api.buildkite.com",
+	Path:   "/v2",
+}
+
+func NewBuildkiteClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) BuildkiteClient {
+	return BuildkiteClient{
+		baseURL:     URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c BuildkiteClient) ID() string {
+	return c.provider.ID
+}
+
+func (c BuildkiteClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c BuildkiteClient) Name() string {
+	return c.provider.Name
+}
+
+// Rate-limited HTTP GET request with authentication
+func (c BuildkiteClient) get(ctx context.Context, resourceURL string) (*bytes.Buffer, error) {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errorBody struct {
+			Message string `json:"message"`
+		}
+		var message string
+		if jsonErr := json.Unmarshal(body.Bytes(), &errorBody); jsonErr == nil {
+			message = errorBody.Message
+		}
+
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: message,
+		}
+	}
+
+	return body, nil
+}
+
+// Extract organization, pipeline and build number from the web URL of a build
+func (c BuildkiteClient) parseBuildURL(u string) (string, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if v.Hostname() != strings.TrimPrefix(c.baseURL.Hostname(), "api.") {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//    https://buildkite.com/organization/pipeline/builds/42
+	//    https://buildkite.com/organization/pipeline/builds/42#job-uuid
+	cs := strings.FieldsFunc(v.EscapedPath(), func(c rune) bool { return c == '/' })
+	if len(cs) < 4 || cs[2] != "builds" {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	number, err := strconv.Atoi(cs[3])
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	return cs[0], cs[1], number, nil
+}
+
+func (c BuildkiteClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	organization, pipeline, number, err := c.parseBuildURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, organization, pipeline, number)
+}
+
+func (c BuildkiteClient) fetchPipeline(ctx context.Context, organization string, pipeline string, number int) (cache.Pipeline, error) {
+	endpoint := c.baseURL
+	pathFormat := "/organizations/%s/pipelines/%s/builds/%d"
+	endpoint.Path += fmt.Sprintf(pathFormat, organization, pipeline, number)
+	endpoint.RawPath += fmt.Sprintf(pathFormat, url.PathEscape(organization), url.PathEscape(pipeline), number)
+
+	body, err := c.get(ctx, endpoint.String())
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	var build buildkiteBuild
+	if err := json.Unmarshal(body.Bytes(), &build); err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return build.toPipeline()
+}
+
+func (c BuildkiteClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	body, err := c.get(ctx, step.Log.Key)
+	if err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+func fromBuildkiteState(state string) cache.State {
+	switch state {
+	case "pending", "waiting", "limiting", "limited", "scheduled", "assigned", "accepted":
+		return cache.Pending
+	case "running", "failing", "canceling", "timing_out":
+		return cache.Running
+	case "passed", "unblocked":
+		return cache.Passed
+	case "failed", "timed_out", "broken", "expired", "waiting_failed", "blocked_failed", "unblocked_failed":
+		return cache.Failed
+	case "canceled":
+		return cache.Canceled
+	case "skipped", "not_run":
+		return cache.Skipped
+	case "blocked":
+		return cache.Manual
+	default:
+		return cache.Unknown
+	}
+}
+
+type buildkiteJob struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	State      string `json:"state"`
+	WebURL     string `json:"web_url"`
+	RawLogURL  string `json:"raw_log_url"`
+	SoftFailed bool   `json:"soft_failed"`
+	CreatedAt  string `json:"created_at"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+func (j buildkiteJob) toStep() (cache.Step, error) {
+	step := cache.Step{
+		ID:           j.ID,
+		Name:         j.Name,
+		Type:         cache.StepJob,
+		State:        fromBuildkiteState(j.State),
+		AllowFailure: j.SoftFailed,
+		WebURL: utils.NullString{
+			String: j.WebURL,
+			Valid:  j.WebURL != "",
+		},
+		Log: cache.Log{
+			Key: j.RawLogURL,
+		},
+	}
+	if step.Name == "" {
+		step.Name = j.Label
+	}
+
+	var err error
+	if step.CreatedAt, err = utils.NullTimeFromString(j.CreatedAt); err != nil {
+		return step, err
+	}
+	if step.StartedAt, err = utils.NullTimeFromString(j.StartedAt); err != nil {
+		return step, err
+	}
+	if step.FinishedAt, err = utils.NullTimeFromString(j.FinishedAt); err != nil {
+		return step, err
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	return step, nil
+}
+
+type buildkiteBuild struct {
+	ID         string         `json:"id"`
+	Number     int            `json:"number"`
+	WebURL     string         `json:"web_url"`
+	State      string         `json:"state"`
+	Commit     string         `json:"commit"`
+	Branch     string         `json:"branch"`
+	Message    string         `json:"message"`
+	CreatedAt  string         `json:"created_at"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at"`
+	Jobs       []buildkiteJob `json:"jobs"`
+	Pipeline   struct {
+		Name string `json:"name"`
+	} `json:"pipeline"`
+}
+
+func (b buildkiteBuild) toPipeline() (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(b.Number),
+		GitReference: cache.GitReference{
+			SHA: b.Commit,
+			Ref: b.Branch,
+		},
+		Step: cache.Step{
+			ID:    b.ID,
+			Name:  b.Pipeline.Name,
+			Type:  cache.StepPipeline,
+			State: fromBuildkiteState(b.State),
+			WebURL: utils.NullString{
+				String: b.WebURL,
+				Valid:  b.WebURL != "",
+			},
+		},
+	}
+
+	var err error
+	if pipeline.CreatedAt, err = utils.NullTimeFromString(b.CreatedAt); err != nil {
+		return pipeline, err
+	}
+	if pipeline.StartedAt, err = utils.NullTimeFromString(b.StartedAt); err != nil {
+		return pipeline, err
+	}
+	if pipeline.FinishedAt, err = utils.NullTimeFromString(b.FinishedAt); err != nil {
+		return pipeline, err
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	updatedAt := utils.MaxNullTime(pipeline.FinishedAt, pipeline.StartedAt, pipeline.CreatedAt)
+	for _, job := range b.Jobs {
+		// Wait steps only separate groups of jobs and hold no information
+		if job.Type == "waiter" {
+			continue
+		}
+		step, err := job.toStep()
+		if err != nil {
+			return pipeline, err
+		}
+		updatedAt = utils.MaxNullTime(updatedAt, step.StartedAt, step.FinishedAt)
+		pipeline.Children = append(pipeline.Children, step)
+	}
+	if !updatedAt.Valid {
+		return pipeline, errors.New("updatedAt attribute cannot be null")
+	}
+	pipeline.UpdatedAt = updatedAt.Time
+
+	return pipeline, nil
+}
@@ -0,0 +1,243 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func setupBuildkiteTestServer(t *testing.T) (BuildkiteClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			return
+		}
+
+		filename := ""
+		switch r.URL.Path {
+		case "/v2/organizations/nbedos/pipelines/citop/builds/42":
+			filename = "buildkite_build.json"
+		case "/v2/organizations/nbedos/pipelines/citop/builds/42/jobs/b63254c0-3271-4a98-8270-7cfbd6c2f14e/log.txt":
+			filename = "buildkite_log.txt"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "buildkite", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		// Rewrite URLs in the file to match the scheme and host of the query
+		s := strings.Replace(string(bs), "https://example.com", "http://"+r.Host, -1)
+		if _, err := fmt.Fprint(w, s); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	u, err := url.Parse(ts.URL + "/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBuildkiteClient("buildkite", "buildkite", "token", *u, time.Millisecond)
+	client.httpClient = ts.Client()
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestBuildkiteClient_parseBuildURL(t *testing.T) {
+	client := NewBuildkiteClient("buildkite", "buildkite", "", BuildkiteURL, time.Millisecond)
+
+	testCases := []struct {
+		url          string
+		organization string
+		pipeline     string
+		number       int
+		err          error
+	}{
+		{
+			url:          "https://buildkite.com/nbedos/citop/builds/42",
+			organization: "nbedos",
+			pipeline:     "citop",
+			number:       42,
+		},
+		{
+			url:          "https://buildkite.com/nbedos/citop/builds/42#b63254c0-3271-4a98-8270-7cfbd6c2f14e",
+			organization: "nbedos",
+			pipeline:     "citop",
+			number:       42,
+		},
+		{
+			url: "https://buildkite.com/nbedos/citop/settings",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://buildkite.com/nbedos/citop/builds/latest",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://circleci.com/nbedos/citop/builds/42",
+			err: cache.ErrUnknownPipelineURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			organization, pipeline, number, err := client.parseBuildURL(testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if organization != testCase.organization || pipeline != testCase.pipeline || number != testCase.number {
+				t.Fatalf("unexpected result: %q, %q, %d", organization, pipeline, number)
+			}
+		})
+	}
+}
+
+func TestFromBuildkiteState(t *testing.T) {
+	testCases := []struct {
+		state    string
+		expected cache.State
+	}{
+		{"scheduled", cache.Pending},
+		{"running", cache.Running},
+		{"failing", cache.Running},
+		{"passed", cache.Passed},
+		{"failed", cache.Failed},
+		{"timed_out", cache.Failed},
+		{"canceled", cache.Canceled},
+		{"not_run", cache.Skipped},
+		{"blocked", cache.Manual},
+		{"", cache.Unknown},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.state, func(t *testing.T) {
+			if state := fromBuildkiteState(testCase.state); state != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, state)
+			}
+		})
+	}
+}
+
+func TestBuildkiteClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupBuildkiteTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL+"/nbedos/citop/builds/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPipeline := cache.Pipeline{
+		Number: "42",
+		GitReference: cache.GitReference{
+			SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+			Ref: "master",
+		},
+		Step: cache.Step{
+			ID:    "f62a1b4d-10f9-4790-bc1c-e2c3a0c80983",
+			Name:  "citop",
+			Type:  cache.StepPipeline,
+			State: cache.Failed,
+			CreatedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 12, 29, 994000000, time.UTC),
+			},
+			StartedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 12, 35, 471000000, time.UTC),
+			},
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 13, 42, 990000000, time.UTC),
+			},
+			UpdatedAt: time.Date(2020, 1, 7, 10, 13, 42, 990000000, time.UTC),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: time.Minute + 7*time.Second + 519*time.Millisecond,
+			},
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: "https://buildkite.com/nbedos/citop/builds/42",
+			},
+			Children: []cache.Step{
+				{
+					ID:    "b63254c0-3271-4a98-8270-7cfbd6c2f14e",
+					Name:  ":go: test",
+					Type:  cache.StepJob,
+					State: cache.Failed,
+					CreatedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 12, 30, 119000000, time.UTC),
+					},
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 12, 35, 471000000, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 13, 42, 836000000, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute + 7*time.Second + 365*time.Millisecond,
+					},
+					WebURL: utils.NullString{
+						Valid:  true,
+						String: "https://buildkite.com/nbedos/citop/builds/42#b63254c0-3271-4a98-8270-7cfbd6c2f14e",
+					},
+					Log: cache.Log{
+						Key: testURL + "/v2/organizations/nbedos/pipelines/citop/builds/42/jobs/b63254c0-3271-4a98-8270-7cfbd6c2f14e/log.txt",
+					},
+				},
+				{
+					ID:    "8c1f6b4e-0f1a-4a2e-8b07-4a4d1b93e4f0",
+					Name:  ":rocket: Deploy",
+					Type:  cache.StepJob,
+					State: cache.Manual,
+				},
+			},
+		},
+	}
+
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestBuildkiteClient_Log(t *testing.T) {
+	client, testURL, teardown := setupBuildkiteTestServer(t)
+	defer teardown()
+
+	step := cache.Step{
+		Log: cache.Log{
+			Key: testURL + "/v2/organizations/nbedos/pipelines/citop/builds/42/jobs/b63254c0-3271-4a98-8270-7cfbd6c2f14e/log.txt",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("--- FAIL: TestCommit (0.00s)\nFAIL\n", log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
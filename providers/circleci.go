@@ -24,11 +24,20 @@ type CircleCIClient struct {
 	provider    cache.Provider
 }
 
+const circleCIAPIPath = "/api/v1.1"
+
 var CircleCIURL = url.URL{
-	Scheme:  "https",
-	Host:    "circleci.com",
-	Path:    "api/v1.1",
-	RawPath: "api/v1.1",
+	Scheme: "https",
+	Host:   "circleci.com",
+	Path:   circleCIAPIPath,
+}
+
+// Return the URL of the API of the CircleCI Server instance located at URL
+func CircleCIServerAPIURL(URL url.URL) url.URL {
+	URL.Path = strings.TrimSuffix(URL.Path, "/") + circleCIAPIPath
+	URL.RawPath = ""
+
+	return URL
 }
 
 func NewCircleCIClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) CircleCIClient {
@@ -45,18 +54,22 @@ func NewCircleCIClient(id string, name string, token string, URL url.URL, rateLi
 }
 
 func (c CircleCIClient) get(ctx context.Context, resourceURL url.URL) (*bytes.Buffer, error) {
+	return c.request(ctx, "GET", resourceURL)
+}
+
+func (c CircleCIClient) request(ctx context.Context, method string, resourceURL url.URL) (*bytes.Buffer, error) {
 	if c.token != "" {
 		parameters := resourceURL.Query()
 		parameters.Add("circle-token", c.token)
 		resourceURL.RawQuery = parameters.Encode()
 	}
 
-	req, err := http.NewRequest("GET", resourceURL.String(), nil)
+	req, err := http.NewRequest(method, resourceURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Accept", "application/json")
-	req.WithContext(ctx)
+	req = req.WithContext(ctx)
 
 	select {
 	case <-c.rateLimiter:
@@ -143,8 +156,15 @@ func parseCircleCIWebURL(baseURL *url.URL, u string) (string, string, int, error
 		return "", "", 0, cache.ErrUnknownPipelineURL
 	}
 
+	// The web interface of a CircleCI Server instance may be located under a path prefix, in
+	// which case the API is located at the same prefix followed by circleCIAPIPath
+	prefix := strings.TrimSuffix(baseURL.EscapedPath(), circleCIAPIPath)
+	if prefix != "" && !strings.HasPrefix(v.EscapedPath(), prefix+"/") {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
 	// URL format: https://circleci.com/gh/nbedos/citop/36
-	cs := strings.Split(v.EscapedPath(), "/")
+	cs := strings.Split(strings.TrimPrefix(v.EscapedPath(), prefix), "/")
 	if len(cs) < 5 {
 		return "", "", 0, cache.ErrUnknownPipelineURL
 	}
@@ -204,6 +224,46 @@ func (c CircleCIClient) Log(ctx context.Context, step cache.Step) (string, error
 	return builder.String(), err
 }
 
+// Send a POST request to the endpoint 'action' of the build 'step'. Only builds, which are shown
+// as pipelines, can be acted upon with the API of CircleCI.
+func (c CircleCIClient) buildAction(ctx context.Context, step cache.Step, action string) error {
+	if step.Type != cache.StepPipeline {
+		return cache.ErrNotSupported
+	}
+	owner, repo, id, err := parseCircleCIWebURL(&c.baseURL, step.WebURL.String)
+	if err != nil {
+		return err
+	}
+
+	endpoint := c.projectEndpoint(owner, repo)
+	endpoint.Path += fmt.Sprintf("/%d/%s", id, action)
+	_, err = c.request(ctx, "POST", endpoint)
+	return err
+}
+
+func (c CircleCIClient) Restart(ctx context.Context, step cache.Step) error {
+	return c.buildAction(ctx, step, "retry")
+}
+
+func (c CircleCIClient) Cancel(ctx context.Context, step cache.Step) error {
+	return c.buildAction(ctx, step, "cancel")
+}
+
+func (c CircleCIClient) Trigger(ctx context.Context, repo string, ref string) error {
+	_, owner, name, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil {
+		return cache.ErrUnknownRepositoryURL
+	}
+
+	// Slashes of the branch name must be escaped
+	endpoint := c.projectEndpoint(owner, name)
+	escapedPath := endpoint.EscapedPath()
+	endpoint.Path += fmt.Sprintf("/tree/%s", ref)
+	endpoint.RawPath = escapedPath + fmt.Sprintf("/tree/%s", url.PathEscape(ref))
+	_, err = c.request(ctx, "POST", endpoint)
+	return err
+}
+
 func (c CircleCIClient) fetchPipeline(ctx context.Context, projectEndpoint url.URL, buildID int) (cache.Pipeline, error) {
 	var err error
 	var pipeline cache.Pipeline
@@ -67,6 +67,52 @@ func TestParseCircleCIWebURL(t *testing.T) {
 	}
 }
 
+func TestParseCircleCIWebURL_server(t *testing.T) {
+	baseURL := CircleCIServerAPIURL(url.URL{
+		Scheme: "https",
+		Host:   "circleci.example.com",
+		Path:   "/circleci/",
+	})
+	if expected := "https://circleci.example.com/circleci/api/v1.1"; baseURL.String() != expected {
+		t.Fatalf("expected %q but got %q", expected, baseURL.String())
+	}
+
+	testCases := []struct {
+		url    string
+		owner  string
+		repo   string
+		number int
+		err    error
+	}{
+		{
+			url:    "https://circleci.example.com/circleci/gh/nbedos/citop/36",
+			owner:  "nbedos",
+			repo:   "citop",
+			number: 36,
+		},
+		{
+			url: "https://circleci.example.com/gh/nbedos/citop/36",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://circleci.com/gh/nbedos/citop/36",
+			err: cache.ErrUnknownPipelineURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			owner, repo, number, err := parseCircleCIWebURL(&baseURL, testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if owner != testCase.owner || repo != testCase.repo || number != testCase.number {
+				t.Fatalf("unexpected result: %q, %q, %d", owner, repo, number)
+			}
+		})
+	}
+}
+
 func TestCircleCIClient_BuildFromURL(t *testing.T) {
 	httpClient, testURL, teardown := setupCircleCITestServer(t)
 	defer teardown()
@@ -177,3 +223,52 @@ func TestCircleCIClient_Log(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestCircleCIClient_actions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.EscapedPath()))
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := CircleCIClient{
+		baseURL:     *URL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+	ctx := context.Background()
+
+	step := cache.Step{
+		ID:   "36",
+		Type: cache.StepPipeline,
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: ts.URL + "/gh/nbedos/citop/36",
+		},
+	}
+	if err := client.Restart(ctx, step); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(ctx, step); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Trigger(ctx, "https://github.com/nbedos/citop", "feature/x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Restart(ctx, cache.Step{ID: "0.1", Type: cache.StepTask}); err != cache.ErrNotSupported {
+		t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+	}
+
+	expected := []string{
+		"POST /project/gh/nbedos/citop/36/retry",
+		"POST /project/gh/nbedos/citop/36/cancel",
+		"POST /project/gh/nbedos/citop/tree/feature%2Fx",
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
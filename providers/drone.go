@@ -0,0 +1,334 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+type DroneClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+}
+
+func NewDroneClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) DroneClient {
+	URL.Path = strings.TrimSuffix(URL.Path, "/")
+	URL.RawPath = strings.TrimSuffix(URL.RawPath, "/")
+
+	return DroneClient{
+		baseURL:     URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c DroneClient) ID() string {
+	return c.provider.ID
+}
+
+func (c DroneClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c DroneClient) Name() string {
+	return c.provider.Name
+}
+
+// Rate-limited HTTP GET request with authentication
+func (c DroneClient) get(ctx context.Context, resourceURL string) (*bytes.Buffer, error) {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: strings.TrimSpace(body.String()),
+		}
+	}
+
+	return body, nil
+}
+
+// Extract owner, repository and build number from the web URL of a build
+func (c DroneClient) parseBuildURL(u string) (string, string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if v.Hostname() != c.baseURL.Hostname() {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//    https://drone.example.com/owner/repo/42
+	//    https://drone.example.com/owner/repo/42/1/2
+	cs := strings.FieldsFunc(v.EscapedPath(), func(c rune) bool { return c == '/' })
+	if len(cs) < 3 {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	number, err := strconv.Atoi(cs[2])
+	if err != nil {
+		return "", "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	return cs[0], cs[1], number, nil
+}
+
+func (c DroneClient) repositoryEndpoint(owner string, repo string) url.URL {
+	endpoint := c.baseURL
+	pathFormat := "/api/repos/%s/%s"
+	endpoint.Path += fmt.Sprintf(pathFormat, owner, repo)
+	endpoint.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repo))
+
+	return endpoint
+}
+
+func (c DroneClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	owner, repo, number, err := c.parseBuildURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, owner, repo, number)
+}
+
+func (c DroneClient) fetchPipeline(ctx context.Context, owner string, repo string, number int) (cache.Pipeline, error) {
+	endpoint := c.repositoryEndpoint(owner, repo)
+	endpoint.Path += fmt.Sprintf("/builds/%d", number)
+	endpoint.RawPath += fmt.Sprintf("/builds/%d", number)
+
+	body, err := c.get(ctx, endpoint.String())
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	var build droneBuild
+	if err := json.Unmarshal(body.Bytes(), &build); err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	webURL := c.baseURL
+	webURL.Path += fmt.Sprintf("/%s/%s/%d", owner, repo, number)
+	webURL.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf("/%s/%s/%d", url.PathEscape(owner), url.PathEscape(repo), number)
+
+	return build.toPipeline(webURL.String(), endpoint.String())
+}
+
+func (c DroneClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	body, err := c.get(ctx, step.Log.Key)
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			// Steps that did not run have no log
+			return "", nil
+		}
+		return "", err
+	}
+
+	var lines []struct {
+		Out string `json:"out"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &lines); err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for _, line := range lines {
+		builder.WriteString(line.Out)
+	}
+
+	return builder.String(), nil
+}
+
+func fromDroneStatus(status string) cache.State {
+	switch status {
+	case "pending", "waiting_on_dependencies":
+		return cache.Pending
+	case "running":
+		return cache.Running
+	case "success":
+		return cache.Passed
+	case "failure", "error":
+		return cache.Failed
+	case "killed":
+		return cache.Canceled
+	case "skipped", "declined":
+		return cache.Skipped
+	case "blocked":
+		return cache.Manual
+	default:
+		return cache.Unknown
+	}
+}
+
+// Convert a unix timestamp to a NullTime. Drone uses 0 for timestamps that are not set.
+func fromUnixTimestamp(t int64) utils.NullTime {
+	if t <= 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{
+		Valid: true,
+		Time:  time.Unix(t, 0).UTC(),
+	}
+}
+
+type droneBuild struct {
+	ID       int          `json:"id"`
+	Number   int          `json:"number"`
+	Status   string       `json:"status"`
+	Event    string       `json:"event"`
+	Ref      string       `json:"ref"`
+	After    string       `json:"after"`
+	Source   string       `json:"source"`
+	Created  int64        `json:"created"`
+	Started  int64        `json:"started"`
+	Finished int64        `json:"finished"`
+	Updated  int64        `json:"updated"`
+	Stages   []droneStage `json:"stages"`
+}
+
+func (b droneBuild) toPipeline(webURL string, buildURL string) (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(b.Number),
+		GitReference: cache.GitReference{
+			SHA: b.After,
+			Ref: b.Source,
+		},
+		Step: cache.Step{
+			ID:         strconv.Itoa(b.ID),
+			Type:       cache.StepPipeline,
+			State:      fromDroneStatus(b.Status),
+			CreatedAt:  fromUnixTimestamp(b.Created),
+			StartedAt:  fromUnixTimestamp(b.Started),
+			FinishedAt: fromUnixTimestamp(b.Finished),
+			WebURL: utils.NullString{
+				String: webURL,
+				Valid:  true,
+			},
+		},
+	}
+	if strings.HasPrefix(b.Ref, "refs/tags/") {
+		pipeline.Ref = strings.TrimPrefix(b.Ref, "refs/tags/")
+		pipeline.IsTag = true
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	updatedAt := utils.MaxNullTime(fromUnixTimestamp(b.Updated), pipeline.FinishedAt, pipeline.StartedAt, pipeline.CreatedAt)
+	for _, s := range b.Stages {
+		stage := s.toStep(webURL, buildURL)
+		updatedAt = utils.MaxNullTime(updatedAt, stage.StartedAt, stage.FinishedAt)
+		pipeline.Children = append(pipeline.Children, stage)
+	}
+	if !updatedAt.Valid {
+		return pipeline, errors.New("updatedAt attribute cannot be null")
+	}
+	pipeline.UpdatedAt = updatedAt.Time
+
+	return pipeline, nil
+}
+
+type droneStage struct {
+	Number  int         `json:"number"`
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Started int64       `json:"started"`
+	Stopped int64       `json:"stopped"`
+	Steps   []droneStep `json:"steps"`
+}
+
+func (s droneStage) toStep(webURL string, buildURL string) cache.Step {
+	stageWebURL := fmt.Sprintf("%s/%d", webURL, s.Number)
+	step := cache.Step{
+		ID:         strconv.Itoa(s.Number),
+		Name:       s.Name,
+		Type:       cache.StepJob,
+		State:      fromDroneStatus(s.Status),
+		StartedAt:  fromUnixTimestamp(s.Started),
+		FinishedAt: fromUnixTimestamp(s.Stopped),
+		WebURL: utils.NullString{
+			String: stageWebURL,
+			Valid:  true,
+		},
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	for _, droneStep := range s.Steps {
+		task := cache.Step{
+			ID:           strconv.Itoa(droneStep.Number),
+			Name:         droneStep.Name,
+			Type:         cache.StepTask,
+			State:        fromDroneStatus(droneStep.Status),
+			AllowFailure: droneStep.ErrIgnore,
+			StartedAt:    fromUnixTimestamp(droneStep.Started),
+			FinishedAt:   fromUnixTimestamp(droneStep.Stopped),
+			WebURL: utils.NullString{
+				String: fmt.Sprintf("%s/%d", stageWebURL, droneStep.Number),
+				Valid:  true,
+			},
+			Log: cache.Log{
+				Key: fmt.Sprintf("%s/logs/%d/%d", buildURL, s.Number, droneStep.Number),
+			},
+		}
+		task.Duration = utils.NullSub(task.FinishedAt, task.StartedAt)
+		step.Children = append(step.Children, task)
+	}
+
+	return step
+}
+
+type droneStep struct {
+	Number    int    `json:"number"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	ErrIgnore bool   `json:"errignore"`
+	Started   int64  `json:"started"`
+	Stopped   int64  `json:"stopped"`
+}
@@ -0,0 +1,332 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func setupDroneTestServer(t *testing.T) (DroneClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch r.URL.Path {
+		case "/api/repos/nbedos/citop/builds/42":
+			filename = "drone_build.json"
+		case "/api/repos/nbedos/citop/builds/42/logs/1/3":
+			filename = "drone_log.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "drone", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewDroneClient("drone", "drone", "token", *u, time.Millisecond)
+	client.httpClient = ts.Client()
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestDroneClient_parseBuildURL(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "drone.example.com"}
+	client := NewDroneClient("drone", "drone", "", u, time.Millisecond)
+
+	testCases := []struct {
+		url    string
+		owner  string
+		repo   string
+		number int
+		err    error
+	}{
+		{
+			url:    "https://drone.example.com/nbedos/citop/42",
+			owner:  "nbedos",
+			repo:   "citop",
+			number: 42,
+		},
+		{
+			url:    "https://drone.example.com/nbedos/citop/42/1/3",
+			owner:  "nbedos",
+			repo:   "citop",
+			number: 42,
+		},
+		{
+			url: "https://drone.example.com/nbedos/citop/settings",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://drone.example.com/nbedos/citop",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://example.com/nbedos/citop/42",
+			err: cache.ErrUnknownPipelineURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			owner, repo, number, err := client.parseBuildURL(testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if owner != testCase.owner || repo != testCase.repo || number != testCase.number {
+				t.Fatalf("unexpected result: %q, %q, %d", owner, repo, number)
+			}
+		})
+	}
+}
+
+func TestFromDroneStatus(t *testing.T) {
+	testCases := []struct {
+		status   string
+		expected cache.State
+	}{
+		{"pending", cache.Pending},
+		{"running", cache.Running},
+		{"success", cache.Passed},
+		{"failure", cache.Failed},
+		{"error", cache.Failed},
+		{"killed", cache.Canceled},
+		{"skipped", cache.Skipped},
+		{"blocked", cache.Manual},
+		{"", cache.Unknown},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.status, func(t *testing.T) {
+			if state := fromDroneStatus(testCase.status); state != testCase.expected {
+				t.Fatalf("expected %q but got %q", testCase.expected, state)
+			}
+		})
+	}
+}
+
+func TestDroneClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupDroneTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL+"/nbedos/citop/42/1/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webURL := testURL + "/nbedos/citop/42"
+	logURL := testURL + "/api/repos/nbedos/citop/builds/42/logs"
+	expectedPipeline := cache.Pipeline{
+		Number: "42",
+		GitReference: cache.GitReference{
+			SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+			Ref: "master",
+		},
+		Step: cache.Step{
+			ID:    "1186",
+			Type:  cache.StepPipeline,
+			State: cache.Failed,
+			CreatedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 18, 28, 0, time.UTC),
+			},
+			StartedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+			},
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+			},
+			UpdatedAt: time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: time.Minute + 32*time.Second,
+			},
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+			Children: []cache.Step{
+				{
+					ID:    "1",
+					Name:  "default",
+					Type:  cache.StepJob,
+					State: cache.Failed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute + 32*time.Second,
+					},
+					WebURL: utils.NullString{
+						Valid:  true,
+						String: webURL + "/1",
+					},
+					Children: []cache.Step{
+						{
+							ID:    "1",
+							Name:  "clone",
+							Type:  cache.StepTask,
+							State: cache.Passed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 33, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 3 * time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/1/1",
+							},
+							Log: cache.Log{
+								Key: logURL + "/1/1",
+							},
+						},
+						{
+							ID:           "2",
+							Name:         "lint",
+							Type:         cache.StepTask,
+							State:        cache.Failed,
+							AllowFailure: true,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 33, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 51, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 18 * time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/1/2",
+							},
+							Log: cache.Log{
+								Key: logURL + "/1/2",
+							},
+						},
+						{
+							ID:    "3",
+							Name:  "test",
+							Type:  cache.StepTask,
+							State: cache.Failed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 51, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: time.Minute + 11*time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/1/3",
+							},
+							Log: cache.Log{
+								Key: logURL + "/1/3",
+							},
+						},
+						{
+							ID:    "4",
+							Name:  "publish",
+							Type:  cache.StepTask,
+							State: cache.Skipped,
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/1/4",
+							},
+							Log: cache.Log{
+								Key: logURL + "/1/4",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestDroneClient_Log(t *testing.T) {
+	client, testURL, teardown := setupDroneTestServer(t)
+	defer teardown()
+
+	testCases := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{
+			name:     "step with log",
+			key:      testURL + "/api/repos/nbedos/citop/builds/42/logs/1/3",
+			expected: "+ go test ./...\n--- FAIL: TestCommit (0.00s)\n",
+		},
+		{
+			name:     "skipped step",
+			key:      testURL + "/api/repos/nbedos/citop/builds/42/logs/1/4",
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			step := cache.Step{
+				Log: cache.Log{
+					Key: testCase.key,
+				},
+			}
+			log, err := client.Log(context.Background(), step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, log); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
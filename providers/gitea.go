@@ -0,0 +1,282 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// GiteaClient is a source provider for Gitea and its fork Forgejo
+type GiteaClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+}
+
+func NewGiteaClient(id string, name string, URL url.URL, token string, rateLimit time.Duration) GiteaClient {
+	URL.Path = strings.TrimSuffix(URL.Path, "/") + "/api/v1"
+	URL.RawPath = ""
+
+	return GiteaClient{
+		baseURL:     URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c GiteaClient) ID() string {
+	return c.provider.ID
+}
+
+func (c GiteaClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c GiteaClient) Name() string {
+	return c.provider.Name
+}
+
+var giteaNextPageRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Rate-limited HTTP GET request with authentication. The URL of the next page of results,
+// if any, is extracted from the Link header of the response.
+func (c GiteaClient) get(ctx context.Context, resourceURL string) (*bytes.Buffer, string, error) {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
+	}
+	req.Header.Add("Accept", "application/json")
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errorBody struct {
+			Message string `json:"message"`
+		}
+		var message string
+		if jsonErr := json.Unmarshal(body.Bytes(), &errorBody); jsonErr == nil {
+			message = errorBody.Message
+		}
+
+		return nil, "", HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: message,
+		}
+	}
+
+	next := ""
+	if matches := giteaNextPageRegexp.FindStringSubmatch(resp.Header.Get("Link")); matches != nil {
+		next = matches[1]
+	}
+
+	return body, next, nil
+}
+
+// Call f on each page of a paginated resource
+func (c GiteaClient) forEachPage(ctx context.Context, resourceURL string, f func(body []byte) error) error {
+	for resourceURL != "" {
+		body, next, err := c.get(ctx, resourceURL)
+		if err != nil {
+			return err
+		}
+		if err := f(body.Bytes()); err != nil {
+			return err
+		}
+		resourceURL = next
+	}
+
+	return nil
+}
+
+func (c GiteaClient) parseRepositoryURL(u string) (string, string, error) {
+	host, owner, repo, err := utils.RepoHostOwnerAndName(u)
+	if err != nil || host != c.baseURL.Hostname() {
+		return "", "", cache.ErrUnknownRepositoryURL
+	}
+
+	return owner, repo, nil
+}
+
+func (c GiteaClient) repositoryEndpoint(owner string, repo string) url.URL {
+	endpoint := c.baseURL
+	pathFormat := "/repos/%s/%s"
+	endpoint.Path += fmt.Sprintf(pathFormat, owner, repo)
+	endpoint.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf(pathFormat, url.PathEscape(owner), url.PathEscape(repo))
+
+	return endpoint
+}
+
+func (c GiteaClient) Commit(ctx context.Context, repo string, ref string) (cache.Commit, error) {
+	owner, name, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return cache.Commit{}, err
+	}
+
+	endpoint := c.repositoryEndpoint(owner, name)
+	endpoint.Path += "/git/commits/" + ref
+	endpoint.RawPath += "/git/commits/" + url.PathEscape(ref)
+
+	body, _, err := c.get(ctx, endpoint.String())
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && (err.Status == 404 || err.Status == 422) {
+			return cache.Commit{}, cache.ErrUnknownGitReference
+		}
+		return cache.Commit{}, err
+	}
+
+	var giteaCommit struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Message string `json:"message"`
+			Author  struct {
+				Name  string    `json:"name"`
+				Email string    `json:"email"`
+				Date  time.Time `json:"date"`
+			} `json:"author"`
+		} `json:"commit"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &giteaCommit); err != nil {
+		return cache.Commit{}, err
+	}
+
+	commit := cache.Commit{
+		Sha:     giteaCommit.SHA,
+		Author:  fmt.Sprintf("%s <%s>", giteaCommit.Commit.Author.Name, giteaCommit.Commit.Author.Email),
+		Date:    giteaCommit.Commit.Author.Date,
+		Message: giteaCommit.Commit.Message,
+	}
+
+	// Gitea offers no way to look up the references pointing to a commit so list all branches
+	// and tags of the repository instead
+	branchesURL := c.repositoryEndpoint(owner, name)
+	branchesURL.Path += "/branches"
+	branchesURL.RawPath += "/branches"
+	err = c.forEachPage(ctx, branchesURL.String(), func(body []byte) error {
+		var branches []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				ID string `json:"id"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(body, &branches); err != nil {
+			return err
+		}
+		for _, branch := range branches {
+			if branch.Commit.ID == commit.Sha {
+				commit.Branches = append(commit.Branches, branch.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cache.Commit{}, err
+	}
+
+	tagsURL := c.repositoryEndpoint(owner, name)
+	tagsURL.Path += "/tags"
+	tagsURL.RawPath += "/tags"
+	err = c.forEachPage(ctx, tagsURL.String(), func(body []byte) error {
+		var tags []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		if err := json.Unmarshal(body, &tags); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if tag.Commit.SHA == commit.Sha {
+				commit.Tags = append(commit.Tags, tag.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cache.Commit{}, err
+	}
+
+	return commit, nil
+}
+
+func (c GiteaClient) RefStatuses(ctx context.Context, u string, ref string, sha string) ([]string, error) {
+	owner, repo, err := c.parseRepositoryURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if sha != "" {
+		ref = sha
+	}
+
+	statusesURL := c.repositoryEndpoint(owner, repo)
+	statusesURL.Path += fmt.Sprintf("/commits/%s/statuses", ref)
+	statusesURL.RawPath += fmt.Sprintf("/commits/%s/statuses", url.PathEscape(ref))
+
+	urls := make([]string, 0)
+	previousURLs := make(map[string]struct{})
+	err = c.forEachPage(ctx, statusesURL.String(), func(body []byte) error {
+		var statuses []struct {
+			TargetURL string `json:"target_url"`
+		}
+		if err := json.Unmarshal(body, &statuses); err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.TargetURL == "" {
+				continue
+			}
+			// Gitea returns every status ever posted, including those overwritten since then
+			if _, exists := previousURLs[status.TargetURL]; !exists {
+				previousURLs[status.TargetURL] = struct{}{}
+				urls = append(urls, status.TargetURL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			return nil, cache.ErrUnknownRepositoryURL
+		}
+		return nil, err
+	}
+
+	return urls, nil
+}
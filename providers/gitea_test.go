@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+func setupGiteaTestServer(t *testing.T) (GiteaClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token token" {
+			w.WriteHeader(401)
+			return
+		}
+
+		filename := ""
+		switch {
+		case r.URL.Path == "/api/v1/repos/nbedos/citop/git/commits/master":
+			filename = "gitea_commit.json"
+		case r.URL.Path == "/api/v1/repos/nbedos/citop/branches" && r.URL.Query().Get("page") == "":
+			w.Header().Add("Link", fmt.Sprintf(`<http://%s/api/v1/repos/nbedos/citop/branches?page=2>; rel="next",<http://%s/api/v1/repos/nbedos/citop/branches?page=2>; rel="last"`, r.Host, r.Host))
+			filename = "gitea_branches.json"
+		case r.URL.Path == "/api/v1/repos/nbedos/citop/branches" && r.URL.Query().Get("page") == "2":
+			w.Header().Add("Link", fmt.Sprintf(`<http://%s/api/v1/repos/nbedos/citop/branches?page=1>; rel="first",<http://%s/api/v1/repos/nbedos/citop/branches?page=1>; rel="prev"`, r.Host, r.Host))
+			filename = "gitea_branches_2.json"
+		case r.URL.Path == "/api/v1/repos/nbedos/citop/tags":
+			filename = "gitea_tags.json"
+		case r.URL.Path == "/api/v1/repos/nbedos/citop/commits/a24840cf94b395af69da4a1001d32e3694637e20/statuses":
+			filename = "gitea_statuses.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "gitea", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		// Rewrite URLs in the file to match the scheme and host of the query
+		s := strings.Replace(string(bs), "https://example.com", "http://"+r.Host, -1)
+		if _, err := fmt.Fprint(w, s); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewGiteaClient("gitea", "gitea", *u, "token", time.Millisecond)
+	client.httpClient = ts.Client()
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestGiteaClient_parseRepositoryURL(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "gitea.example.com"}
+	client := NewGiteaClient("gitea", "gitea", u, "", time.Millisecond)
+
+	testCases := []struct {
+		url   string
+		owner string
+		repo  string
+		err   error
+	}{
+		{
+			url:   "https://gitea.example.com/nbedos/citop",
+			owner: "nbedos",
+			repo:  "citop",
+		},
+		{
+			url:   "git@gitea.example.com:nbedos/citop.git",
+			owner: "nbedos",
+			repo:  "citop",
+		},
+		{
+			url:   "ssh://git@gitea.example.com:2222/nbedos/citop.git",
+			owner: "nbedos",
+			repo:  "citop",
+		},
+		{
+			url: "https://github.com/nbedos/citop",
+			err: cache.ErrUnknownRepositoryURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			owner, repo, err := client.parseRepositoryURL(testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if owner != testCase.owner || repo != testCase.repo {
+				t.Fatalf("expected %q, %q but got %q, %q", testCase.owner, testCase.repo, owner, repo)
+			}
+		})
+	}
+}
+
+func TestGiteaClient_Commit(t *testing.T) {
+	t.Run("existing reference", func(t *testing.T) {
+		client, testURL, teardown := setupGiteaTestServer(t)
+		defer teardown()
+
+		commit, err := client.Commit(context.Background(), testURL+"/nbedos/citop", "master")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedCommit := cache.Commit{
+			Sha:      "a24840cf94b395af69da4a1001d32e3694637e20",
+			Author:   "nbedos <nicolas.bedos@gmail.com>",
+			Date:     time.Date(2019, 12, 16, 18, 6, 43, 0, time.UTC),
+			Message:  "Fix typos\n",
+			Branches: []string{"master"},
+			Tags:     []string{"0.1.0"},
+		}
+
+		if diff := cmp.Diff(expectedCommit, commit); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("non existing commit", func(t *testing.T) {
+		client, testURL, teardown := setupGiteaTestServer(t)
+		defer teardown()
+
+		_, err := client.Commit(context.Background(), testURL+"/nbedos/citop", "0000000")
+		if err != cache.ErrUnknownGitReference {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestGiteaClient_RefStatuses(t *testing.T) {
+	client, testURL, teardown := setupGiteaTestServer(t)
+	defer teardown()
+
+	statuses, err := client.RefStatuses(context.Background(), testURL+"/nbedos/citop", "master", "a24840cf94b395af69da4a1001d32e3694637e20")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStatuses := []string{
+		"https://drone.example.com/nbedos/citop/42",
+		"https://jenkins.example.com/job/citop/12/",
+	}
+	if diff := cmp.Diff(expectedStatuses, statuses); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -1,12 +1,15 @@
 package providers
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/v28/github"
 	"github.com/nbedos/citop/cache"
@@ -15,12 +18,21 @@ import (
 )
 
 type GitHubClient struct {
-	id     string
-	client *github.Client
+	provider   cache.Provider
+	client     *github.Client
+	httpClient *http.Client
+	webURL     url.URL
 }
 
-func NewGitHubClient(ctx context.Context, id string, token *string) GitHubClient {
-	var httpClient *http.Client
+var GitHubURL = url.URL{
+	Scheme: "https",
+	Host:   "github.com",
+}
+
+// Return a client for github.com if baseURL is empty or points to github.com, otherwise return
+// a client for the GitHub Enterprise Server instance located at baseURL
+func NewGitHubClient(ctx context.Context, id string, name string, baseURL string, token *string) (GitHubClient, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
 
 	if token != nil && *token != "" {
 		ts := oauth2.StaticTokenSource(
@@ -29,20 +41,64 @@ func NewGitHubClient(ctx context.Context, id string, token *string) GitHubClient
 		httpClient = oauth2.NewClient(ctx, ts)
 	}
 
-	return GitHubClient{
-		id:     id,
-		client: github.NewClient(httpClient),
+	client := GitHubClient{
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+		httpClient: httpClient,
+		webURL:     GitHubURL,
+	}
+
+	if baseURL == "" {
+		client.client = github.NewClient(httpClient)
+		return client, nil
+	}
+
+	webURL, err := url.Parse(baseURL)
+	if err != nil {
+		return client, err
+	}
+	if webURL.Hostname() == GitHubURL.Hostname() {
+		client.client = github.NewClient(httpClient)
+		return client, nil
+	}
+	webURL.Path = strings.TrimSuffix(webURL.Path, "/")
+	webURL.RawPath = ""
+	client.webURL = *webURL
+
+	// GitHub Enterprise Server exposes its REST API on the same host as its web interface
+	// but under a different path
+	apiURL, uploadURL := *webURL, *webURL
+	apiURL.Path += "/api/v3/"
+	uploadURL.Path += "/api/uploads/"
+	if client.client, err = github.NewEnterpriseClient(apiURL.String(), uploadURL.String(), httpClient); err != nil {
+		return client, err
 	}
+
+	return client, nil
 }
 
 func (c GitHubClient) ID() string {
-	return c.id
+	return c.provider.ID
+}
+
+func (c GitHubClient) Host() string {
+	return c.client.BaseURL.Host
+}
+
+func (c GitHubClient) Name() string {
+	return c.provider.Name
+}
+
+// Hostname of the web interface of GitHub
+func (c GitHubClient) webHostname() string {
+	return c.webURL.Hostname()
 }
 
 func (c GitHubClient) parseRepositoryURL(url string) (string, string, error) {
 	host, owner, repo, err := utils.RepoHostOwnerAndName(url)
-	expectedHost := strings.TrimPrefix(c.client.BaseURL.Hostname(), "api.")
-	if err != nil || !strings.Contains(host, expectedHost) {
+	if err != nil || (host != c.webHostname() && !strings.HasSuffix(host, "."+c.webHostname())) {
 		return "", "", cache.ErrUnknownRepositoryURL
 	}
 
@@ -109,6 +165,80 @@ func (c GitHubClient) Commit(ctx context.Context, repo string, ref string) (cach
 	return commit, nil
 }
 
+func (c GitHubClient) PullRequestHead(ctx context.Context, repo string, number int) (string, error) {
+	owner, repo, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return "", cache.ErrUnknownRepositoryURL
+	}
+
+	owner = url.PathEscape(owner)
+	repo = url.PathEscape(repo)
+
+	pr, _, err := c.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == 404 {
+			err = cache.ErrUnknownGitReference
+		}
+		return "", err
+	}
+
+	return pr.GetHead().GetSHA(), nil
+}
+
+// Return the last 'limit' commits of the history of 'ref'. Branches and tags of the commits are not
+// set.
+func (c GitHubClient) Commits(ctx context.Context, repo string, ref string, limit int) ([]cache.Commit, error) {
+	owner, repo, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return nil, cache.ErrUnknownRepositoryURL
+	}
+
+	owner = url.PathEscape(owner)
+	repo = url.PathEscape(repo)
+
+	commits := make([]cache.Commit, 0, limit)
+	opt := github.CommitsListOptions{
+		SHA: ref,
+		ListOptions: github.ListOptions{
+			PerPage: utils.MinInt(limit, 100),
+		},
+	}
+	for len(commits) < limit {
+		repoCommits, resp, err := c.client.Repositories.ListCommits(ctx, owner, repo, &opt)
+		if err != nil {
+			if e, ok := err.(*github.ErrorResponse); ok {
+				switch e.Response.StatusCode {
+				case 404:
+					err = cache.ErrUnknownRepositoryURL
+				case 422:
+					err = cache.ErrUnknownGitReference
+				}
+			}
+			return nil, err
+		}
+
+		for _, repoCommit := range repoCommits {
+			if len(commits) >= limit {
+				break
+			}
+			githubCommit := repoCommit.GetCommit()
+			commits = append(commits, cache.Commit{
+				Sha:     repoCommit.GetSHA(),
+				Author:  fmt.Sprintf("%s <%s>", githubCommit.GetAuthor().GetName(), githubCommit.GetAuthor().GetEmail()),
+				Date:    githubCommit.GetAuthor().GetDate(),
+				Message: githubCommit.GetMessage(),
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
 func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha string) ([]string, error) {
 	owner, repo, err := c.parseRepositoryURL(u)
 	if err != nil {
@@ -204,3 +334,319 @@ func (c GitHubClient) RefStatuses(ctx context.Context, u string, ref string, sha
 
 	return urls, err
 }
+
+// Extract owner, repository, workflow run ID and job ID from the web URL of a GitHub Actions
+// workflow run or job. The run ID is zero if the URL refers to a job.
+func (c GitHubClient) parseActionsURL(u string) (string, string, int64, int64, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	if v.Hostname() != c.webHostname() {
+		return "", "", 0, 0, cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//   https://github.com/nbedos/citop/actions/runs/33746887
+	//   https://github.com/nbedos/citop/actions/runs/33746887/job/398576438
+	//   https://github.com/nbedos/citop/runs/398576438
+	cs := strings.FieldsFunc(v.EscapedPath(), func(c rune) bool { return c == '/' })
+	var runID, jobID string
+	switch {
+	case len(cs) >= 4 && cs[2] == "runs":
+		jobID = cs[3]
+	case len(cs) >= 7 && cs[2] == "actions" && cs[3] == "runs" && cs[5] == "job":
+		jobID = cs[6]
+	case len(cs) >= 5 && cs[2] == "actions" && cs[3] == "runs":
+		runID = cs[4]
+	default:
+		return "", "", 0, 0, cache.ErrUnknownPipelineURL
+	}
+
+	var run, job int64
+	if runID != "" {
+		if run, err = strconv.ParseInt(runID, 10, 64); err != nil {
+			return "", "", 0, 0, cache.ErrUnknownPipelineURL
+		}
+	} else {
+		if job, err = strconv.ParseInt(jobID, 10, 64); err != nil {
+			return "", "", 0, 0, cache.ErrUnknownPipelineURL
+		}
+	}
+
+	return cs[0], cs[1], run, job, nil
+}
+
+func (c GitHubClient) getJSON(ctx context.Context, path string, v interface{}) (*github.Response, error) {
+	req, err := c.client.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.Do(ctx, req, v)
+}
+
+func (c GitHubClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	owner, repo, runID, jobID, err := c.parseActionsURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	owner = url.PathEscape(owner)
+	repo = url.PathEscape(repo)
+
+	// Check runs created by GitHub Actions point to a job, not to the workflow run it
+	// belongs to, so look up the run first
+	if runID == 0 {
+		var job githubActionsJob
+		p := fmt.Sprintf("repos/%s/%s/actions/jobs/%d", owner, repo, jobID)
+		if _, err := c.getJSON(ctx, p, &job); err != nil {
+			if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == 404 {
+				err = cache.ErrUnknownPipelineURL
+			}
+			return cache.Pipeline{}, err
+		}
+		runID = job.RunID
+	}
+
+	return c.fetchPipeline(ctx, owner, repo, runID)
+}
+
+func (c GitHubClient) fetchPipeline(ctx context.Context, owner string, repo string, runID int64) (cache.Pipeline, error) {
+	var run githubActionsRun
+	p := fmt.Sprintf("repos/%s/%s/actions/runs/%d", owner, repo, runID)
+	if _, err := c.getJSON(ctx, p, &run); err != nil {
+		if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == 404 {
+			err = cache.ErrUnknownPipelineURL
+		}
+		return cache.Pipeline{}, err
+	}
+
+	jobs := make([]githubActionsJob, 0)
+	for page := 1; page != 0; {
+		var jobsPage struct {
+			Jobs []githubActionsJob `json:"jobs"`
+		}
+		p := fmt.Sprintf("repos/%s/%s/actions/runs/%d/jobs?per_page=100&page=%d", owner, repo, runID, page)
+		resp, err := c.getJSON(ctx, p, &jobsPage)
+		if err != nil {
+			return cache.Pipeline{}, err
+		}
+		jobs = append(jobs, jobsPage.Jobs...)
+		page = resp.NextPage
+	}
+
+	logPathPrefix := fmt.Sprintf("repos/%s/%s/actions/jobs", owner, repo)
+	return run.toPipeline(jobs, logPathPrefix)
+}
+
+func (c GitHubClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	req, err := c.client.NewRequest("GET", step.Log.Key, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+
+	// The API answers with a redirection to a short-lived URL that must be requested
+	// without our credentials
+	httpClient := http.Client{}
+	if c.httpClient != nil {
+		httpClient = *c.httpClient
+	}
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently {
+		location, err := resp.Location()
+		if err != nil {
+			return "", err
+		}
+		if req, err = http.NewRequest("GET", location.String(), nil); err != nil {
+			return "", err
+		}
+		req = req.WithContext(ctx)
+		httpClient := http.Client{Timeout: 10 * time.Second}
+		if resp, err = httpClient.Do(req); err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+	}
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: body.String(),
+		}
+	}
+
+	return body.String(), nil
+}
+
+func fromGitHubActionsState(status string, conclusion string) cache.State {
+	switch status {
+	case "queued", "waiting", "requested", "pending":
+		return cache.Pending
+	case "in_progress":
+		return cache.Running
+	case "completed":
+		switch conclusion {
+		case "success", "neutral":
+			return cache.Passed
+		case "failure", "timed_out", "startup_failure":
+			return cache.Failed
+		case "cancelled":
+			return cache.Canceled
+		case "skipped":
+			return cache.Skipped
+		case "action_required":
+			return cache.Manual
+		}
+	}
+
+	return cache.Unknown
+}
+
+type githubActionsRun struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Number     int    `json:"run_number"`
+	Event      string `json:"event"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HeadBranch string `json:"head_branch"`
+	HeadSha    string `json:"head_sha"`
+	WebURL     string `json:"html_url"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+func (r githubActionsRun) toPipeline(jobs []githubActionsJob, logPathPrefix string) (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(r.Number),
+		GitReference: cache.GitReference{
+			SHA:   r.HeadSha,
+			Ref:   r.HeadBranch,
+			IsTag: false,
+		},
+		Step: cache.Step{
+			ID:    strconv.FormatInt(r.ID, 10),
+			Name:  r.Name,
+			Type:  cache.StepPipeline,
+			State: fromGitHubActionsState(r.Status, r.Conclusion),
+			WebURL: utils.NullString{
+				String: r.WebURL,
+				Valid:  r.WebURL != "",
+			},
+		},
+	}
+
+	var err error
+	if pipeline.CreatedAt, err = utils.NullTimeFromString(r.CreatedAt); err != nil {
+		return pipeline, err
+	}
+	if pipeline.UpdatedAt, err = time.Parse(time.RFC3339, r.UpdatedAt); err != nil {
+		return pipeline, err
+	}
+
+	for _, githubJob := range jobs {
+		job, err := githubJob.toStep(logPathPrefix)
+		if err != nil {
+			return pipeline, err
+		}
+		pipeline.StartedAt = utils.MinNullTime(pipeline.StartedAt, job.StartedAt)
+		pipeline.FinishedAt = utils.MaxNullTime(pipeline.FinishedAt, job.FinishedAt)
+		pipeline.Children = append(pipeline.Children, job)
+	}
+
+	if pipeline.State.IsActive() {
+		pipeline.FinishedAt = utils.NullTime{}
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	return pipeline, nil
+}
+
+type githubActionsJob struct {
+	ID          int64  `json:"id"`
+	RunID       int64  `json:"run_id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+	WebURL      string `json:"html_url"`
+	Steps       []struct {
+		Number      int    `json:"number"`
+		Name        string `json:"name"`
+		Status      string `json:"status"`
+		Conclusion  string `json:"conclusion"`
+		StartedAt   string `json:"started_at"`
+		CompletedAt string `json:"completed_at"`
+	} `json:"steps"`
+}
+
+func (j githubActionsJob) toStep(logPathPrefix string) (cache.Step, error) {
+	webURL := utils.NullString{
+		String: j.WebURL,
+		Valid:  j.WebURL != "",
+	}
+
+	job := cache.Step{
+		ID:    strconv.FormatInt(j.ID, 10),
+		Name:  j.Name,
+		Type:  cache.StepJob,
+		State: fromGitHubActionsState(j.Status, j.Conclusion),
+		Log: cache.Log{
+			Key: fmt.Sprintf("%s/%d/logs", logPathPrefix, j.ID),
+		},
+		WebURL: webURL,
+	}
+
+	var err error
+	if job.StartedAt, err = utils.NullTimeFromString(j.StartedAt); err != nil {
+		return job, err
+	}
+	if job.FinishedAt, err = utils.NullTimeFromString(j.CompletedAt); err != nil {
+		return job, err
+	}
+	job.Duration = utils.NullSub(job.FinishedAt, job.StartedAt)
+
+	for _, s := range j.Steps {
+		task := cache.Step{
+			ID:     strconv.Itoa(s.Number),
+			Name:   s.Name,
+			Type:   cache.StepTask,
+			State:  fromGitHubActionsState(s.Status, s.Conclusion),
+			WebURL: webURL,
+		}
+		if task.StartedAt, err = utils.NullTimeFromString(s.StartedAt); err != nil {
+			return job, err
+		}
+		if task.FinishedAt, err = utils.NullTimeFromString(s.CompletedAt); err != nil {
+			return job, err
+		}
+		task.Duration = utils.NullSub(task.FinishedAt, task.StartedAt)
+		job.Children = append(job.Children, task)
+	}
+
+	return job, nil
+}
@@ -12,24 +12,48 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-github/v28/github"
 	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
 )
 
-func setupGitHubTestServer() (*http.Client, string, func()) {
+// Test server standing in for a GitHub Enterprise Server instance
+func setupGitHubTestServer(t *testing.T) (GitHubClient, string, func()) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		filename := ""
 		switch r.URL.Path {
-		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/check-runs":
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/check-runs":
 			filename = "github_check_runs.json"
-		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/statuses":
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/statuses":
 			filename = "github_statuses.json"
-		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07":
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07":
 			filename = "github_commit.json"
-		case "/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/branches-where-head":
+		case "/api/v3/repos/nbedos/termtosvg/commits/d58600a58bf1738c6529ce3489a546bfa2178e07/branches-where-head":
 			filename = "github_branches.json"
-		case "/repos/nbedos/termtosvg/tags":
+		case "/api/v3/repos/nbedos/termtosvg/commits":
+			if r.URL.Query().Get("sha") != "master" {
+				w.WriteHeader(404)
+				return
+			}
+			filename = "github_commits.json"
+		case "/api/v3/repos/nbedos/termtosvg/pulls/42":
+			filename = "github_pull.json"
+		case "/api/v3/repos/nbedos/termtosvg/tags":
 			filename = "github_tags.json"
+		case "/api/v3/repos/nbedos/citop/actions/runs/33746887":
+			filename = "github_actions_run.json"
+		case "/api/v3/repos/nbedos/citop/actions/runs/33746887/jobs":
+			filename = "github_actions_jobs.json"
+		case "/api/v3/repos/nbedos/citop/actions/jobs/398576438":
+			filename = "github_actions_job.json"
+		case "/api/v3/repos/nbedos/citop/actions/jobs/398576438/logs":
+			http.Redirect(w, r, "/logs/398576438", http.StatusFound)
+			return
+		case "/logs/398576438":
+			if r.Header.Get("Authorization") != "" {
+				w.WriteHeader(403)
+				return
+			}
+			filename = "github_actions_log"
 		default:
 			w.WriteHeader(404)
 			return
@@ -48,21 +72,19 @@ func setupGitHubTestServer() (*http.Client, string, func()) {
 		}
 	}))
 
-	return ts.Client(), ts.URL, func() { ts.Close() }
+	token := "token"
+	client, err := NewGitHubClient(context.Background(), "github", "github", ts.URL, &token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, ts.URL, func() { ts.Close() }
 }
 
 func TestRefStatuses(t *testing.T) {
-	httpClient, serverURL, teardown := setupGitHubTestServer()
+	client, serverURL, teardown := setupGitHubTestServer(t)
 	defer teardown()
 
-	c, err := github.NewEnterpriseClient(serverURL, serverURL, httpClient)
-	if err != nil {
-		t.Fatal(err)
-	}
-	client := GitHubClient{
-		client: c,
-	}
-
 	sha := "d58600a58bf1738c6529ce3489a546bfa2178e07"
 	urls, err := client.RefStatuses(context.Background(), serverURL+"/nbedos/termtosvg", "", sha)
 	if err != nil {
@@ -85,17 +107,9 @@ func TestRefStatuses(t *testing.T) {
 }
 
 func TestCommit(t *testing.T) {
-	httpClient, serverURL, teardown := setupGitHubTestServer()
+	client, serverURL, teardown := setupGitHubTestServer(t)
 	defer teardown()
 
-	c, err := github.NewEnterpriseClient(serverURL, serverURL, httpClient)
-	if err != nil {
-		t.Fatal(err)
-	}
-	client := GitHubClient{
-		client: c,
-	}
-
 	repoURL := serverURL + "/nbedos/termtosvg"
 	commit, err := client.Commit(context.Background(), repoURL, "d58600a58bf1738c6529ce3489a546bfa2178e07")
 	if err != nil {
@@ -115,3 +129,377 @@ func TestCommit(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestGitHubClient_PullRequestHead(t *testing.T) {
+	client, serverURL, teardown := setupGitHubTestServer(t)
+	defer teardown()
+
+	repoURL := serverURL + "/nbedos/termtosvg"
+
+	t.Run("existing pull request", func(t *testing.T) {
+		sha, err := client.PullRequestHead(context.Background(), repoURL, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "d58600a58bf1738c6529ce3489a546bfa2178e07"; sha != expected {
+			t.Fatalf("expected %q but got %q", expected, sha)
+		}
+	})
+
+	t.Run("non existing pull request", func(t *testing.T) {
+		_, err := client.PullRequestHead(context.Background(), repoURL, 43)
+		if err != cache.ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownGitReference, err)
+		}
+	})
+}
+
+func TestGitHubClient_Commits(t *testing.T) {
+	client, serverURL, teardown := setupGitHubTestServer(t)
+	defer teardown()
+
+	commits, err := client.Commits(context.Background(), serverURL+"/nbedos/termtosvg", "master", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []cache.Commit{
+		{
+			Sha:     "d58600a58bf1738c6529ce3489a546bfa2178e07",
+			Author:  "nbedos <nicolas.bedos@gmail.com>",
+			Date:    time.Date(2019, 11, 16, 14, 59, 32, 0, time.UTC),
+			Message: "Bump version to 1.0.0",
+		},
+		{
+			Sha:     "3f8a1ac18ccb3e1b6f9b1d1f2a4e8e0aa8ad1b6e",
+			Author:  "nbedos <nicolas.bedos@gmail.com>",
+			Date:    time.Date(2019, 11, 15, 21, 10, 5, 0, time.UTC),
+			Message: "Update README\n\nDocument the new options",
+		},
+	}
+	if diff := cmp.Diff(expected, commits); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		commits, err := client.Commits(context.Background(), serverURL+"/nbedos/termtosvg", "master", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(commits) != 1 {
+			t.Fatalf("expected 1 commit but got %d", len(commits))
+		}
+	})
+}
+
+func TestGitHubClient_parseRepositoryURL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		baseURL string
+		url     string
+		err     error
+	}{
+		{
+			name: "github.com web URL",
+			url:  "https://github.com/nbedos/citop",
+		},
+		{
+			name: "github.com git URL",
+			url:  "git@github.com:nbedos/citop.git",
+		},
+		{
+			name: "GitHub Enterprise URL with github.com client",
+			url:  "https://github.example.com/nbedos/citop",
+			err:  cache.ErrUnknownRepositoryURL,
+		},
+		{
+			name:    "github.com URL with explicit base URL",
+			baseURL: "https://github.com",
+			url:     "https://github.com/nbedos/citop",
+		},
+		{
+			name:    "GitHub Enterprise web URL",
+			baseURL: "https://github.example.com",
+			url:     "https://github.example.com/nbedos/citop",
+		},
+		{
+			name:    "GitHub Enterprise git URL",
+			baseURL: "https://github.example.com/",
+			url:     "git@github.example.com:nbedos/citop.git",
+		},
+		{
+			name:    "github.com URL with GitHub Enterprise client",
+			baseURL: "https://github.example.com",
+			url:     "https://github.com/nbedos/citop",
+			err:     cache.ErrUnknownRepositoryURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, err := NewGitHubClient(context.Background(), "github", "github", testCase.baseURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			owner, repo, err := client.parseRepositoryURL(testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if err == nil && (owner != "nbedos" || repo != "citop") {
+				t.Fatalf("unexpected result: %q %q", owner, repo)
+			}
+		})
+	}
+}
+
+func TestGitHubClient_parseActionsURL(t *testing.T) {
+	client, err := NewGitHubClient(context.Background(), "github", "github", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		url   string
+		runID int64
+		jobID int64
+	}{
+		{
+			url:   "https://github.com/nbedos/citop/actions/runs/33746887",
+			runID: 33746887,
+		},
+		{
+			url:   "https://github.com/nbedos/citop/actions/runs/33746887/job/398576438",
+			jobID: 398576438,
+		},
+		{
+			url:   "https://github.com/nbedos/citop/runs/398576438",
+			jobID: 398576438,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			owner, repo, runID, jobID, err := client.parseActionsURL(testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != "nbedos" || repo != "citop" || runID != testCase.runID || jobID != testCase.jobID {
+				t.Fatalf("unexpected result: %q %q %d %d", owner, repo, runID, jobID)
+			}
+		})
+	}
+
+	for _, u := range []string{
+		"https://travis-ci.org/nbedos/citop/builds/615087280",
+		"https://github.com/nbedos/citop",
+		"https://github.com/nbedos/citop/actions",
+	} {
+		t.Run(u, func(t *testing.T) {
+			if _, _, _, _, err := client.parseActionsURL(u); err != cache.ErrUnknownPipelineURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownPipelineURL, err)
+			}
+		})
+	}
+}
+
+func TestGitHubClient_BuildFromURL(t *testing.T) {
+	client, serverURL, teardown := setupGitHubTestServer(t)
+	defer teardown()
+
+	expectedPipeline := cache.Pipeline{
+		Number: "12",
+		GitReference: cache.GitReference{
+			SHA: "b8e2bf8bbc2ab7a7f8c51ac16f4a0c15e3d9b6d2",
+			Ref: "master",
+		},
+		Step: cache.Step{
+			ID:    "33746887",
+			Name:  "Go",
+			Type:  cache.StepPipeline,
+			State: cache.Failed,
+			CreatedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 4, 17, 30, 11, 0, time.UTC),
+			},
+			StartedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 4, 17, 30, 18, 0, time.UTC),
+			},
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 4, 17, 32, 45, 0, time.UTC),
+			},
+			UpdatedAt: time.Date(2020, 1, 4, 17, 32, 46, 0, time.UTC),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: 2*time.Minute + 27*time.Second,
+			},
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: "https://github.com/nbedos/citop/actions/runs/33746887",
+			},
+			Children: []cache.Step{
+				{
+					ID:    "398576437",
+					Name:  "build",
+					Type:  cache.StepJob,
+					State: cache.Passed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 4, 17, 30, 18, 0, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 4, 17, 31, 2, 0, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 44 * time.Second,
+					},
+					WebURL: utils.NullString{
+						Valid:  true,
+						String: "https://github.com/nbedos/citop/runs/398576437",
+					},
+					Log: cache.Log{
+						Key: "repos/nbedos/citop/actions/jobs/398576437/logs",
+					},
+					Children: []cache.Step{
+						{
+							ID:    "1",
+							Name:  "Set up job",
+							Type:  cache.StepTask,
+							State: cache.Passed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 30, 18, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 30, 20, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 2 * time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: "https://github.com/nbedos/citop/runs/398576437",
+							},
+						},
+						{
+							ID:    "2",
+							Name:  "Build",
+							Type:  cache.StepTask,
+							State: cache.Passed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 30, 20, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 31, 1, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 41 * time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: "https://github.com/nbedos/citop/runs/398576437",
+							},
+						},
+					},
+				},
+				{
+					ID:    "398576438",
+					Name:  "test (ubuntu-latest)",
+					Type:  cache.StepJob,
+					State: cache.Failed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 4, 17, 30, 20, 0, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 4, 17, 32, 45, 0, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: 2*time.Minute + 25*time.Second,
+					},
+					WebURL: utils.NullString{
+						Valid:  true,
+						String: "https://github.com/nbedos/citop/runs/398576438",
+					},
+					Log: cache.Log{
+						Key: "repos/nbedos/citop/actions/jobs/398576438/logs",
+					},
+					Children: []cache.Step{
+						{
+							ID:    "1",
+							Name:  "Run tests",
+							Type:  cache.StepTask,
+							State: cache.Failed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 30, 21, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 4, 17, 32, 44, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 2*time.Minute + 23*time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: "https://github.com/nbedos/citop/runs/398576438",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, u := range []string{
+		serverURL + "/nbedos/citop/actions/runs/33746887",
+		serverURL + "/nbedos/citop/runs/398576438",
+	} {
+		t.Run(u, func(t *testing.T) {
+			pipeline, err := client.BuildFromURL(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestGitHubClient_Log(t *testing.T) {
+	client, _, teardown := setupGitHubTestServer(t)
+	defer teardown()
+
+	step := cache.Step{
+		ID:   "398576438",
+		Type: cache.StepJob,
+		Log: cache.Log{
+			Key: "repos/nbedos/citop/actions/jobs/398576438/logs",
+		},
+	}
+
+	log, err := client.Log(context.Background(), step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "2020-01-04T17:30:21.0000000Z ##[group]Run go test ./...\n2020-01-04T17:32:44.0000000Z --- FAIL: TestCommit (0.00s)\n"
+	if diff := cmp.Diff(expected, log); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -102,6 +105,88 @@ func (c GitLabClient) Commit(ctx context.Context, repo string, ref string) (cach
 	return commit, nil
 }
 
+// Return the SHA of the head commit of the merge request 'number'
+func (c GitLabClient) PullRequestHead(ctx context.Context, repo string, number int) (string, error) {
+	slug, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return "", cache.ErrUnknownRepositoryURL
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	mr, _, err := c.remote.MergeRequests.GetMergeRequest(slug, number, nil, gitlab.WithContext(ctx))
+	if err != nil {
+		if err, ok := err.(*gitlab.ErrorResponse); ok {
+			switch err.Response.StatusCode {
+			case 401, 404:
+				return "", cache.ErrUnknownGitReference
+			}
+		}
+		return "", err
+	}
+
+	return mr.SHA, nil
+}
+
+// Return the last 'limit' commits of the history of 'ref'. Branches and tags of the commits are not
+// set.
+func (c GitLabClient) Commits(ctx context.Context, repo string, ref string, limit int) ([]cache.Commit, error) {
+	slug, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return nil, cache.ErrUnknownRepositoryURL
+	}
+
+	commits := make([]cache.Commit, 0, limit)
+	opt := gitlab.ListCommitsOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: utils.MinInt(limit, 100),
+		},
+		RefName: &ref,
+	}
+	for len(commits) < limit {
+		select {
+		case <-c.rateLimiter:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		gitlabCommits, resp, err := c.remote.Commits.ListCommits(slug, &opt, gitlab.WithContext(ctx))
+		if err != nil {
+			if err, ok := err.(*gitlab.ErrorResponse); ok {
+				switch err.Response.StatusCode {
+				case 401, 404:
+					return nil, cache.ErrUnknownGitReference
+				}
+			}
+			return nil, err
+		}
+
+		for _, gitlabCommit := range gitlabCommits {
+			if len(commits) >= limit {
+				break
+			}
+			commit := cache.Commit{
+				Sha:     gitlabCommit.ID,
+				Author:  fmt.Sprintf("%s <%s>", gitlabCommit.AuthorName, gitlabCommit.AuthorEmail),
+				Message: gitlabCommit.Message,
+			}
+			if gitlabCommit.AuthoredDate != nil {
+				commit.Date = *gitlabCommit.AuthoredDate
+			}
+			commits = append(commits, commit)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
 func (c GitLabClient) buildURLsPipelines(ctx context.Context, slug string, sha string) ([]string, error) {
 	options := gitlab.ListProjectPipelinesOptions{
 		SHA: &sha,
@@ -305,6 +390,171 @@ func (c GitLabClient) Log(ctx context.Context, step cache.Step) (string, error)
 	return buf.String(), nil
 }
 
+// Write to w the part of the trace of the job 'step' starting at byte 'offset'. Only the new part
+// of the trace is requested thanks to an HTTP range request.
+func (c GitLabClient) StreamLog(ctx context.Context, step cache.Step, offset int, w io.Writer) (int, error) {
+	if step.Log.Key == "" {
+		return offset, cache.ErrNoLogHere
+	}
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return offset, err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return offset, ctx.Err()
+	}
+	withRange := func(req *http.Request) error {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		return nil
+	}
+	var content []byte
+	trace, _, err := c.remote.Jobs.GetTraceFile(step.Log.Key, id, withRange, gitlab.WithContext(ctx))
+	switch e := err.(type) {
+	case nil:
+		// The server ignored the Range header and sent the whole trace
+		buf := bytes.Buffer{}
+		if _, err := buf.ReadFrom(trace); err != nil {
+			return offset, err
+		}
+		if content = buf.Bytes(); len(content) < offset {
+			return offset, nil
+		}
+		content = content[offset:]
+	case *gitlab.ErrorResponse:
+		switch e.Response.StatusCode {
+		case http.StatusPartialContent:
+			// go-gitlab does not expect partial content and returns the body of the response
+			// as part of an error
+			content = e.Body
+		case http.StatusRequestedRangeNotSatisfiable:
+			// Nothing was written to the trace since the last request
+			return offset, nil
+		default:
+			return offset, err
+		}
+	default:
+		return offset, err
+	}
+
+	n, err := w.Write(content)
+	return offset + n, err
+}
+
+// Return the slug of the project and the ID of the pipeline or job 'step'. Stages cannot be
+// acted upon with the API of GitLab.
+func (c GitLabClient) stepSlugAndID(step cache.Step) (string, int, error) {
+	switch step.Type {
+	case cache.StepPipeline:
+		return c.parsePipelineURL(step.WebURL.String)
+	case cache.StepJob:
+		id, err := strconv.Atoi(step.ID)
+		return step.Log.Key, id, err
+	default:
+		return "", 0, cache.ErrNotSupported
+	}
+}
+
+// Retry the job 'step', or the failed jobs of the pipeline 'step'
+func (c GitLabClient) Restart(ctx context.Context, step cache.Step) error {
+	slug, id, err := c.stepSlugAndID(step)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if step.Type == cache.StepPipeline {
+		_, _, err = c.remote.Pipelines.RetryPipelineBuild(slug, id, gitlab.WithContext(ctx))
+	} else {
+		_, _, err = c.remote.Jobs.RetryJob(slug, id, gitlab.WithContext(ctx))
+	}
+	return err
+}
+
+func (c GitLabClient) Cancel(ctx context.Context, step cache.Step) error {
+	slug, id, err := c.stepSlugAndID(step)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if step.Type == cache.StepPipeline {
+		_, _, err = c.remote.Pipelines.CancelPipelineBuild(slug, id, gitlab.WithContext(ctx))
+	} else {
+		_, _, err = c.remote.Jobs.CancelJob(slug, id, gitlab.WithContext(ctx))
+	}
+	return err
+}
+
+type gitLabJobVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// go-gitlab does not support variables of manual jobs yet
+type gitLabPlayJobOptions struct {
+	Variables []gitLabJobVariable `url:"-" json:"job_variables_attributes,omitempty"`
+}
+
+// Start the manual job 'step'
+func (c GitLabClient) Play(ctx context.Context, step cache.Step, variables map[string]string) error {
+	if step.Type != cache.StepJob {
+		return cache.ErrNotSupported
+	}
+	id, err := strconv.Atoi(step.ID)
+	if err != nil {
+		return err
+	}
+
+	opt := gitLabPlayJobOptions{}
+	for key, value := range variables {
+		opt.Variables = append(opt.Variables, gitLabJobVariable{Key: key, Value: value})
+	}
+	sort.Slice(opt.Variables, func(i, j int) bool {
+		return opt.Variables[i].Key < opt.Variables[j].Key
+	})
+
+	u := fmt.Sprintf("projects/%s/jobs/%d/play", url.PathEscape(step.Log.Key), id)
+	req, err := c.remote.NewRequest("POST", u, &opt, []gitlab.OptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, err = c.remote.Do(req, nil)
+	return err
+}
+
+func (c GitLabClient) Trigger(ctx context.Context, repo string, ref string) error {
+	slug, err := c.parseRepositoryURL(repo)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	opt := gitlab.CreatePipelineOptions{Ref: &ref}
+	_, _, err = c.remote.Pipelines.CreatePipeline(slug, &opt, gitlab.WithContext(ctx))
+	return err
+}
+
 func (c GitLabClient) fetchJobs(ctx context.Context, slug string, pipelineID int) ([]*gitlab.Job, error) {
 	select {
 	case <-c.rateLimiter:
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"path"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -44,8 +45,16 @@ func setupGitLabTestServer(t *testing.T) (GitLabClient, string, func()) {
 			filename = "gitlab_jobs.json"
 		case "/api/v4/projects/nbedos/citop/jobs/42/trace":
 			filename = "gitlab_log"
+		case "/api/v4/projects/owner/repo/repository/commits":
+			if r.URL.Query().Get("ref_name") != "master" {
+				w.WriteHeader(404)
+				return
+			}
+			filename = "gitlab_commits.json"
 		case "/api/v4/projects/owner/repo/repository/commits/master":
 			filename = "gitlab_commit.json"
+		case "/api/v4/projects/owner/repo/merge_requests/12":
+			filename = "gitlab_merge_request.json"
 		case "/api/v4/projects/owner/repo/repository/commits/a24840cf94b395af69da4a1001d32e3694637e20/refs":
 			filename = "gitlab_refs.json"
 		case "/api/v4/projects/nbedos/citop/pipelines":
@@ -200,6 +209,99 @@ func TestGitLabClient_Log(t *testing.T) {
 	}
 }
 
+func TestGitLabClient_StreamLog(t *testing.T) {
+	trace := "first line\nsecond line\n"
+	setup := func(t *testing.T, handler http.HandlerFunc) (GitLabClient, func()) {
+		ts := httptest.NewServer(handler)
+		gitlabClient := gitlab.NewClient(ts.Client(), "token")
+		if err := gitlabClient.SetBaseURL(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+		client := GitLabClient{
+			remote:      gitlabClient,
+			rateLimiter: time.Tick(time.Millisecond),
+		}
+		return client, ts.Close
+	}
+	step := cache.Step{
+		ID: "42",
+		Log: cache.Log{
+			Key: "nbedos/citop",
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		ignoreRange    bool
+		offset         int
+		expectedOffset int
+		expectedLog    string
+	}{
+		{
+			name:           "whole trace",
+			offset:         0,
+			expectedOffset: len(trace),
+			expectedLog:    trace,
+		},
+		{
+			name:           "range request",
+			offset:         11,
+			expectedOffset: len(trace),
+			expectedLog:    "second line\n",
+		},
+		{
+			// The server answers with 416 Range Not Satisfiable
+			name:           "nothing new",
+			offset:         len(trace),
+			expectedOffset: len(trace),
+			expectedLog:    "",
+		},
+		{
+			name:           "server ignoring the Range header",
+			ignoreRange:    true,
+			offset:         11,
+			expectedOffset: len(trace),
+			expectedLog:    "second line\n",
+		},
+		{
+			name:           "server ignoring the Range header with nothing new",
+			ignoreRange:    true,
+			offset:         len(trace),
+			expectedOffset: len(trace),
+			expectedLog:    "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, teardown := setup(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v4/projects/nbedos/citop/jobs/42/trace" {
+					w.WriteHeader(404)
+					return
+				}
+				if testCase.ignoreRange {
+					fmt.Fprint(w, trace)
+					return
+				}
+				http.ServeContent(w, r, "trace", time.Time{}, strings.NewReader(trace))
+			})
+			defer teardown()
+
+			buf := strings.Builder{}
+			offset, err := client.StreamLog(context.Background(), step, testCase.offset, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != testCase.expectedOffset {
+				t.Fatalf("expected offset %d but got %d", testCase.expectedOffset, offset)
+			}
+			if diff := cmp.Diff(testCase.expectedLog, buf.String()); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestGitLabClient_Commit(t *testing.T) {
 	t.Run("existing reference", func(t *testing.T) {
 		client, testURL, teardown := setupGitLabTestServer(t)
@@ -237,6 +339,61 @@ func TestGitLabClient_Commit(t *testing.T) {
 	})
 }
 
+func TestGitLabClient_Commits(t *testing.T) {
+	client, testURL, teardown := setupGitLabTestServer(t)
+	defer teardown()
+
+	commits, err := client.Commits(context.Background(), testURL+"/owner/repo", "master", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shas := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		shas = append(shas, commit.Sha)
+	}
+	expected := []string{
+		"a24840cf94b395af69da4a1001d32e3694637e20",
+		"78813c9dc24828fd29d1ee1f7b8d0df79ab7b2b5",
+	}
+	if diff := cmp.Diff(expected, shas); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("non existing reference", func(t *testing.T) {
+		_, err := client.Commits(context.Background(), testURL+"/owner/repo", "feature", 10)
+		if err != cache.ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownGitReference, err)
+		}
+	})
+}
+
+func TestGitLabClient_PullRequestHead(t *testing.T) {
+	client, testURL, teardown := setupGitLabTestServer(t)
+	defer teardown()
+
+	sha, err := client.PullRequestHead(context.Background(), testURL+"/owner/repo", 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a24840cf94b395af69da4a1001d32e3694637e20"; sha != expected {
+		t.Fatalf("expected %q but got %q", expected, sha)
+	}
+
+	t.Run("non existing merge request", func(t *testing.T) {
+		_, err := client.PullRequestHead(context.Background(), testURL+"/owner/repo", 13)
+		if err != cache.ErrUnknownGitReference {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownGitReference, err)
+		}
+	})
+
+	t.Run("repository of another host", func(t *testing.T) {
+		_, err := client.PullRequestHead(context.Background(), "https://example.com/owner/repo", 12)
+		if err != cache.ErrUnknownRepositoryURL {
+			t.Fatalf("expected %v but got %v", cache.ErrUnknownRepositoryURL, err)
+		}
+	})
+}
+
 func TestGitLabClient_RefStatuses(t *testing.T) {
 	client, testURL, teardown := setupGitLabTestServer(t)
 	defer teardown()
@@ -255,3 +412,117 @@ func TestGitLabClient_RefStatuses(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestGitLabClient_Play(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	gitlabClient := gitlab.NewClient(ts.Client(), "token")
+	if err := gitlabClient.SetBaseURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	client := GitLabClient{
+		remote:      gitlabClient,
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	step := cache.Step{
+		ID:    "42",
+		Type:  cache.StepJob,
+		State: cache.Manual,
+		Log: cache.Log{
+			Key: "nbedos/citop",
+		},
+	}
+	variables := map[string]string{"ENV": "production", "DRY_RUN": "0"}
+	if err := client.Play(context.Background(), step, variables); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Play(context.Background(), step, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`POST /api/v4/projects/nbedos/citop/jobs/42/play {"job_variables_attributes":[{"key":"DRY_RUN","value":"0"},{"key":"ENV","value":"production"}]}`,
+		`POST /api/v4/projects/nbedos/citop/jobs/42/play {}`,
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestGitLabClient_Actions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.RequestURI(), body))
+		fmt.Fprint(w, "{}")
+	}))
+	defer ts.Close()
+
+	gitlabClient := gitlab.NewClient(ts.Client(), "token")
+	if err := gitlabClient.SetBaseURL(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	client := GitLabClient{
+		remote:      gitlabClient,
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+
+	pipeline := cache.Step{
+		ID:   "12",
+		Type: cache.StepPipeline,
+		WebURL: utils.NullString{
+			Valid:  true,
+			String: ts.URL + "/nbedos/citop/pipelines/12",
+		},
+	}
+	job := cache.Step{
+		ID:   "42",
+		Type: cache.StepJob,
+		Log: cache.Log{
+			Key: "nbedos/citop",
+		},
+	}
+	ctx := context.Background()
+	for _, step := range []cache.Step{pipeline, job} {
+		if err := client.Restart(ctx, step); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Cancel(ctx, step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Trigger(ctx, ts.URL+"/nbedos/citop", "feature/doc"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"POST /api/v4/projects/nbedos%2Fcitop/pipelines/12/retry null",
+		"POST /api/v4/projects/nbedos%2Fcitop/pipelines/12/cancel null",
+		"POST /api/v4/projects/nbedos%2Fcitop/jobs/42/retry null",
+		"POST /api/v4/projects/nbedos%2Fcitop/jobs/42/cancel null",
+		`POST /api/v4/projects/nbedos%2Fcitop/pipeline {"ref":"feature/doc"}`,
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("stage", func(t *testing.T) {
+		stage := cache.Step{ID: "1", Type: cache.StepStage}
+		if err := client.Restart(ctx, stage); err != cache.ErrNotSupported {
+			t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+		}
+	})
+}
@@ -0,0 +1,401 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+type JenkinsClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	username    string
+	token       string
+	provider    cache.Provider
+}
+
+func NewJenkinsClient(id string, name string, URL url.URL, username string, token string, rateLimit time.Duration) JenkinsClient {
+	URL.Path = strings.TrimSuffix(URL.Path, "/")
+	URL.RawPath = strings.TrimSuffix(URL.RawPath, "/")
+
+	return JenkinsClient{
+		baseURL:     URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		username:    username,
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c JenkinsClient) ID() string {
+	return c.provider.ID
+}
+
+func (c JenkinsClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c JenkinsClient) Name() string {
+	return c.provider.Name
+}
+
+// Rate-limited HTTP GET request of a path relative to the root of the Jenkins instance
+func (c JenkinsClient) get(ctx context.Context, resourcePath string, query url.Values) (*bytes.Buffer, error) {
+	resourceURL, err := url.Parse(c.baseURL.String() + resourcePath)
+	if err != nil {
+		return nil, err
+	}
+	resourceURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", resourceURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" || c.token != "" {
+		req.SetBasicAuth(c.username, c.token)
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Status: resp.StatusCode,
+		}
+	}
+
+	return body, nil
+}
+
+func (c JenkinsClient) getJSON(ctx context.Context, resourcePath string, query url.Values, v interface{}) error {
+	body, err := c.get(ctx, resourcePath, query)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body.Bytes(), v)
+}
+
+// Extract the names of the nested jobs and the build number from the URL of a Jenkins build.
+// Job names are returned path-escaped.
+func (c JenkinsClient) parseBuildURL(u string) ([]string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return nil, 0, err
+	}
+	if v.Hostname() != c.baseURL.Hostname() {
+		return nil, 0, cache.ErrUnknownPipelineURL
+	}
+
+	// Jenkins may be served from a subdirectory of the host
+	prefix := c.baseURL.EscapedPath()
+	if !strings.HasPrefix(v.EscapedPath(), prefix+"/") {
+		return nil, 0, cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//    https://jenkins.example.com/job/name/42/
+	//    https://jenkins.example.com/job/folder/job/name/job/branch/42/console
+	cs := strings.FieldsFunc(strings.TrimPrefix(v.EscapedPath(), prefix), func(c rune) bool { return c == '/' })
+	jobs := make([]string, 0)
+	i := 0
+	for ; i+1 < len(cs) && cs[i] == "job"; i += 2 {
+		jobs = append(jobs, cs[i+1])
+	}
+	if len(jobs) == 0 || i >= len(cs) {
+		return nil, 0, cache.ErrUnknownPipelineURL
+	}
+
+	number, err := strconv.Atoi(cs[i])
+	if err != nil {
+		return nil, 0, cache.ErrUnknownPipelineURL
+	}
+
+	return jobs, number, nil
+}
+
+func jenkinsBuildPath(jobs []string, number int) string {
+	return fmt.Sprintf("/job/%s/%d", strings.Join(jobs, "/job/"), number)
+}
+
+func (c JenkinsClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	jobs, number, err := c.parseBuildURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, jobs, number)
+}
+
+func (c JenkinsClient) fetchPipeline(ctx context.Context, jobs []string, number int) (cache.Pipeline, error) {
+	buildPath := jenkinsBuildPath(jobs, number)
+
+	var build jenkinsBuild
+	query := url.Values{}
+	query.Set("tree", "number,url,building,result,timestamp,duration,fullDisplayName,actions[lastBuiltRevision[SHA1,branch[name]]]")
+	if err := c.getJSON(ctx, buildPath+"/api/json", query, &build); err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	// Only builds of Pipeline jobs are divided in stages
+	var description jenkinsDescription
+	if err := c.getJSON(ctx, buildPath+"/wfapi/describe", nil, &description); err != nil {
+		if err, ok := err.(HTTPError); !ok || err.Status != 404 {
+			return cache.Pipeline{}, err
+		}
+	}
+
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		name, err := url.PathUnescape(job)
+		if err != nil {
+			return cache.Pipeline{}, err
+		}
+		names = append(names, name)
+	}
+
+	return build.toPipeline(strings.Join(names, "/"), buildPath, description.Stages)
+}
+
+// Return the log of a build or of one of its stages
+func (c JenkinsClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	buildPath := step.Log.Key
+	if i := strings.Index(step.Log.Key, "/execution/node/"); i >= 0 {
+		buildPath = step.Log.Key[:i]
+		nodeID := strings.TrimPrefix(step.Log.Key[i:], "/execution/node/")
+
+		// The log of a single stage is only available through the Blue Ocean REST API
+		jobsPath, number := buildPath, ""
+		if j := strings.LastIndex(buildPath, "/"); j >= 0 {
+			jobsPath, number = buildPath[:j], buildPath[j+1:]
+		}
+		pipelinePath, err := c.blueOceanPipelinePath(ctx, jobsPath)
+		if err == nil {
+			var body *bytes.Buffer
+			logPath := fmt.Sprintf("/blue/rest/organizations/jenkins/pipelines/%s/runs/%s/nodes/%s/log/", pipelinePath, number, nodeID)
+			if body, err = c.get(ctx, logPath, nil); err == nil {
+				return body.String(), nil
+			}
+		}
+		// Fall back to the log of the whole build if Blue Ocean is not installed
+		if err, ok := err.(HTTPError); !ok || err.Status != 404 {
+			return "", err
+		}
+	}
+
+	body, err := c.get(ctx, buildPath+"/consoleText", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+// Return the path of the job 'jobsPath' relative to the pipelines of the Blue Ocean REST API.
+// Nested jobs are found under "pipelines" except for the branches of a multibranch project which
+// are found under "branches": "/job/folder/job/name" becomes "folder/pipelines/name" but
+// "/job/repo/job/master" becomes "repo/branches/master" if "repo" is a multibranch project.
+func (c JenkinsClient) blueOceanPipelinePath(ctx context.Context, jobsPath string) (string, error) {
+	jobs := strings.Split(strings.TrimPrefix(jobsPath, "/job/"), "/job/")
+	if len(jobs) == 1 {
+		return jobs[0], nil
+	}
+
+	parentPath := "/job/" + strings.Join(jobs[:len(jobs)-1], "/job/")
+	var parent struct {
+		Class string `json:"_class"`
+	}
+	query := url.Values{}
+	query.Set("tree", "_class")
+	if err := c.getJSON(ctx, parentPath+"/api/json", query, &parent); err != nil {
+		return "", err
+	}
+
+	separator := "/pipelines/"
+	if strings.HasSuffix(parent.Class, "MultiBranchProject") {
+		separator = "/branches/"
+	}
+	pipelinePath := strings.Join(jobs[:len(jobs)-1], "/pipelines/")
+
+	return pipelinePath + separator + jobs[len(jobs)-1], nil
+}
+
+func fromJenkinsState(s string) cache.State {
+	switch strings.ToUpper(s) {
+	case "QUEUED":
+		return cache.Pending
+	case "IN_PROGRESS":
+		return cache.Running
+	case "PAUSED_PENDING_INPUT":
+		return cache.Manual
+	case "SUCCESS":
+		return cache.Passed
+	case "FAILED", "FAILURE", "UNSTABLE":
+		return cache.Failed
+	case "ABORTED":
+		return cache.Canceled
+	case "NOT_EXECUTED", "NOT_BUILT":
+		return cache.Skipped
+	default:
+		return cache.Unknown
+	}
+}
+
+func fromJenkinsMillis(ms int64) utils.NullTime {
+	if ms <= 0 {
+		return utils.NullTime{}
+	}
+	return utils.NullTime{
+		Valid: true,
+		Time:  time.Unix(0, ms*int64(time.Millisecond)).UTC(),
+	}
+}
+
+type jenkinsBuild struct {
+	Number          int    `json:"number"`
+	URL             string `json:"url"`
+	Building        bool   `json:"building"`
+	Result          string `json:"result"`
+	Timestamp       int64  `json:"timestamp"`
+	Duration        int64  `json:"duration"`
+	FullDisplayName string `json:"fullDisplayName"`
+	Actions         []struct {
+		LastBuiltRevision *struct {
+			SHA1   string `json:"SHA1"`
+			Branch []struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"lastBuiltRevision"`
+	} `json:"actions"`
+}
+
+func (b jenkinsBuild) toPipeline(jobName string, buildPath string, stages []jenkinsStage) (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(b.Number),
+		Step: cache.Step{
+			ID:        fmt.Sprintf("%s/%d", jobName, b.Number),
+			Name:      b.FullDisplayName,
+			Type:      cache.StepPipeline,
+			State:     fromJenkinsState(b.Result),
+			CreatedAt: fromJenkinsMillis(b.Timestamp),
+			StartedAt: fromJenkinsMillis(b.Timestamp),
+			WebURL: utils.NullString{
+				String: b.URL,
+				Valid:  b.URL != "",
+			},
+			Log: cache.Log{
+				Key: buildPath,
+			},
+		},
+	}
+
+	// The first revision listed is the one of the repository containing the Jenkinsfile.
+	// Others may be shared libraries.
+	for _, action := range b.Actions {
+		if action.LastBuiltRevision == nil {
+			continue
+		}
+		pipeline.SHA = action.LastBuiltRevision.SHA1
+		if len(action.LastBuiltRevision.Branch) > 0 {
+			ref := action.LastBuiltRevision.Branch[0].Name
+			for _, prefix := range []string{"refs/remotes/origin/", "origin/", "refs/heads/"} {
+				ref = strings.TrimPrefix(ref, prefix)
+			}
+			if strings.HasPrefix(ref, "refs/tags/") {
+				ref = strings.TrimPrefix(ref, "refs/tags/")
+				pipeline.IsTag = true
+			}
+			pipeline.Ref = ref
+		}
+		break
+	}
+
+	if b.Building {
+		pipeline.State = cache.Running
+	} else {
+		pipeline.FinishedAt = fromJenkinsMillis(b.Timestamp + b.Duration)
+		pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+	}
+
+	updatedAt := utils.MaxNullTime(pipeline.FinishedAt, pipeline.StartedAt)
+	for _, s := range stages {
+		stage := s.toStep(buildPath, pipeline.WebURL)
+		updatedAt = utils.MaxNullTime(updatedAt, stage.StartedAt, stage.FinishedAt)
+		pipeline.Children = append(pipeline.Children, stage)
+	}
+	if !updatedAt.Valid {
+		return pipeline, errors.New("updatedAt attribute cannot be null")
+	}
+	pipeline.UpdatedAt = updatedAt.Time
+
+	return pipeline, nil
+}
+
+type jenkinsDescription struct {
+	Stages []jenkinsStage `json:"stages"`
+}
+
+type jenkinsStage struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	StartTimeMillis int64  `json:"startTimeMillis"`
+	DurationMillis  int64  `json:"durationMillis"`
+}
+
+func (s jenkinsStage) toStep(buildPath string, webURL utils.NullString) cache.Step {
+	step := cache.Step{
+		ID:        s.ID,
+		Name:      s.Name,
+		Type:      cache.StepStage,
+		State:     fromJenkinsState(s.Status),
+		StartedAt: fromJenkinsMillis(s.StartTimeMillis),
+		WebURL:    webURL,
+		Log: cache.Log{
+			Key: fmt.Sprintf("%s/execution/node/%s", buildPath, s.ID),
+		},
+	}
+
+	if !step.State.IsActive() && step.State != cache.Manual && step.StartedAt.Valid {
+		step.FinishedAt = fromJenkinsMillis(s.StartTimeMillis + s.DurationMillis)
+		step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+	}
+
+	return step
+}
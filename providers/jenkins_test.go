@@ -0,0 +1,336 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func setupJenkinsTestServer(t *testing.T, blueOcean bool) (JenkinsClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch {
+		case r.URL.Path == "/jenkins/job/citop/job/master/12/api/json" && r.URL.Query().Get("tree") != "":
+			filename = "jenkins_build.json"
+		case r.URL.Path == "/jenkins/job/citop/job/master/12/wfapi/describe":
+			filename = "jenkins_describe.json"
+		case r.URL.Path == "/jenkins/job/citop/job/master/12/consoleText":
+			filename = "jenkins_console"
+		case r.URL.Path == "/jenkins/blue/rest/organizations/jenkins/pipelines/citop/pipelines/master/runs/12/nodes/13/log/" && blueOcean:
+			filename = "jenkins_stage_log"
+		case r.URL.Path == "/jenkins/job/citop/api/json" && r.URL.Query().Get("tree") == "_class":
+			filename = "jenkins_folder.json"
+		case r.URL.Path == "/jenkins/job/repo/api/json" && r.URL.Query().Get("tree") == "_class":
+			filename = "jenkins_multibranch.json"
+		case r.URL.Path == "/jenkins/blue/rest/organizations/jenkins/pipelines/repo/branches/feature%2Fdoc/runs/7/nodes/13/log/" && blueOcean:
+			filename = "jenkins_stage_log"
+		case r.URL.Path == "/jenkins/job/citop-freestyle/3/api/json":
+			filename = "jenkins_freestyle_build.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "jenkins", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+
+		// Rewrite URLs in the file to match the scheme and host of the query
+		s := strings.Replace(string(bs), "https://example.com", "http://"+r.Host, -1)
+		if _, err := fmt.Fprint(w, s); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	u, err := url.Parse(ts.URL + "/jenkins/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewJenkinsClient("jenkins", "jenkins", *u, "nbedos", "token", time.Millisecond)
+	client.httpClient = ts.Client()
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestJenkinsClient_parseBuildURL(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "jenkins.example.com", Path: "/ci"}
+	client := NewJenkinsClient("jenkins", "jenkins", u, "", "", time.Millisecond)
+
+	testCases := []struct {
+		url    string
+		jobs   []string
+		number int
+	}{
+		{
+			url:    "https://jenkins.example.com/ci/job/citop/42/",
+			jobs:   []string{"citop"},
+			number: 42,
+		},
+		{
+			url:    "https://jenkins.example.com/ci/job/nbedos/job/citop/job/feature%252Fjenkins/7/console",
+			jobs:   []string{"nbedos", "citop", "feature%252Fjenkins"},
+			number: 7,
+		},
+		{
+			url:    "https://jenkins.example.com/ci/job/citop/42/display/redirect",
+			jobs:   []string{"citop"},
+			number: 42,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			jobs, number, err := client.parseBuildURL(testCase.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.jobs, jobs); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+			if number != testCase.number {
+				t.Fatalf("expected build number %d but got %d", testCase.number, number)
+			}
+		})
+	}
+
+	invalidURLs := []string{
+		"https://jenkins.example.com/job/citop/42/",
+		"https://jenkins.example.com/ci/job/citop/",
+		"https://jenkins.example.com/ci/job/citop/lastBuild/",
+		"https://example.com/ci/job/citop/42/",
+	}
+	for _, u := range invalidURLs {
+		t.Run(u, func(t *testing.T) {
+			if _, _, err := client.parseBuildURL(u); err != cache.ErrUnknownPipelineURL {
+				t.Fatalf("expected %v but got %v", cache.ErrUnknownPipelineURL, err)
+			}
+		})
+	}
+}
+
+func TestJenkinsClient_BuildFromURL(t *testing.T) {
+	t.Run("pipeline job", func(t *testing.T) {
+		client, testURL, teardown := setupJenkinsTestServer(t, true)
+		defer teardown()
+
+		buildURL := testURL + "/jenkins/job/citop/job/master/12/"
+		pipeline, err := client.BuildFromURL(context.Background(), buildURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		webURL := utils.NullString{
+			String: buildURL,
+			Valid:  true,
+		}
+		expectedPipeline := cache.Pipeline{
+			Number: "12",
+			GitReference: cache.GitReference{
+				SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+				Ref: "master",
+			},
+			Step: cache.Step{
+				ID:    "citop/master/12",
+				Name:  "citop » master #12",
+				Type:  cache.StepPipeline,
+				State: cache.Failed,
+				CreatedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2020, 1, 6, 8, 48, 32, 104000000, time.UTC),
+				},
+				StartedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2020, 1, 6, 8, 48, 32, 104000000, time.UTC),
+				},
+				FinishedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2020, 1, 6, 8, 50, 8, 318000000, time.UTC),
+				},
+				UpdatedAt: time.Date(2020, 1, 6, 8, 50, 8, 318000000, time.UTC),
+				Duration: utils.NullDuration{
+					Valid:    true,
+					Duration: time.Minute + 36*time.Second + 214*time.Millisecond,
+				},
+				WebURL: webURL,
+				Log: cache.Log{
+					Key: "/job/citop/job/master/12",
+				},
+				Children: []cache.Step{
+					{
+						ID:    "6",
+						Name:  "Build",
+						Type:  cache.StepStage,
+						State: cache.Passed,
+						StartedAt: utils.NullTime{
+							Valid: true,
+							Time:  time.Date(2020, 1, 6, 8, 48, 34, 231000000, time.UTC),
+						},
+						FinishedAt: utils.NullTime{
+							Valid: true,
+							Time:  time.Date(2020, 1, 6, 8, 49, 6, 249000000, time.UTC),
+						},
+						Duration: utils.NullDuration{
+							Valid:    true,
+							Duration: 32*time.Second + 18*time.Millisecond,
+						},
+						WebURL: webURL,
+						Log: cache.Log{
+							Key: "/job/citop/job/master/12/execution/node/6",
+						},
+					},
+					{
+						ID:    "13",
+						Name:  "Test",
+						Type:  cache.StepStage,
+						State: cache.Failed,
+						StartedAt: utils.NullTime{
+							Valid: true,
+							Time:  time.Date(2020, 1, 6, 8, 49, 6, 249000000, time.UTC),
+						},
+						FinishedAt: utils.NullTime{
+							Valid: true,
+							Time:  time.Date(2020, 1, 6, 8, 50, 8, 52000000, time.UTC),
+						},
+						Duration: utils.NullDuration{
+							Valid:    true,
+							Duration: time.Minute + 1*time.Second + 803*time.Millisecond,
+						},
+						WebURL: webURL,
+						Log: cache.Log{
+							Key: "/job/citop/job/master/12/execution/node/13",
+						},
+					},
+					{
+						ID:     "21",
+						Name:   "Deploy",
+						Type:   cache.StepStage,
+						State:  cache.Skipped,
+						WebURL: webURL,
+						Log: cache.Log{
+							Key: "/job/citop/job/master/12/execution/node/21",
+						},
+					},
+				},
+			},
+		}
+
+		if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("freestyle job", func(t *testing.T) {
+		client, testURL, teardown := setupJenkinsTestServer(t, true)
+		defer teardown()
+
+		buildURL := testURL + "/jenkins/job/citop-freestyle/3/"
+		pipeline, err := client.BuildFromURL(context.Background(), buildURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectedPipeline := cache.Pipeline{
+			Number: "3",
+			GitReference: cache.GitReference{
+				SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+				Ref: "master",
+			},
+			Step: cache.Step{
+				ID:    "citop-freestyle/3",
+				Name:  "citop-freestyle #3",
+				Type:  cache.StepPipeline,
+				State: cache.Running,
+				CreatedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2020, 1, 6, 8, 48, 32, 104000000, time.UTC),
+				},
+				StartedAt: utils.NullTime{
+					Valid: true,
+					Time:  time.Date(2020, 1, 6, 8, 48, 32, 104000000, time.UTC),
+				},
+				UpdatedAt: time.Date(2020, 1, 6, 8, 48, 32, 104000000, time.UTC),
+				WebURL: utils.NullString{
+					String: buildURL,
+					Valid:  true,
+				},
+				Log: cache.Log{
+					Key: "/job/citop-freestyle/3",
+				},
+			},
+		}
+
+		if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestJenkinsClient_Log(t *testing.T) {
+	testCases := []struct {
+		name      string
+		blueOcean bool
+		key       string
+		expected  string
+	}{
+		{
+			name:      "build",
+			blueOcean: true,
+			key:       "/job/citop/job/master/12",
+			expected:  "Started by user nbedos\n[Pipeline] Start of Pipeline\n[Pipeline] End of Pipeline\nFinished: FAILURE\n",
+		},
+		{
+			name:      "stage",
+			blueOcean: true,
+			key:       "/job/citop/job/master/12/execution/node/13",
+			expected:  "+ go test ./...\n--- FAIL: TestCommit (0.00s)\n",
+		},
+		{
+			name:      "stage of a branch of a multibranch project",
+			blueOcean: true,
+			key:       "/job/repo/job/feature%252Fdoc/7/execution/node/13",
+			expected:  "+ go test ./...\n--- FAIL: TestCommit (0.00s)\n",
+		},
+		{
+			name:      "stage without Blue Ocean",
+			blueOcean: false,
+			key:       "/job/citop/job/master/12/execution/node/13",
+			expected:  "Started by user nbedos\n[Pipeline] Start of Pipeline\n[Pipeline] End of Pipeline\nFinished: FAILURE\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, _, teardown := setupJenkinsTestServer(t, testCase.blueOcean)
+			defer teardown()
+
+			step := cache.Step{
+				Log: cache.Log{
+					Key: testCase.key,
+				},
+			}
+			log, err := client.Log(context.Background(), step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, log); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -338,10 +339,27 @@ func (c TravisClient) webURL(slug string) (url.URL, error) {
 
 // Rate-limited HTTP GET request with custom headers
 func (c TravisClient) get(ctx context.Context, method string, resourceURL url.URL) (*bytes.Buffer, error) {
-	req, err := http.NewRequest(method, resourceURL.String(), nil)
+	return c.request(ctx, method, resourceURL, nil)
+}
+
+// Rate-limited HTTP request with custom headers. If v is not nil, it is sent as the JSON body of
+// the request.
+func (c TravisClient) request(ctx context.Context, method string, resourceURL url.URL, v interface{}) (*bytes.Buffer, error) {
+	var reqBody io.Reader
+	if v != nil {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, resourceURL.String(), reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if v != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 	req.Header.Add("Travis-API-Version", "3")
 	if c.token != "" {
 		req.Header.Add("Authorization", fmt.Sprintf("token %s", c.token))
@@ -438,3 +456,60 @@ func (c TravisClient) Log(ctx context.Context, step cache.Step) (string, error)
 
 	return log.Content, nil
 }
+
+// Return the path of the API resource of the pipeline or job 'step'. Stages cannot be acted upon
+// with the API of Travis CI.
+func travisStepResource(step cache.Step) (string, error) {
+	switch step.Type {
+	case cache.StepPipeline:
+		return fmt.Sprintf("/build/%s", url.PathEscape(step.ID)), nil
+	case cache.StepJob:
+		return fmt.Sprintf("/job/%s", url.PathEscape(step.ID)), nil
+	default:
+		return "", cache.ErrNotSupported
+	}
+}
+
+func (c TravisClient) Restart(ctx context.Context, step cache.Step) error {
+	resource, err := travisStepResource(step)
+	if err != nil {
+		return err
+	}
+	reqURL := c.baseURL
+	reqURL.Path += resource + "/restart"
+
+	_, err = c.get(ctx, "POST", reqURL)
+	return err
+}
+
+func (c TravisClient) Cancel(ctx context.Context, step cache.Step) error {
+	resource, err := travisStepResource(step)
+	if err != nil {
+		return err
+	}
+	reqURL := c.baseURL
+	reqURL.Path += resource + "/cancel"
+
+	_, err = c.get(ctx, "POST", reqURL)
+	return err
+}
+
+func (c TravisClient) Trigger(ctx context.Context, repo string, ref string) error {
+	_, owner, name, err := utils.RepoHostOwnerAndName(repo)
+	if err != nil {
+		return cache.ErrUnknownRepositoryURL
+	}
+	// The slug of the repository is a single path component so its slash must be escaped
+	slug := fmt.Sprintf("%s/%s", owner, name)
+	reqURL := c.baseURL
+	reqURL.Path += fmt.Sprintf("/repo/%s/requests", slug)
+	reqURL.RawPath = c.baseURL.EscapedPath() + fmt.Sprintf("/repo/%s/requests", url.PathEscape(slug))
+
+	body := map[string]interface{}{
+		"request": map[string]string{
+			"branch": ref,
+		},
+	}
+	_, err = c.request(ctx, "POST", reqURL, body)
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/utils"
 )
@@ -246,3 +247,49 @@ func TestParseTravisWebURL(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestTravisClient_actions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.EscapedPath(), body))
+		w.WriteHeader(202)
+	}))
+	defer ts.Close()
+
+	URL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := TravisClient{
+		baseURL:     *URL,
+		httpClient:  ts.Client(),
+		rateLimiter: time.Tick(time.Millisecond),
+	}
+	ctx := context.Background()
+
+	if err := client.Restart(ctx, cache.Step{ID: "1", Type: cache.StepPipeline}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Cancel(ctx, cache.Step{ID: "2", Type: cache.StepJob}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Trigger(ctx, "https://github.com/nbedos/citop", "feature/x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Restart(ctx, cache.Step{ID: "3", Type: cache.StepStage}); err != cache.ErrNotSupported {
+		t.Fatalf("expected %v but got %v", cache.ErrNotSupported, err)
+	}
+
+	expected := []string{
+		"POST /build/1/restart ",
+		"POST /job/2/cancel ",
+		`POST /repo/nbedos%2Fcitop/requests {"request":{"branch":"feature/x"}}`,
+	}
+	if diff := cmp.Diff(expected, requests); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
@@ -0,0 +1,327 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+// WoodpeckerClient is a CI provider for Woodpecker CI, a community fork of Drone.
+type WoodpeckerClient struct {
+	baseURL     url.URL
+	httpClient  *http.Client
+	rateLimiter <-chan time.Time
+	token       string
+	provider    cache.Provider
+}
+
+func NewWoodpeckerClient(id string, name string, token string, URL url.URL, rateLimit time.Duration) WoodpeckerClient {
+	URL.Path = strings.TrimSuffix(URL.Path, "/")
+	URL.RawPath = strings.TrimSuffix(URL.RawPath, "/")
+
+	return WoodpeckerClient{
+		baseURL:     URL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		rateLimiter: time.Tick(rateLimit),
+		token:       token,
+		provider: cache.Provider{
+			ID:   id,
+			Name: name,
+		},
+	}
+}
+
+func (c WoodpeckerClient) ID() string {
+	return c.provider.ID
+}
+
+func (c WoodpeckerClient) Host() string {
+	return c.baseURL.Host
+}
+
+func (c WoodpeckerClient) Name() string {
+	return c.provider.Name
+}
+
+// Rate-limited HTTP GET request with authentication
+func (c WoodpeckerClient) get(ctx context.Context, resourceURL string) (*bytes.Buffer, error) {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+	req = req.WithContext(ctx)
+
+	select {
+	case <-c.rateLimiter:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, HTTPError{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Status:  resp.StatusCode,
+			Message: strings.TrimSpace(body.String()),
+		}
+	}
+
+	return body, nil
+}
+
+// Extract the path identifying the repository in the API (either "owner/repo" or the numerical
+// ID of the repository) and the pipeline number from the web URL of a pipeline
+func (c WoodpeckerClient) parsePipelineURL(u string) (string, int, error) {
+	v, err := url.Parse(u)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if v.Hostname() != c.baseURL.Hostname() {
+		return "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	// URL formats:
+	//    https://woodpecker.example.com/repos/12/pipeline/42 (Woodpecker >= 2.0)
+	//    https://woodpecker.example.com/owner/repo/pipeline/42
+	//    https://woodpecker.example.com/owner/repo/pipeline/42/3
+	cs := strings.FieldsFunc(v.EscapedPath(), func(c rune) bool { return c == '/' })
+	if len(cs) < 4 || cs[2] != "pipeline" {
+		return "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	number, err := strconv.Atoi(cs[3])
+	if err != nil {
+		return "", 0, cache.ErrUnknownPipelineURL
+	}
+
+	if cs[0] == "repos" {
+		if _, err := strconv.Atoi(cs[1]); err == nil {
+			return cs[1], number, nil
+		}
+	}
+
+	return cs[0] + "/" + cs[1], number, nil
+}
+
+func (c WoodpeckerClient) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	repo, number, err := c.parsePipelineURL(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	return c.fetchPipeline(ctx, repo, number, u)
+}
+
+func (c WoodpeckerClient) fetchPipeline(ctx context.Context, repo string, number int, u string) (cache.Pipeline, error) {
+	repoURL := c.baseURL
+	repoURL.Path += "/api/repos/" + repo
+	repoURL.RawPath = c.baseURL.EscapedPath() + "/api/repos/" + repo
+
+	endpoint := repoURL
+	endpoint.Path += fmt.Sprintf("/pipelines/%d", number)
+	endpoint.RawPath += fmt.Sprintf("/pipelines/%d", number)
+
+	body, err := c.get(ctx, endpoint.String())
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	var pipeline woodpeckerPipeline
+	if err := json.Unmarshal(body.Bytes(), &pipeline); err != nil {
+		return cache.Pipeline{}, err
+	}
+
+	// Remove the eventual step number from the web URL
+	webURL, err := url.Parse(u)
+	if err != nil {
+		return cache.Pipeline{}, err
+	}
+	if i := strings.Index(webURL.Path, fmt.Sprintf("/pipeline/%d", number)); i >= 0 {
+		webURL.Path = webURL.Path[:i] + fmt.Sprintf("/pipeline/%d", number)
+		webURL.RawPath = ""
+	}
+	webURL.RawQuery = ""
+	webURL.Fragment = ""
+
+	logsURL := repoURL
+	logsURL.Path += fmt.Sprintf("/logs/%d", number)
+	logsURL.RawPath += fmt.Sprintf("/logs/%d", number)
+
+	return pipeline.toPipeline(webURL.String(), logsURL.String())
+}
+
+func (c WoodpeckerClient) Log(ctx context.Context, step cache.Step) (string, error) {
+	if step.Log.Key == "" {
+		return "", cache.ErrNoLogHere
+	}
+
+	body, err := c.get(ctx, step.Log.Key)
+	if err != nil {
+		if err, ok := err.(HTTPError); ok && err.Status == 404 {
+			// Steps that did not run have no log
+			return "", nil
+		}
+		return "", err
+	}
+
+	// Lines are base64-encoded by the API which encoding/json takes care of decoding since
+	// Data is a byte slice
+	var lines []struct {
+		Data []byte `json:"data"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &lines); err != nil {
+		return "", err
+	}
+
+	builder := strings.Builder{}
+	for _, line := range lines {
+		builder.Write(line.Data)
+		builder.WriteByte('\n')
+	}
+
+	return builder.String(), nil
+}
+
+// Woodpecker inherited its statuses from Drone
+func fromWoodpeckerStatus(status string) cache.State {
+	return fromDroneStatus(status)
+}
+
+type woodpeckerPipeline struct {
+	ID         int                  `json:"id"`
+	Number     int                  `json:"number"`
+	Status     string               `json:"status"`
+	Event      string               `json:"event"`
+	Ref        string               `json:"ref"`
+	Commit     string               `json:"commit"`
+	Branch     string               `json:"branch"`
+	CreatedAt  int64                `json:"created_at"`
+	UpdatedAt  int64                `json:"updated_at"`
+	StartedAt  int64                `json:"started_at"`
+	FinishedAt int64                `json:"finished_at"`
+	Workflows  []woodpeckerWorkflow `json:"workflows"`
+}
+
+func (p woodpeckerPipeline) toPipeline(webURL string, logsURL string) (cache.Pipeline, error) {
+	pipeline := cache.Pipeline{
+		Number: strconv.Itoa(p.Number),
+		GitReference: cache.GitReference{
+			SHA: p.Commit,
+			Ref: p.Branch,
+		},
+		Step: cache.Step{
+			ID:         strconv.Itoa(p.ID),
+			Type:       cache.StepPipeline,
+			State:      fromWoodpeckerStatus(p.Status),
+			CreatedAt:  fromUnixTimestamp(p.CreatedAt),
+			StartedAt:  fromUnixTimestamp(p.StartedAt),
+			FinishedAt: fromUnixTimestamp(p.FinishedAt),
+			WebURL: utils.NullString{
+				String: webURL,
+				Valid:  true,
+			},
+		},
+	}
+	if strings.HasPrefix(p.Ref, "refs/tags/") {
+		pipeline.Ref = strings.TrimPrefix(p.Ref, "refs/tags/")
+		pipeline.IsTag = true
+	}
+	pipeline.Duration = utils.NullSub(pipeline.FinishedAt, pipeline.StartedAt)
+
+	updatedAt := utils.MaxNullTime(fromUnixTimestamp(p.UpdatedAt), pipeline.FinishedAt, pipeline.StartedAt, pipeline.CreatedAt)
+	for _, w := range p.Workflows {
+		workflow := w.toStep(webURL, logsURL)
+		updatedAt = utils.MaxNullTime(updatedAt, workflow.StartedAt, workflow.FinishedAt)
+		pipeline.Children = append(pipeline.Children, workflow)
+	}
+	if !updatedAt.Valid {
+		return pipeline, errors.New("updatedAt attribute cannot be null")
+	}
+	pipeline.UpdatedAt = updatedAt.Time
+
+	return pipeline, nil
+}
+
+type woodpeckerWorkflow struct {
+	ID       int              `json:"id"`
+	PID      int              `json:"pid"`
+	Name     string           `json:"name"`
+	State    string           `json:"state"`
+	Started  int64            `json:"start_time"`
+	Stopped  int64            `json:"end_time"`
+	Children []woodpeckerStep `json:"children"`
+}
+
+func (w woodpeckerWorkflow) toStep(webURL string, logsURL string) cache.Step {
+	step := cache.Step{
+		ID:         strconv.Itoa(w.PID),
+		Name:       w.Name,
+		Type:       cache.StepJob,
+		State:      fromWoodpeckerStatus(w.State),
+		StartedAt:  fromUnixTimestamp(w.Started),
+		FinishedAt: fromUnixTimestamp(w.Stopped),
+		WebURL: utils.NullString{
+			String: webURL,
+			Valid:  true,
+		},
+	}
+	step.Duration = utils.NullSub(step.FinishedAt, step.StartedAt)
+
+	for _, s := range w.Children {
+		task := cache.Step{
+			ID:           strconv.Itoa(s.PID),
+			Name:         s.Name,
+			Type:         cache.StepTask,
+			State:        fromWoodpeckerStatus(s.State),
+			AllowFailure: s.Failure == "ignore",
+			StartedAt:    fromUnixTimestamp(s.Started),
+			FinishedAt:   fromUnixTimestamp(s.Stopped),
+			WebURL: utils.NullString{
+				String: fmt.Sprintf("%s/%d", webURL, s.PID),
+				Valid:  true,
+			},
+			Log: cache.Log{
+				Key: fmt.Sprintf("%s/%d", logsURL, s.ID),
+			},
+		}
+		task.Duration = utils.NullSub(task.FinishedAt, task.StartedAt)
+		step.Children = append(step.Children, task)
+	}
+
+	return step
+}
+
+type woodpeckerStep struct {
+	ID      int    `json:"id"`
+	PID     int    `json:"pid"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Failure string `json:"failure"`
+	Started int64  `json:"start_time"`
+	Stopped int64  `json:"end_time"`
+}
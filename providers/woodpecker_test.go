@@ -0,0 +1,285 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/utils"
+)
+
+func setupWoodpeckerTestServer(t *testing.T) (WoodpeckerClient, string, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filename := ""
+		switch r.URL.Path {
+		case "/api/repos/12/pipelines/42", "/api/repos/nbedos/citop/pipelines/42":
+			filename = "woodpecker_pipeline.json"
+		case "/api/repos/12/logs/42/10412":
+			filename = "woodpecker_log.json"
+		default:
+			w.WriteHeader(404)
+			return
+		}
+
+		bs, err := ioutil.ReadFile(path.Join("test_data", "woodpecker", filename))
+		if err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+		if _, err := fmt.Fprint(w, string(bs)); err != nil {
+			w.WriteHeader(500)
+			fmt.Fprint(w, err.Error())
+			return
+		}
+	}))
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewWoodpeckerClient("woodpecker", "woodpecker", "token", *u, time.Millisecond)
+	client.httpClient = ts.Client()
+
+	return client, ts.URL, func() { ts.Close() }
+}
+
+func TestWoodpeckerClient_parsePipelineURL(t *testing.T) {
+	u := url.URL{Scheme: "https", Host: "ci.example.com"}
+	client := NewWoodpeckerClient("woodpecker", "woodpecker", "", u, time.Millisecond)
+
+	testCases := []struct {
+		url    string
+		repo   string
+		number int
+		err    error
+	}{
+		{
+			url:    "https://ci.example.com/repos/12/pipeline/42",
+			repo:   "12",
+			number: 42,
+		},
+		{
+			url:    "https://ci.example.com/repos/12/pipeline/42/3",
+			repo:   "12",
+			number: 42,
+		},
+		{
+			url:    "https://ci.example.com/nbedos/citop/pipeline/42",
+			repo:   "nbedos/citop",
+			number: 42,
+		},
+		{
+			url: "https://ci.example.com/repos/12/settings",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://ci.example.com/repos/12/pipeline/latest",
+			err: cache.ErrUnknownPipelineURL,
+		},
+		{
+			url: "https://example.com/repos/12/pipeline/42",
+			err: cache.ErrUnknownPipelineURL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			repo, number, err := client.parsePipelineURL(testCase.url)
+			if err != testCase.err {
+				t.Fatalf("expected error %v but got %v", testCase.err, err)
+			}
+			if repo != testCase.repo || number != testCase.number {
+				t.Fatalf("unexpected result: %q, %d", repo, number)
+			}
+		})
+	}
+}
+
+func TestWoodpeckerClient_BuildFromURL(t *testing.T) {
+	client, testURL, teardown := setupWoodpeckerTestServer(t)
+	defer teardown()
+
+	pipeline, err := client.BuildFromURL(context.Background(), testURL+"/repos/12/pipeline/42/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webURL := testURL + "/repos/12/pipeline/42"
+	logsURL := testURL + "/api/repos/12/logs/42"
+	expectedPipeline := cache.Pipeline{
+		Number: "42",
+		GitReference: cache.GitReference{
+			SHA:   "a24840cf94b395af69da4a1001d32e3694637e20",
+			Ref:   "0.2.0",
+			IsTag: true,
+		},
+		Step: cache.Step{
+			ID:    "2311",
+			Type:  cache.StepPipeline,
+			State: cache.Failed,
+			CreatedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 18, 28, 0, time.UTC),
+			},
+			StartedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+			},
+			FinishedAt: utils.NullTime{
+				Valid: true,
+				Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+			},
+			UpdatedAt: time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+			Duration: utils.NullDuration{
+				Valid:    true,
+				Duration: time.Minute + 32*time.Second,
+			},
+			WebURL: utils.NullString{
+				Valid:  true,
+				String: webURL,
+			},
+			Children: []cache.Step{
+				{
+					ID:    "1",
+					Name:  "test",
+					Type:  cache.StepJob,
+					State: cache.Failed,
+					StartedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+					},
+					FinishedAt: utils.NullTime{
+						Valid: true,
+						Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+					},
+					Duration: utils.NullDuration{
+						Valid:    true,
+						Duration: time.Minute + 32*time.Second,
+					},
+					WebURL: utils.NullString{
+						Valid:  true,
+						String: webURL,
+					},
+					Children: []cache.Step{
+						{
+							ID:    "2",
+							Name:  "clone",
+							Type:  cache.StepTask,
+							State: cache.Passed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 30, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 33, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: 3 * time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/2",
+							},
+							Log: cache.Log{
+								Key: logsURL + "/10411",
+							},
+						},
+						{
+							ID:    "3",
+							Name:  "test",
+							Type:  cache.StepTask,
+							State: cache.Failed,
+							StartedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 18, 33, 0, time.UTC),
+							},
+							FinishedAt: utils.NullTime{
+								Valid: true,
+								Time:  time.Date(2020, 1, 7, 10, 20, 2, 0, time.UTC),
+							},
+							Duration: utils.NullDuration{
+								Valid:    true,
+								Duration: time.Minute + 29*time.Second,
+							},
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/3",
+							},
+							Log: cache.Log{
+								Key: logsURL + "/10412",
+							},
+						},
+						{
+							ID:           "4",
+							Name:         "coverage",
+							Type:         cache.StepTask,
+							State:        cache.Skipped,
+							AllowFailure: true,
+							WebURL: utils.NullString{
+								Valid:  true,
+								String: webURL + "/4",
+							},
+							Log: cache.Log{
+								Key: logsURL + "/10413",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if diff := expectedPipeline.Diff(pipeline); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+}
+
+func TestWoodpeckerClient_Log(t *testing.T) {
+	client, testURL, teardown := setupWoodpeckerTestServer(t)
+	defer teardown()
+
+	testCases := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{
+			name:     "step with log",
+			key:      testURL + "/api/repos/12/logs/42/10412",
+			expected: "+ go test ./...\n--- FAIL: TestCommit (0.00s)\n",
+		},
+		{
+			name:     "skipped step",
+			key:      testURL + "/api/repos/12/logs/42/10413",
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			step := cache.Step{
+				Log: cache.Log{
+					Key: testCase.key,
+				},
+			}
+			log, err := client.Log(context.Background(), step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testCase.expected, log); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package text
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// https://stackoverflow.com/questions/14693701/how-can-i-remove-the-ansi-escape-sequences-from-a-string-in-python
+var ansiEscapeSequence = regexp.MustCompile(`\x1b[@-_][0-?]*[ -/]*[@-~]`)
+
+// Number of colors of the ANSI palette: 8 normal colors followed by their 8 bright variants
+const ansiColors = 16
+
+// ANSIState holds the graphic rendition set by the SGR escape sequences read so far. The zero
+// value is the default rendition.
+type ANSIState struct {
+	bold       bool
+	dim        bool
+	underline  bool
+	blink      bool
+	reverse    bool
+	foreground Class
+	background Class
+}
+
+// Return the class of the foreground of index 'color' in the ANSI palette
+func ANSIForegroundClass(color int) Class {
+	return ANSIForeground + Class(color)
+}
+
+// Return the class of the background of index 'color' in the ANSI palette
+func ANSIBackgroundClass(color int) Class {
+	return ANSIBackground + Class(color)
+}
+
+func (s ANSIState) classes() []Class {
+	classes := make([]Class, 0)
+	for _, attribute := range []struct {
+		on    bool
+		class Class
+	}{
+		{s.bold, ANSIBold},
+		{s.dim, ANSIDim},
+		{s.underline, ANSIUnderline},
+		{s.blink, ANSIBlink},
+		{s.reverse, ANSIReverse},
+	} {
+		if attribute.on {
+			classes = append(classes, attribute.class)
+		}
+	}
+	if s.foreground != DefaultClass {
+		classes = append(classes, s.foreground)
+	}
+	if s.background != DefaultClass {
+		classes = append(classes, s.background)
+	}
+
+	return classes
+}
+
+// Update the state based on the parameters of an SGR sequence ("1;31" for "\x1b[1;31m")
+func (s *ANSIState) apply(parameters string) {
+	params := strings.Split(parameters, ";")
+	for i := 0; i < len(params); i++ {
+		// An empty parameter is equivalent to 0
+		n := 0
+		if params[i] != "" {
+			var err error
+			if n, err = strconv.Atoi(params[i]); err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case n == 0:
+			*s = ANSIState{}
+		case n == 1:
+			s.bold = true
+		case n == 2:
+			s.dim = true
+		case n == 4:
+			s.underline = true
+		case n == 5:
+			s.blink = true
+		case n == 7:
+			s.reverse = true
+		case n == 22:
+			s.bold, s.dim = false, false
+		case n == 24:
+			s.underline = false
+		case n == 25:
+			s.blink = false
+		case n == 27:
+			s.reverse = false
+		case n >= 30 && n <= 37:
+			s.foreground = ANSIForegroundClass(n - 30)
+		case n == 39:
+			s.foreground = DefaultClass
+		case n >= 40 && n <= 47:
+			s.background = ANSIBackgroundClass(n - 40)
+		case n == 49:
+			s.background = DefaultClass
+		case n >= 90 && n <= 97:
+			s.foreground = ANSIForegroundClass(n - 90 + 8)
+		case n >= 100 && n <= 107:
+			s.background = ANSIBackgroundClass(n - 100 + 8)
+		case n == 38 || n == 48:
+			// Extended colors: "38;5;n" for the 256 color palette or "38;2;r;g;b" for true
+			// colors. Only the first 16 colors of the 256 color palette can be rendered.
+			if i+1 >= len(params) {
+				break
+			}
+			switch params[i+1] {
+			case "5":
+				if i+2 < len(params) {
+					if color, err := strconv.Atoi(params[i+2]); err == nil && color < ansiColors {
+						if n == 38 {
+							s.foreground = ANSIForegroundClass(color)
+						} else {
+							s.background = ANSIBackgroundClass(color)
+						}
+					}
+				}
+				i += 2
+			case "2":
+				i += 4
+			}
+		}
+	}
+}
+
+// Turn 'content' into a StyledString by interpreting the SGR escape sequences it contains.
+// Other escape sequences are deleted. The state is updated so that it can be used for parsing
+// the content that follows.
+func (s *ANSIState) Parse(content string) StyledString {
+	styled := StyledString{
+		components: make([]elementaryString, 0),
+	}
+	start := 0
+	for _, match := range ansiEscapeSequence.FindAllStringIndex(content, -1) {
+		if match[0] > start {
+			styled.Append(content[start:match[0]], s.classes()...)
+		}
+		sequence := content[match[0]:match[1]]
+		if strings.HasPrefix(sequence, "\x1b[") && strings.HasSuffix(sequence, "m") {
+			s.apply(sequence[2 : len(sequence)-1])
+		}
+		start = match[1]
+	}
+	if start < len(content) || len(styled.components) == 0 {
+		styled.Append(content[start:], s.classes()...)
+	}
+
+	return styled
+}
+
+// Replace tabulations by spaces, with tab stops every 8 columns. Escape sequences are copied
+// as is and do not count as columns.
+func ExpandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+
+	builder := strings.Builder{}
+	column := 0
+	start := 0
+	expand := func(segment string) {
+		for _, r := range segment {
+			if r == '\t' {
+				n := 8 - column%8
+				builder.WriteString(strings.Repeat(" ", n))
+				column += n
+			} else {
+				builder.WriteRune(r)
+				column += runewidth.RuneWidth(r)
+			}
+		}
+	}
+	for _, match := range ansiEscapeSequence.FindAllStringIndex(s, -1) {
+		expand(s[start:match[0]])
+		builder.WriteString(s[match[0]:match[1]])
+		start = match[1]
+	}
+	expand(s[start:])
+
+	return builder.String()
+}
@@ -0,0 +1,104 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestANSIState_Parse(t *testing.T) {
+	testCases := []struct {
+		name       string
+		content    string
+		components []elementaryString
+	}{
+		{
+			name:    "no escape sequence",
+			content: "abc",
+			components: []elementaryString{
+				{Content: "abc", Classes: []Class{}},
+			},
+		},
+		{
+			name:    "foreground color and reset",
+			content: "\x1b[31mred\x1b[0m default",
+			components: []elementaryString{
+				{Content: "red", Classes: []Class{ANSIForegroundClass(1)}},
+				{Content: " default", Classes: []Class{}},
+			},
+		},
+		{
+			name:    "attributes and bright background",
+			content: "\x1b[1;4;102mbold\x1b[22m underline",
+			components: []elementaryString{
+				{Content: "bold", Classes: []Class{ANSIBold, ANSIUnderline, ANSIBackgroundClass(10)}},
+				{Content: " underline", Classes: []Class{ANSIUnderline, ANSIBackgroundClass(10)}},
+			},
+		},
+		{
+			name:    "256 colors",
+			content: "\x1b[38;5;3myellow\x1b[38;5;200mignored\x1b[38;2;1;2;3;1mbold",
+			components: []elementaryString{
+				{Content: "yellow", Classes: []Class{ANSIForegroundClass(3)}},
+				{Content: "ignored", Classes: []Class{ANSIForegroundClass(3)}},
+				{Content: "bold", Classes: []Class{ANSIBold, ANSIForegroundClass(3)}},
+			},
+		},
+		{
+			name:    "other escape sequences are deleted",
+			content: "\x1b[0Kabc\x1b[2J",
+			components: []elementaryString{
+				{Content: "abc", Classes: []Class{}},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			state := ANSIState{}
+			s := state.Parse(testCase.content)
+			if diff := cmp.Diff(testCase.components, s.components); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	t.Run("state is kept across calls", func(t *testing.T) {
+		state := ANSIState{}
+		state.Parse("\x1b[32mgreen")
+		s := state.Parse("still green")
+		expected := []elementaryString{
+			{Content: "still green", Classes: []Class{ANSIForegroundClass(2)}},
+		}
+		if diff := cmp.Diff(expected, s.components); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestExpandTabs(t *testing.T) {
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{
+			content:  "no tab",
+			expected: "no tab",
+		},
+		{
+			content:  "\tab\tc",
+			expected: "        ab      c",
+		},
+		{
+			// Escape sequences do not count as columns
+			content:  "\x1b[31mab\x1b[0m\tc",
+			expected: "\x1b[31mab\x1b[0m      c",
+		},
+	}
+
+	for _, testCase := range testCases {
+		if diff := cmp.Diff(testCase.expected, ExpandTabs(testCase.content)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	}
+}
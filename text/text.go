@@ -24,6 +24,15 @@ const (
 	StatusFailed
 	StatusSkipped
 	Provider
+	ANSIBold
+	ANSIDim
+	ANSIUnderline
+	ANSIBlink
+	ANSIReverse
+	// First of the 16 classes of foreground colors of the ANSI palette
+	ANSIForeground
+	// First of the 16 classes of background colors of the ANSI palette
+	ANSIBackground = ANSIForeground + ansiColors
 )
 
 type elementaryString struct {
@@ -96,6 +105,33 @@ func (s *StyledString) Align(alignment Alignment, width int) {
 	}
 }
 
+// Shorten s so that it fits in 'width' columns. The end of a truncated string is replaced by an
+// ellipsis.
+func (s *StyledString) Truncate(width int) {
+	if s.Length() <= width {
+		return
+	}
+	const ellipsis = "…"
+	width -= runewidth.StringWidth(ellipsis)
+	if width < 0 {
+		s.components = nil
+		return
+	}
+
+	components := make([]elementaryString, 0, len(s.components))
+	for _, c := range s.components {
+		if w := runewidth.StringWidth(c.Content); w < width {
+			components = append(components, c)
+			width -= w
+			continue
+		}
+		c.Content = runewidth.Truncate(c.Content, width, "") + ellipsis
+		components = append(components, c)
+		break
+	}
+	s.components = components
+}
+
 func (s StyledString) Contains(value string) bool {
 	b := bytes.NewBufferString("")
 	for _, c := range s.components {
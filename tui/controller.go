@@ -1,14 +1,13 @@
 package tui
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
-	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,28 +23,136 @@ const (
 	inputNone inputDestination = iota
 	inputSearch
 	inputRef
+	inputConfirmation
+	inputVariables
+	inputPalette
+	inputColumns
+	inputFilter
 )
 
+// Operation on the pipeline or step of the active row of the table
+type action struct {
+	// Question asked to the user before running the action
+	prompt string
+	// Description of the action shown in messages about its result
+	description string
+	run         func(source cache.HierarchicalTabularDataSource, ctx context.Context, key interface{}) error
+}
+
+var (
+	restartAction = action{
+		prompt:      "Restart the selected pipeline or step? [y/N] ",
+		description: "restart",
+		run:         cache.HierarchicalTabularDataSource.Restart,
+	}
+	cancelAction = action{
+		prompt:      "Cancel the selected pipeline or step? [y/N] ",
+		description: "cancel",
+		run:         cache.HierarchicalTabularDataSource.Cancel,
+	}
+	triggerAction = action{
+		prompt:      "Trigger a new pipeline for the selected git reference? [y/N] ",
+		description: "trigger",
+		run:         cache.HierarchicalTabularDataSource.Trigger,
+	}
+)
+
+// Return the action starting a manual step with the environment variables 'variables'
+func playAction(variables map[string]string) action {
+	return action{
+		description: "play",
+		run: func(source cache.HierarchicalTabularDataSource, ctx context.Context, key interface{}) error {
+			return source.Play(ctx, key, variables)
+		},
+	}
+}
+
+// Parse variables written "KEY1=value1 KEY2=value2"
+func parseVariables(s string) (map[string]string, error) {
+	variables := make(map[string]string)
+	for _, field := range strings.Fields(s) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid variable %q (expected KEY=value)", field)
+		}
+		variables[kv[0]] = kv[1]
+	}
+
+	return variables, nil
+}
+
+type actionResult struct {
+	action action
+	err    error
+}
+
 type Controller struct {
 	tui              *TUI
 	cache            cache.Cache
-	ref              string
+	targets          []cache.Target
 	width            int
 	height           int
 	header           *TextArea
 	table            *Table
-	tableSearch      string
+	search           string
 	status           *StatusBar
 	inputDestination inputDestination
-	defaultStatus    string
+	keys             KeyBindings
 	help             string
+	// Columns of the table
+	columns Columns
+	// Filter and order of the rows of the table
+	filter filter
+	sort   sortOrder
+	// Viewer of the log selected by the user, nil if the table is shown instead
+	logViewer *LogViewer
+	// Whether new content is appended to the log viewer until the step is finished
+	logFollow bool
+	// Stop fetching the log shown in logViewer
+	logCancel context.CancelFunc
+	// New content of the log shown in logViewer. Nil if logViewer is nil.
+	logc <-chan string
+	// Result of the fetching of the log shown in logViewer. Nil if logViewer is nil.
+	logErrc <-chan error
+	// Errors of notifiers. Nil if there is no notifier.
+	notificationErrc <-chan error
+	// Action waiting for confirmation by the user
+	pendingAction action
+	// Results of the actions confirmed by the user
+	actionResults chan actionResult
+	// Whether the table shows the pipelines of the recent commits of the target instead of the
+	// pipelines of its current commit
+	history bool
+	// Mouse buttons pressed as of the last mouse event
+	mouseButtons tcell.ButtonMask
+	// Time and position of the last click, used to detect double clicks
+	lastClick struct {
+		when time.Time
+		x, y int
+	}
 }
 
 var ErrExit = errors.New("exit")
 
+// Number of commits listed by the history view
+const historyLength = 20
+
 type SourceFromRef = func(ref string) cache.HierarchicalTabularDataSource
 
-func NewController(tui *TUI, ref string, c cache.Cache, loc *time.Location, defaultStatus string, help string) (Controller, error) {
+// Return the data source of the table. Pipelines are grouped by target only if there are
+// several of them. If history is true and there is a single target, pipelines of the recent
+// commits of the target are listed instead.
+func targetsSource(c cache.Cache, targets []cache.Target, history bool) cache.HierarchicalTabularDataSource {
+	if history && len(targets) == 1 {
+		return c.HistoryOfRef(targets[0])
+	}
+	if len(targets) == 1 {
+		return c.BuildsOfRef(targets[0])
+	}
+	return c.BuildsOfRefs(targets)
+}
+
+func NewController(tui *TUI, targets []cache.Target, c cache.Cache, loc *time.Location, keys KeyBindings, columns Columns, help string) (Controller, error) {
 	// Arbitrary values, the correct size will be set when the first RESIZE event is received
 	width, height := tui.Size()
 	header, err := NewTextArea(width, height)
@@ -53,105 +160,219 @@ func NewController(tui *TUI, ref string, c cache.Cache, loc *time.Location, defa
 		return Controller{}, err
 	}
 
-	table, err := NewTable(c.BuildsOfRef(ref), width, height, loc)
+	table, err := NewTable(targetsSource(c, targets, false), width, height, loc)
 	if err != nil {
 		return Controller{}, err
 	}
+	table.SetColumns(columns)
 
 	status, err := NewStatusBar(width, height)
 	if err != nil {
 		return Controller{}, err
 	}
-	status.Write(defaultStatus)
+	status.Write(keys.hint(tableHint))
 
 	return Controller{
 		tui:           tui,
-		ref:           ref,
+		targets:       targets,
 		cache:         c,
 		width:         width,
 		height:        height,
 		header:        &header,
 		table:         &table,
 		status:        &status,
-		defaultStatus: defaultStatus,
+		keys:          keys,
+		columns:       columns,
 		help:          help,
+		actionResults: make(chan actionResult),
 	}, nil
 }
 
-func (c *Controller) setRef(ref string) error {
-	if ref != c.ref {
-		// TODO Preserve traversable state across calls to setRef()
-		table, err := NewTable(c.cache.BuildsOfRef(ref), c.table.width, c.table.height, c.table.location)
+func (c *Controller) setTargets(targets []cache.Target) error {
+	if !sameTargets(targets, c.targets) {
+		// The history is only available for a single target
+		if len(targets) > 1 {
+			c.history = false
+		}
+		// TODO Preserve traversable state across calls to setTargets()
+		table, err := c.newTable(targets, c.history)
 		if err != nil {
 			return err
 		}
-		c.table, c.ref = &table, ref
+		c.table, c.targets = &table, targets
+	}
+
+	return nil
+}
+
+// Columns used to sort rows by each sort command
+var sortColumns = map[command]string{
+	commandSortState:    "STATE",
+	commandSortStarted:  "STARTED",
+	commandSortDuration: "DURATION",
+}
+
+// Return a table of the same size as the current one listing the pipelines of targets
+func (c Controller) newTable(targets []cache.Target, history bool) (Table, error) {
+	table, err := NewTable(targetsSource(c.cache, targets, history), c.table.width, c.table.height, c.table.location)
+	if err != nil {
+		return Table{}, err
+	}
+	table.SetColumns(c.columns)
+	table.SetFilter(c.filter)
+	table.SetSort(c.sort)
+
+	return table, nil
+}
+
+// Switch the table between the pipelines of the current commit of the target and the pipelines
+// of the recent commits of its history
+func (c *Controller) setHistory(history bool) error {
+	table, err := c.newTable(c.targets, history)
+	if err != nil {
+		return err
 	}
+	c.table, c.history = &table, history
+	c.refresh()
 
 	return nil
 }
 
-func (c *Controller) Run(ctx context.Context, repositoryURL string) error {
+func sameTargets(a []cache.Target, b []cache.Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Monitor the pipelines of c.targets until the user exits. If pipelineURLs is not empty, these
+// pipelines are monitored instead of those found by querying source providers.
+func (c *Controller) Run(ctx context.Context, pipelineURLs []string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	errc := make(chan error)
-	refc := make(chan string)
+	targetsc := make(chan []cache.Target)
+	unknownc := make(chan cache.Target)
 	updates := make(chan time.Time)
 
 	c.refresh()
 	c.draw()
 
 	// Start pipeline monitoring
-	go func() {
-		select {
-		case refc <- c.ref:
-		case <-ctx.Done():
-		}
-	}()
+	if len(pipelineURLs) > 0 {
+		go func() {
+			errc <- c.cache.MonitorPipelineURLs(ctx, c.targets[0], pipelineURLs, updates)
+		}()
+	} else {
+		go func() {
+			select {
+			case targetsc <- c.targets:
+			case <-ctx.Done():
+			}
+		}()
+	}
 
-	var tmpRef string
+	var tmpTargets = c.targets
 	var mux = &sync.Mutex{}
-	var refCtx context.Context
-	var refCancel = func() {}
+	var targetsCtx context.Context
+	var targetsCancel = func() {}
 	var err error
 	for err == nil {
 		select {
-		case ref := <-refc:
-			// Each time a new git reference is received, cancel the last function call
-			// and start a new one.
+		case targets := <-targetsc:
+			// Each time a new list of targets is received, cancel the last function calls
+			// and start new ones, one for each target.
 			mux.Lock()
-			tmpRef = ref
+			tmpTargets = targets
 			mux.Unlock()
-			refCancel()
-			refCtx, refCancel = context.WithCancel(ctx)
-			go func(ctx context.Context, ref string) {
-				errc <- c.cache.MonitorPipelines(ctx, repositoryURL, ref, updates)
-			}(refCtx, ref)
+			targetsCancel()
+			targetsCtx, targetsCancel = context.WithCancel(ctx)
+			history := c.history && len(targets) == 1
+			for _, target := range targets {
+				go func(ctx context.Context, target cache.Target) {
+					var err error
+					if history {
+						err = c.cache.MonitorHistory(ctx, target, historyLength, updates)
+					} else {
+						err = c.cache.MonitorPipelines(ctx, target, updates)
+					}
+					if err == cache.ErrUnknownGitReference {
+						select {
+						case unknownc <- target:
+						case <-ctx.Done():
+						}
+						return
+					}
+					errc <- err
+				}(targetsCtx, target)
+			}
 
 		case <-updates:
 			// Update the controller once we receive an update, meaning the reference exists at
 			// least locally or remotely
 			mux.Lock()
-			if err := c.setRef(tmpRef); err != nil {
+			if err := c.setTargets(tmpTargets); err != nil {
 				return err
 			}
 			mux.Unlock()
 			c.refresh()
 			c.draw()
 
+		case target := <-unknownc:
+			c.status.Write(fmt.Sprintf("error: git reference %q was not found on remote server(s)", target.Ref))
+			c.draw()
+
 		case e := <-errc:
 			switch e {
-			case context.Canceled:
+			case nil, context.Canceled:
 				// Do nothing
-			case cache.ErrUnknownGitReference:
-				c.status.Write(fmt.Sprintf("error: git reference was not found on remote server(s)"))
-				c.draw()
 			default:
 				err = e
 			}
 
+		case content := <-c.logc:
+			c.logViewer.Write(content)
+			if c.logFollow {
+				c.logViewer.SetStatus("Following log...")
+			}
+			c.draw()
+
+		case e := <-c.logErrc:
+			switch e {
+			case nil:
+				if c.logFollow {
+					c.logViewer.SetStatus("End of log")
+				} else {
+					c.logViewer.SetStatus("")
+				}
+			case cache.ErrNoLogHere:
+				c.closeLog()
+			default:
+				c.logViewer.SetStatus(fmt.Sprintf("error: %s", e.Error()))
+			}
+			c.logc, c.logErrc = nil, nil
+			c.draw()
+
+		case result := <-c.actionResults:
+			if result.err != nil {
+				c.writeStatus(fmt.Sprintf("error: %s request failed: %s", result.action.description, result.err.Error()))
+			} else {
+				c.writeStatus(fmt.Sprintf("The %s request was accepted", result.action.description))
+			}
+			c.refresh()
+			c.draw()
+
+		case e := <-c.notificationErrc:
+			c.writeStatus(fmt.Sprintf("error: notification failed: %s", e.Error()))
+			c.draw()
+
 		case event := <-c.tui.eventc:
-			err = c.process(ctx, event, refc)
+			err = c.process(ctx, event, targetsc)
 
 		case <-ctx.Done():
 			err = ctx.Err()
@@ -173,21 +394,58 @@ func (c *Controller) writeStatus(s string) {
 }
 
 func (c *Controller) writeDefaultStatus() {
-	c.writeStatus(c.defaultStatus)
+	if c.logViewer != nil {
+		c.writeStatus(c.keys.hint(logViewerHint))
+	} else {
+		c.writeStatus(c.keys.hint(tableHint))
+	}
 }
 
 func (c *Controller) refresh() {
-	commit, _ := c.cache.Commit(c.ref)
-	c.header.Write(commit.Strings()...)
+	switch {
+	case c.history:
+		line := text.NewStyledString(fmt.Sprintf("Last %d commits of ", historyLength))
+		line.Append(c.targets[0].Ref, text.GitBranch)
+		line.Append(" " + c.targets[0].Repository)
+		c.header.Write(line)
+	case len(c.targets) == 1:
+		commit, _ := c.cache.Commit(c.targets[0])
+		c.header.Write(commit.Strings()...)
+	default:
+		// Show a single line per target
+		lines := make([]text.StyledString, 0, len(c.targets))
+		for _, target := range c.targets {
+			line := text.NewStyledString(target.Ref, text.GitBranch)
+			if commit, exists := c.cache.Commit(target); exists {
+				sha := commit.Sha
+				if len(sha) > 7 {
+					sha = sha[:7]
+				}
+				line.Append(" ")
+				line.Append(sha, text.GitSha)
+				line.Append(" " + strings.SplitN(commit.Message, "\n", 2)[0])
+			}
+			lines = append(lines, line)
+		}
+		c.header.Write(lines...)
+	}
 	c.table.Refresh()
 	c.resize(c.width, c.height)
 }
 
+// Return the widgets shown, from top to bottom
+func (c Controller) widgets() []Widget {
+	if c.logViewer != nil {
+		return []Widget{c.logViewer, c.status}
+	}
+	return []Widget{c.header, c.table, c.status}
+}
+
 func (c Controller) text() []text.LocalizedStyledString {
 	texts := make([]text.LocalizedStyledString, 0)
 	yOffset := 0
 
-	for _, child := range []Widget{c.header, c.table, c.status} {
+	for _, child := range c.widgets() {
 		for _, line := range child.Text() {
 			line.Y += yOffset
 			texts = append(texts, line)
@@ -199,11 +457,16 @@ func (c Controller) text() []text.LocalizedStyledString {
 	return texts
 }
 
-func (c *Controller) nextMatch() {
-	if c.tableSearch != "" {
-		found := c.table.NextMatch(c.tableSearch, true)
+func (c *Controller) nextMatch(ascending bool) {
+	if c.search != "" {
+		var found bool
+		if c.logViewer != nil {
+			found = c.logViewer.NextMatch(c.search, ascending)
+		} else {
+			found = c.table.NextMatch(c.search, ascending)
+		}
 		if !found {
-			c.writeStatus(fmt.Sprintf("No match found for %#v", c.tableSearch))
+			c.writeStatus(fmt.Sprintf("No match found for %#v", c.search))
 		}
 	}
 }
@@ -218,44 +481,260 @@ func (c *Controller) resize(width int, height int) {
 	c.header.Resize(width, headerHeight)
 	c.table.Resize(width, tableHeight)
 	c.status.Resize(width, statusHeight)
+	if c.logViewer != nil {
+		c.logViewer.Resize(width, headerHeight+tableHeight)
+	}
 	c.width, c.height = width, height
 }
 
-// Turn `aaa\rbbb\rccc\r\n` into `ccc\r\n`
-// This is mostly for Travis logs that contain metadata hidden by carriage returns
-var deleteUntilCarriageReturn = regexp.MustCompile(`.*\r([^\r\n])`)
+// Writer sending everything written to it on a channel
+type channelWriter struct {
+	ctx context.Context
+	c   chan<- string
+}
 
-// https://stackoverflow.com/questions/14693701/how-can-i-remove-the-ansi-escape-sequences-from-a-string-in-python
-var deleteANSIEscapeSequence = regexp.MustCompile(`\x1b[@-_][0-?]*[ -/]*[@-~]`)
+func (w channelWriter) Write(p []byte) (int, error) {
+	select {
+	case w.c <- string(p):
+		return len(p), nil
+	case <-w.ctx.Done():
+		return 0, w.ctx.Err()
+	}
+}
 
-func (c *Controller) viewLog(ctx context.Context) error {
-	c.writeStatus("Fetching logs...")
-	c.draw()
-	defer func() {
-		c.writeDefaultStatus()
-		c.draw()
-	}()
+// Show the log of the active row in place of the table. If follow is true, new content is
+// appended to the log until the step is finished.
+func (c *Controller) openLog(ctx context.Context, follow bool) error {
+	key, exists := c.table.ActiveRowKey()
+	if !exists {
+		return nil
+	}
 
-	log, err := c.table.ActiveRowLog(ctx)
+	logViewer, err := NewLogViewer(c.width, c.height)
 	if err != nil {
-		if err == cache.ErrNoLogHere {
-			return nil
-		}
 		return err
 	}
+	logViewer.SetStatus("Fetching log...")
+
+	ctx, cancel := context.WithCancel(ctx)
+	logc := make(chan string)
+	errc := make(chan error)
+	c.logViewer, c.logFollow, c.logCancel, c.logc, c.logErrc = &logViewer, follow, cancel, logc, errc
+	c.resize(c.width, c.height)
+	c.writeDefaultStatus()
+
+	source := c.table.source
+	go func() {
+		w := channelWriter{ctx: ctx, c: logc}
+		var err error
+		if follow {
+			err = source.StreamLog(ctx, key, w)
+		} else {
+			var log string
+			if log, err = source.Log(ctx, key); err == nil {
+				_, err = w.Write([]byte(log))
+			}
+		}
+		select {
+		case errc <- err:
+		case <-ctx.Done():
+		}
+	}()
+
+	return nil
+}
+
+// Stop fetching the log shown and go back to the table
+func (c *Controller) closeLog() {
+	if c.logCancel != nil {
+		c.logCancel()
+	}
+	c.logViewer, c.logCancel, c.logc, c.logErrc = nil, nil, nil, nil
+	c.resize(c.width, c.height)
+	c.writeDefaultStatus()
+}
+
+// Run a command while the log viewer is shown
+func (c *Controller) runLogViewerCommand(cmd command) {
+	switch cmd {
+	case commandScrollDown:
+		c.logViewer.Scroll(+1)
+	case commandScrollUp:
+		c.logViewer.Scroll(-1)
+	case commandPageDown:
+		c.logViewer.Scroll(c.logViewer.NbrRows())
+	case commandPageUp:
+		c.logViewer.Scroll(-c.logViewer.NbrRows())
+	case commandTop:
+		c.logViewer.Top()
+	case commandBottom:
+		c.logViewer.Bottom()
+	case commandClose:
+		c.logViewer.SetTraversable(false, false)
+	case commandCloseAll:
+		c.logViewer.SetTraversable(false, true)
+	case commandOpen:
+		c.logViewer.SetTraversable(true, false)
+	case commandOpenAll:
+		c.logViewer.SetTraversable(true, true)
+	case commandNextMatch, commandPreviousMatch:
+		c.nextMatch(cmd == commandNextMatch)
+	case commandSearch:
+		c.openInput(inputSearch, "/")
+	case commandPalette:
+		c.openPalette()
+	case commandQuit:
+		c.closeLog()
+	}
+}
+
+func (c *Controller) openInput(destination inputDestination, prefix string) {
+	c.inputDestination = destination
+	c.status.ShowInput = true
+	c.status.inputPrefix = prefix
+	c.status.InputBuffer = ""
+	c.status.Completions = nil
+}
+
+func (c *Controller) closeInput() {
+	c.inputDestination = inputNone
+	c.status.ShowInput = false
+	c.status.Completions = nil
+}
+
+// Open the command palette where commands are typed by name
+func (c *Controller) openPalette() {
+	c.openInput(inputPalette, ":")
+	c.status.Completions = paletteCompletions("")
+}
+
+// Run the command typed in the command palette. Unless the name of the command is typed in
+// full, the best match is run.
+func (c *Controller) runPaletteCommand(ctx context.Context, targetsc chan<- []cache.Target) error {
+	input := strings.TrimSpace(c.status.InputBuffer)
+	c.closeInput()
+	if input == "" {
+		return nil
+	}
+	completions := paletteCompletions(input)
+	if len(completions) == 0 {
+		c.writeStatus(fmt.Sprintf("error: unknown command %q", input))
+		return nil
+	}
+
+	return c.runCommand(ctx, command(completions[0]), targetsc)
+}
+
+// Ask the user to confirm the action 'a' on the active row of the table
+func (c *Controller) confirmAction(a action) {
+	if _, exists := c.table.ActiveRowKey(); !exists {
+		return
+	}
+	c.pendingAction = a
+	c.openInput(inputConfirmation, a.prompt)
+}
+
+// Run the action confirmed by the user on the active row of the table. The result is sent on
+// c.actionResults.
+func (c *Controller) runAction(ctx context.Context, a action) {
+	key, exists := c.table.ActiveRowKey()
+	if !exists {
+		return
+	}
+	c.writeStatus(fmt.Sprintf("Sending %s request...", a.description))
+
+	source := c.table.source
+	go func() {
+		result := actionResult{
+			action: a,
+			err:    a.run(source, ctx, key),
+		}
+		select {
+		case c.actionResults <- result:
+		case <-ctx.Done():
+		}
+	}()
+}
 
-	stdin := bytes.Buffer{}
-	log = deleteANSIEscapeSequence.ReplaceAllString(log, "")
-	log = deleteUntilCarriageReturn.ReplaceAllString(log, "$1")
-	stdin.WriteString(log)
+// Process a key pressed while the user is typing in the status bar
+func (c *Controller) processInput(ctx context.Context, event *tcell.EventKey, targetsc chan<- []cache.Target) error {
+	if c.inputDestination == inputConfirmation {
+		c.closeInput()
+		if event.Key() == tcell.KeyRune && (event.Rune() == 'y' || event.Rune() == 'Y') {
+			c.runAction(ctx, c.pendingAction)
+		}
+		c.pendingAction = action{}
+		return nil
+	}
 
-	// FIXME Do not make this choice here, move this to the configuration
-	pager := os.Getenv("PAGER")
-	if pager == "" {
-		pager = "less"
+	switch event.Key() {
+	case tcell.KeyEsc:
+		c.closeInput()
+	case tcell.KeyTab:
+		if c.inputDestination == inputPalette && len(c.status.Completions) > 0 {
+			c.status.InputBuffer = c.status.Completions[0]
+		}
+	case tcell.KeyEnter:
+		if c.inputDestination == inputPalette {
+			return c.runPaletteCommand(ctx, targetsc)
+		}
+		switch c.inputDestination {
+		case inputSearch:
+			c.search = c.status.InputBuffer
+			c.nextMatch(true)
+		case inputVariables:
+			variables, err := parseVariables(c.status.InputBuffer)
+			if err != nil {
+				c.writeStatus(fmt.Sprintf("error: %s", err.Error()))
+				break
+			}
+			c.runAction(ctx, playAction(variables))
+		case inputColumns:
+			columns, err := c.columns.withNames(strings.Fields(c.status.InputBuffer))
+			if err != nil {
+				c.writeStatus(fmt.Sprintf("error: %s", err.Error()))
+				break
+			}
+			c.columns = columns
+			c.table.SetColumns(columns)
+		case inputFilter:
+			f, err := parseFilter(c.status.InputBuffer)
+			if err != nil {
+				c.writeStatus(fmt.Sprintf("error: %s", err.Error()))
+				break
+			}
+			c.filter = f
+			c.table.SetFilter(f)
+		case inputRef:
+			target := cache.Target{
+				Repository: c.targets[0].Repository,
+				Ref:        c.status.InputBuffer,
+			}
+			if target.Ref != "" && target != c.targets[0] {
+				go func() {
+					select {
+					case targetsc <- []cache.Target{target}:
+					case <-ctx.Done():
+					}
+				}()
+			}
+		}
+		c.closeInput()
+	case tcell.KeyCtrlU:
+		c.status.InputBuffer = ""
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		runes := []rune(c.status.InputBuffer)
+		if len(runes) > 0 {
+			c.status.InputBuffer = string(runes[:len(runes)-1])
+		}
+	case tcell.KeyRune:
+		c.status.InputBuffer += string(event.Rune())
+	}
+	if c.inputDestination == inputPalette {
+		c.status.Completions = paletteCompletions(c.status.InputBuffer)
 	}
 
-	return c.tui.Exec(ctx, pager, nil, &stdin)
+	return nil
 }
 
 func (c *Controller) viewHelp(ctx context.Context) error {
@@ -300,124 +779,190 @@ func (c *Controller) draw() {
 	c.tui.Draw(c.text()...)
 }
 
-func (c *Controller) process(ctx context.Context, event tcell.Event, refc chan<- string) error {
+func (c *Controller) process(ctx context.Context, event tcell.Event, targetsc chan<- []cache.Target) error {
 	c.writeDefaultStatus()
 	switch ev := event.(type) {
 	case *tcell.EventResize:
 		sx, sy := ev.Size()
 		c.resize(sx, sy)
 	case *tcell.EventKey:
-		switch ev.Key() {
-		case tcell.KeyDown:
-			c.table.Scroll(+1)
-		case tcell.KeyUp:
-			c.table.Scroll(-1)
-		case tcell.KeyPgDn:
-			c.table.Scroll(c.table.NbrRows())
-		case tcell.KeyPgUp:
-			c.table.Scroll(-c.table.NbrRows())
-		case tcell.KeyHome:
-			c.table.Top()
-		case tcell.KeyEnd:
-			c.table.Bottom()
-		case tcell.KeyEsc:
-			if c.inputDestination != inputNone {
-				c.inputDestination = inputNone
-				c.status.ShowInput = false
+		if c.inputDestination != inputNone {
+			if err := c.processInput(ctx, ev, targetsc); err != nil {
+				return err
 			}
-		case tcell.KeyEnter:
-			if c.inputDestination != inputNone {
-				switch c.inputDestination {
-				case inputSearch:
-					c.tableSearch = c.status.InputBuffer
-					c.nextMatch()
-				case inputRef:
-					if ref := c.status.InputBuffer; ref != "" && ref != c.ref {
-						go func() {
-							select {
-							case refc <- ref:
-							case <-ctx.Done():
-							}
-						}()
-					}
-				}
-				c.inputDestination = inputNone
-				c.status.ShowInput = false
-			} else {
-				c.nextMatch()
+			break
+		}
+		if c.logViewer != nil && ev.Key() == tcell.KeyEsc {
+			c.closeLog()
+			break
+		}
+		if cmd, exists := c.keys.command(ev); exists {
+			if err := c.runCommand(ctx, cmd, targetsc); err != nil {
+				return err
 			}
-		case tcell.KeyCtrlU:
-			if c.inputDestination != inputNone {
-				c.status.InputBuffer = ""
+		}
+	case *tcell.EventMouse:
+		if err := c.processMouse(ctx, ev, targetsc); err != nil {
+			return err
+		}
+	}
+
+	c.draw()
+	return nil
+}
+
+// Maximum delay between the two clicks of a double click
+const doubleClickDelay = 500 * time.Millisecond
+
+// Send a mouse event to the widget under the pointer and run the command it leads to. Only
+// clicks and turns of the wheel are taken into account.
+func (c *Controller) processMouse(ctx context.Context, event *tcell.EventMouse, targetsc chan<- []cache.Target) error {
+	buttons, previous := event.Buttons(), c.mouseButtons
+	c.mouseButtons = buttons
+	if c.inputDestination != inputNone {
+		return nil
+	}
+
+	x, y := event.Position()
+	var action mouseAction
+	switch {
+	case buttons&tcell.WheelUp != 0:
+		action = mouseWheelUp
+	case buttons&tcell.WheelDown != 0:
+		action = mouseWheelDown
+	case buttons&tcell.Button1 != 0 && previous&tcell.Button1 == 0:
+		action = mouseClick
+		if event.When().Sub(c.lastClick.when) < doubleClickDelay && c.lastClick.x == x && c.lastClick.y == y {
+			action = mouseDoubleClick
+		}
+		c.lastClick.when, c.lastClick.x, c.lastClick.y = event.When(), x, y
+		if action == mouseDoubleClick {
+			// A third click starts a new double click
+			c.lastClick.when = time.Time{}
+		}
+	default:
+		return nil
+	}
+
+	yOffset := 0
+	for _, widget := range c.widgets() {
+		_, height := widget.Size()
+		if y >= yOffset && y < yOffset+height {
+			if cmd, exists := widget.ProcessMouse(mouseEvent{x: x, y: y - yOffset, action: action}); exists {
+				return c.runCommand(ctx, cmd, targetsc)
 			}
-		case tcell.KeyBackspace, tcell.KeyBackspace2:
-			if c.inputDestination != inputNone {
-				runes := []rune(c.status.InputBuffer)
-				if len(runes) > 0 {
-					c.status.InputBuffer = string(runes[:len(runes)-1])
-				}
+			break
+		}
+		yOffset += height
+	}
+
+	return nil
+}
+
+// Run a command selected by the user with a key or with the command palette
+func (c *Controller) runCommand(ctx context.Context, cmd command, targetsc chan<- []cache.Target) error {
+	if c.logViewer != nil {
+		c.runLogViewerCommand(cmd)
+		return nil
+	}
+
+	switch cmd {
+	case commandScrollDown:
+		c.table.Scroll(+1)
+	case commandScrollUp:
+		c.table.Scroll(-1)
+	case commandPageDown:
+		c.table.Scroll(c.table.NbrRows())
+	case commandPageUp:
+		c.table.Scroll(-c.table.NbrRows())
+	case commandTop:
+		c.table.Top()
+	case commandBottom:
+		c.table.Bottom()
+	case commandBrowser:
+		if u := c.table.ActiveRowURL(); u.Valid {
+			if err := c.openWebBrowser(u.String); err != nil {
+				return err
 			}
-		case tcell.KeyRune:
-			if c.inputDestination != inputNone {
-				c.status.InputBuffer += string(ev.Rune())
-				break
+		}
+	case commandClose:
+		c.table.SetTraversable(false, false)
+	case commandCloseAll:
+		c.table.SetTraversable(false, true)
+	case commandOpen:
+		c.table.SetTraversable(true, false)
+	case commandOpenAll:
+		c.table.SetTraversable(true, true)
+	case commandNextMatch, commandPreviousMatch:
+		c.nextMatch(cmd == commandNextMatch)
+	case commandQuit:
+		return ErrExit
+	case commandSearch:
+		c.openInput(inputSearch, "/")
+	case commandPalette:
+		c.openPalette()
+	case commandRef:
+		if len(c.targets) > 1 {
+			c.writeStatus("error: the git reference cannot be changed when monitoring several references")
+			break
+		}
+		c.openInput(inputRef, "ref: ")
+	case commandColumns:
+		c.openInput(inputColumns, "columns: ")
+		c.status.InputBuffer = c.table.Columns().String()
+	case commandFilter:
+		c.openInput(inputFilter, "filter: ")
+		c.status.InputBuffer = c.filter.expression
+	case commandSortState, commandSortStarted, commandSortDuration:
+		c.sort = c.sort.toggle(sortColumns[cmd])
+		c.table.SetSort(c.sort)
+	case commandHistory:
+		if len(c.targets) > 1 {
+			c.writeStatus("error: the history is not available when monitoring several references")
+			break
+		}
+		if err := c.setHistory(!c.history); err != nil {
+			return err
+		}
+		// Monitor either the history or the current commit of the target
+		go func() {
+			select {
+			case targetsc <- c.targets:
+			case <-ctx.Done():
 			}
-			switch keyRune := ev.Rune(); keyRune {
-			case 'b':
-				if u := c.table.ActiveRowURL(); u.Valid {
-					if err := c.openWebBrowser(u.String); err != nil {
-						return err
-					}
-				}
-			case 'j':
-				c.table.Scroll(+1)
-			case 'k':
-				c.table.Scroll(-1)
-			case 'c':
-				c.table.SetTraversable(false, false)
-			case 'C', '-':
-				c.table.SetTraversable(false, true)
-			case 'o':
-				c.table.SetTraversable(true, false)
-			case 'O', '+':
-				c.table.SetTraversable(true, true)
-			case 'n', 'N':
-				if c.status.InputBuffer != "" {
-					_ = c.table.NextMatch(c.status.InputBuffer, ev.Rune() == 'n')
-				}
-			case 'q':
-				return ErrExit
-			case '/':
-				c.inputDestination = inputSearch
-				c.status.ShowInput = true
-				c.status.inputPrefix = "/"
-				c.status.InputBuffer = ""
-			case 'r':
-				c.inputDestination = inputRef
-				c.status.ShowInput = true
-				c.status.InputBuffer = ""
-				c.status.inputPrefix = "ref: "
-			case 'u':
-				// TODO Fix controller.setRef to preserve traversable state
-				go func() {
-					select {
-					case refc <- c.ref:
-					case <-ctx.Done():
-					}
-				}()
-			case '?':
-				if err := c.viewHelp(ctx); err != nil {
-					return err
-				}
-
-			case 'v':
-				if err := c.viewLog(ctx); err != nil {
-					return err
-				}
+		}()
+	case commandRefresh:
+		// TODO Fix controller.setRef to preserve traversable state
+		go func() {
+			select {
+			case targetsc <- c.targets:
+			case <-ctx.Done():
 			}
+		}()
+	case commandHelp:
+		if err := c.viewHelp(ctx); err != nil {
+			return err
+		}
+	case commandLogs, commandFollow:
+		if err := c.openLog(ctx, cmd == commandFollow); err != nil {
+			return err
+		}
+	case commandRestart:
+		c.confirmAction(restartAction)
+	case commandCancel:
+		c.confirmAction(cancelAction)
+	case commandTrigger:
+		c.confirmAction(triggerAction)
+	case commandPlay:
+		if row, exists := c.table.ActiveRow(); exists {
+			// Don't ask for variables only to have the request rejected afterwards
+			if playable, ok := row.(cache.PlayableRow); !ok || !playable.Playable() {
+				c.writeStatus("error: the selected row is not a manual step")
+				break
+			}
+			c.openInput(inputVariables, "Play with variables (KEY=value ...): ")
 		}
 	}
 
-	c.draw()
 	return nil
 }
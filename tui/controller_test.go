@@ -1,10 +1,13 @@
 package tui
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/text"
 )
@@ -22,7 +25,7 @@ func TestController_resize(t *testing.T) {
 			tui.Finish()
 		}()
 		c := cache.NewCache(nil, nil)
-		controller, err := NewController(&tui, "", c, time.UTC, "", "")
+		controller, err := NewController(&tui, []cache.Target{{}}, c, time.UTC, DefaultKeyBindings(), Columns{}, "")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -33,3 +36,193 @@ func TestController_resize(t *testing.T) {
 		controller.draw()
 	})
 }
+
+func TestController_setHistory(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tui.Finish()
+
+	c := cache.NewCache(nil, nil)
+	target := cache.Target{Repository: "repo", Ref: "master"}
+	controller, err := NewController(&tui, []cache.Target{target}, c, time.UTC, DefaultKeyBindings(), Columns{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := controller.setHistory(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := controller.table.source.(cache.BuildsByHistory); !ok {
+		t.Fatalf("expected the table to list the history of the target but got %T", controller.table.source)
+	}
+
+	if err := controller.setHistory(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := controller.table.source.(cache.BuildsByCommit); !ok {
+		t.Fatalf("expected the table to list the pipelines of the target but got %T", controller.table.source)
+	}
+
+	t.Run("the history is left when several targets are monitored", func(t *testing.T) {
+		if err := controller.setHistory(true); err != nil {
+			t.Fatal(err)
+		}
+		other := cache.Target{Repository: "repo", Ref: "feature"}
+		if err := controller.setTargets([]cache.Target{target, other}); err != nil {
+			t.Fatal(err)
+		}
+		if controller.history {
+			t.Fatal("expected the history to be left")
+		}
+		if _, ok := controller.table.source.(cache.BuildsByCommit); !ok {
+			t.Fatalf("expected the table to list the pipelines of the targets but got %T", controller.table.source)
+		}
+	})
+}
+
+func TestParseVariables(t *testing.T) {
+	variables, err := parseVariables(" ENV=production  DRY_RUN= URL=https://example.com/?a=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"ENV":     "production",
+		"DRY_RUN": "",
+		"URL":     "https://example.com/?a=b",
+	}
+	if diff := cmp.Diff(expected, variables); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	for _, s := range []string{"ENV", "=production"} {
+		if _, err := parseVariables(s); err == nil {
+			t.Fatalf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestController_processMouse(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tui.Finish()
+
+	c := cache.NewCache(nil, nil)
+	target := cache.Target{Repository: "repo", Ref: "master"}
+	pipeline := cache.Pipeline{
+		Step: cache.Step{
+			ID:   "1",
+			Type: cache.StepPipeline,
+			Children: []cache.Step{
+				{ID: "2", Type: cache.StepJob},
+				{ID: "3", Type: cache.StepJob},
+			},
+		},
+	}
+	pipeline.SetProvider("provider", "example.com")
+	if err := c.SavePipeline(target, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	controller, err := NewController(&tui, []cache.Target{target}, c, time.UTC, DefaultKeyBindings(), Columns{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 24)
+	controller.refresh()
+	_, headerHeight := controller.header.Size()
+
+	ctx := context.Background()
+	// Click on the last row of the table, below the header of the controller and the header
+	// of the table
+	events := []*tcell.EventMouse{
+		tcell.NewEventMouse(0, headerHeight+3, tcell.Button1, 0),
+		tcell.NewEventMouse(0, headerHeight+3, tcell.ButtonNone, 0),
+	}
+	for _, event := range events {
+		if err := controller.processMouse(ctx, event, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if controller.table.activeLine != 2 {
+		t.Fatalf("expected active line 2 but got %d", controller.table.activeLine)
+	}
+
+	// Moving the mouse while the button is held down is not a click
+	events = []*tcell.EventMouse{
+		tcell.NewEventMouse(0, headerHeight+1, tcell.Button1, 0),
+		tcell.NewEventMouse(0, headerHeight+2, tcell.Button1, 0),
+	}
+	for _, event := range events {
+		if err := controller.processMouse(ctx, event, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if controller.table.activeLine != 0 {
+		t.Fatalf("expected active line 0 but got %d", controller.table.activeLine)
+	}
+}
+
+func TestController_play(t *testing.T) {
+	newScreen := func() (tcell.Screen, error) {
+		return tcell.NewSimulationScreen(""), nil
+	}
+	tui, err := NewTUI(newScreen, tcell.StyleDefault, text.StyleSheet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tui.Finish()
+
+	c := cache.NewCache(nil, nil)
+	target := cache.Target{Repository: "repo", Ref: "master"}
+	pipeline := cache.Pipeline{
+		Step: cache.Step{
+			ID:    "1",
+			Type:  cache.StepPipeline,
+			State: cache.Manual,
+			Children: []cache.Step{
+				{ID: "2", Type: cache.StepJob, State: cache.Passed},
+				{ID: "3", Type: cache.StepJob, State: cache.Manual},
+			},
+		},
+	}
+	pipeline.SetProvider("provider", "example.com")
+	if err := c.SavePipeline(target, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	controller, err := NewController(&tui, []cache.Target{target}, c, time.UTC, DefaultKeyBindings(), Columns{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.resize(80, 24)
+	controller.refresh()
+
+	ctx := context.Background()
+	for line, playable := range []bool{true, false, true} {
+		controller.closeInput()
+		controller.table.activeLine = line
+		if err := controller.runCommand(ctx, commandPlay, nil); err != nil {
+			t.Fatal(err)
+		}
+		if playable && controller.inputDestination != inputVariables {
+			t.Fatalf("expected a prompt for variables on line %d", line)
+		}
+		if !playable {
+			if controller.inputDestination != inputNone {
+				t.Fatalf("expected no prompt on line %d", line)
+			}
+			buffer := controller.status.outputBuffer
+			if len(buffer) == 0 || !strings.HasPrefix(buffer[len(buffer)-1], "error:") {
+				t.Fatalf("expected an error on line %d but got %v", line, buffer)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+)
+
+// Condition on the cells of a row
+type condition struct {
+	// Column of the cell compared to value. If empty, the condition holds if any cell of the
+	// row contains value.
+	column string
+	value  string
+	// Whether the cell must contain value instead of being equal to it
+	contains bool
+	negated  bool
+}
+
+// Filter hiding the rows of a table that do not meet all of its conditions
+type filter struct {
+	expression string
+	conditions []condition
+}
+
+// Parse a filter expression made of conditions separated by spaces. Conditions are written
+// "column:value" (the cell is equal to value), "column~value" (the cell contains value) or
+// "value" (any cell contains value), and are negated if prefixed by "-". Comparisons ignore
+// case.
+func parseFilter(expression string) (filter, error) {
+	f := filter{
+		expression: strings.TrimSpace(expression),
+	}
+	for _, term := range strings.Fields(expression) {
+		c := condition{}
+		field := term
+		if strings.HasPrefix(field, "-") {
+			c.negated = true
+			field = field[1:]
+		}
+		if i := strings.IndexAny(field, ":~"); i >= 0 {
+			column, err := columnName(field[:i])
+			if err != nil {
+				return filter{}, err
+			}
+			c.column = column
+			c.contains = field[i] == '~'
+			field = field[i+1:]
+		} else {
+			c.contains = true
+		}
+		if field == "" {
+			return filter{}, fmt.Errorf("missing value in condition %q", term)
+		}
+		c.value = strings.ToLower(field)
+		f.conditions = append(f.conditions, c)
+	}
+
+	return f, nil
+}
+
+func (c condition) match(cell string) bool {
+	cell = strings.ToLower(cell)
+	if c.contains {
+		return strings.Contains(cell, c.value)
+	}
+	return strings.TrimSpace(cell) == c.value
+}
+
+// Return whether the row meets all the conditions of the filter
+func (f filter) match(row cache.HierarchicalTabularSourceRow, loc *time.Location) bool {
+	if len(f.conditions) == 0 {
+		return true
+	}
+
+	var values map[string]string
+	if filterable, ok := row.(cache.FilterableRow); ok {
+		values = filterable.FilterValues(loc)
+	} else {
+		values = make(map[string]string)
+		for column, value := range row.Tabular(loc) {
+			values[column] = value.String()
+		}
+	}
+	for _, c := range f.conditions {
+		matched := false
+		if c.column == "" {
+			for _, value := range values {
+				if matched = c.match(value); matched {
+					break
+				}
+			}
+		} else if value, exists := values[c.column]; exists {
+			matched = c.match(value)
+		}
+		if matched == c.negated {
+			return false
+		}
+	}
+
+	return true
+}
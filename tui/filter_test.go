@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+type filterRow struct {
+	testRow
+	values map[string]string
+}
+
+func (r *filterRow) Tabular(loc *time.Location) map[string]text.StyledString {
+	values := make(map[string]text.StyledString, len(r.values))
+	for column, value := range r.values {
+		values[column] = text.NewStyledString(value)
+	}
+	return values
+}
+
+func (r filterRow) Children() []utils.TreeNode {
+	return nil
+}
+
+func TestParseFilter(t *testing.T) {
+	t.Run("valid expression", func(t *testing.T) {
+		f, err := parseFilter(" state:Failed  -provider:gitlab name~integration test ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []condition{
+			{column: "STATE", value: "failed"},
+			{column: "PROVIDER", value: "gitlab", negated: true},
+			{column: "NAME", value: "integration", contains: true},
+			{value: "test", contains: true},
+		}
+		if diff := cmp.Diff(expected, f.conditions, cmp.AllowUnexported(condition{})); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if f.expression != "state:Failed  -provider:gitlab name~integration test" {
+			t.Fatalf("unexpected expression %q", f.expression)
+		}
+	})
+
+	for _, expression := range []string{"color:red", "state:", "-name~"} {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := parseFilter(expression); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	row := filterRow{
+		values: map[string]string{
+			"STATE":    "failed",
+			"PROVIDER": "gitlab",
+			"NAME":     "integration tests",
+		},
+	}
+
+	testCases := []struct {
+		expression string
+		match      bool
+	}{
+		{expression: "", match: true},
+		{expression: "state:failed", match: true},
+		{expression: "state:FAILED provider:gitlab", match: true},
+		{expression: "state:fail", match: false},
+		{expression: "state~fail", match: true},
+		{expression: "-state:failed", match: false},
+		{expression: "-provider:travis", match: true},
+		{expression: "name~integration", match: true},
+		{expression: "name~unit", match: false},
+		{expression: "tests", match: true},
+		{expression: "author:nobody", match: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expression, func(t *testing.T) {
+			f, err := parseFilter(testCase.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match := f.match(&row, time.UTC); match != testCase.match {
+				t.Fatalf("expected %v but got %v", testCase.match, match)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+)
+
+// Command run by the user with a key or from the command palette
+type command string
+
+const (
+	commandScrollDown    command = "scroll-down"
+	commandScrollUp      command = "scroll-up"
+	commandPageDown      command = "page-down"
+	commandPageUp        command = "page-up"
+	commandTop           command = "top"
+	commandBottom        command = "bottom"
+	commandOpen          command = "open"
+	commandOpenAll       command = "open-all"
+	commandClose         command = "close"
+	commandCloseAll      command = "close-all"
+	commandSearch        command = "search"
+	commandNextMatch     command = "next-match"
+	commandPreviousMatch command = "previous-match"
+	commandLogs          command = "logs"
+	commandFollow        command = "follow"
+	commandBrowser       command = "browser"
+	commandRef           command = "ref"
+	commandRefresh       command = "refresh"
+	commandHistory       command = "history"
+	commandColumns       command = "columns"
+	commandFilter        command = "filter"
+	commandSortState     command = "sort-state"
+	commandSortStarted   command = "sort-started"
+	commandSortDuration  command = "sort-duration"
+	commandRestart       command = "restart"
+	commandCancel        command = "cancel"
+	commandTrigger       command = "trigger"
+	commandPlay          command = "play"
+	commandHelp          command = "help"
+	commandQuit          command = "quit"
+	commandPalette       command = "palette"
+)
+
+// Key pressed by the user. Runes are identified by r, other keys by k alone.
+type key struct {
+	k tcell.Key
+	r rune
+}
+
+func runeKey(r rune) key {
+	return key{k: tcell.KeyRune, r: r}
+}
+
+func eventKey(event *tcell.EventKey) key {
+	if event.Key() == tcell.KeyRune {
+		return runeKey(event.Rune())
+	}
+	return key{k: event.Key()}
+}
+
+// Parse a key written either as a single character ("j", "?") or as the name of a special key
+// ("Down", "PgUp", "Enter", "Ctrl-N"...)
+func parseKey(s string) (key, error) {
+	if utf8.RuneCountInString(s) == 1 {
+		r, _ := utf8.DecodeRuneInString(s)
+		return runeKey(r), nil
+	}
+	for k, name := range tcell.KeyNames {
+		if strings.EqualFold(name, s) {
+			return key{k: k}, nil
+		}
+	}
+
+	return key{}, fmt.Errorf("invalid key %q", s)
+}
+
+func (k key) String() string {
+	if k.k == tcell.KeyRune {
+		return string(k.r)
+	}
+	if name, exists := tcell.KeyNames[k.k]; exists {
+		return name
+	}
+	return fmt.Sprintf("Key[%d]", k.k)
+}
+
+// Commands in the order they are offered by the command palette, along with their default keys
+var defaultKeyBindings = []struct {
+	command command
+	keys    []key
+}{
+	{commandScrollDown, []key{runeKey('j'), {k: tcell.KeyDown}}},
+	{commandScrollUp, []key{runeKey('k'), {k: tcell.KeyUp}}},
+	{commandPageDown, []key{{k: tcell.KeyPgDn}}},
+	{commandPageUp, []key{{k: tcell.KeyPgUp}}},
+	{commandTop, []key{runeKey('g'), {k: tcell.KeyHome}}},
+	{commandBottom, []key{runeKey('G'), {k: tcell.KeyEnd}}},
+	{commandOpen, []key{runeKey('o')}},
+	{commandOpenAll, []key{runeKey('O'), runeKey('+')}},
+	{commandClose, []key{runeKey('c')}},
+	{commandCloseAll, []key{runeKey('C'), runeKey('-')}},
+	{commandSearch, []key{runeKey('/')}},
+	{commandNextMatch, []key{runeKey('n'), {k: tcell.KeyEnter}}},
+	{commandPreviousMatch, []key{runeKey('N')}},
+	{commandLogs, []key{runeKey('v')}},
+	{commandFollow, []key{runeKey('f')}},
+	{commandBrowser, []key{runeKey('b')}},
+	{commandRef, []key{runeKey('r')}},
+	{commandRefresh, []key{runeKey('u')}},
+	{commandHistory, []key{runeKey('h')}},
+	{commandColumns, []key{runeKey('t')}},
+	{commandFilter, []key{runeKey('F')}},
+	{commandSortState, []key{runeKey('s')}},
+	{commandSortStarted, []key{runeKey('S')}},
+	{commandSortDuration, []key{runeKey('d')}},
+	{commandRestart, []key{runeKey('R')}},
+	{commandCancel, []key{runeKey('X')}},
+	{commandTrigger, []key{runeKey('T')}},
+	{commandPlay, []key{runeKey('p')}},
+	{commandHelp, []key{runeKey('?')}},
+	{commandQuit, []key{runeKey('q')}},
+	{commandPalette, []key{runeKey(':')}},
+}
+
+// Association of keys to the commands they run
+type KeyBindings struct {
+	commandByKey  map[key]command
+	keysByCommand map[command][]key
+}
+
+// Return the default key bindings overridden by 'bindings'. The keys of 'bindings' are command
+// names and its values the keys bound to each command. A key bound to a command by 'bindings' is
+// removed from the default keys of other commands.
+func NewKeyBindings(bindings map[string][]string) (KeyBindings, error) {
+	b := KeyBindings{
+		commandByKey:  make(map[key]command),
+		keysByCommand: make(map[command][]key),
+	}
+
+	userKeys := make(map[command][]key, len(bindings))
+	for name, keyNames := range bindings {
+		cmd := command(name)
+		if !isCommand(cmd) {
+			return KeyBindings{}, fmt.Errorf("unknown command %q", name)
+		}
+		keys := make([]key, 0, len(keyNames))
+		for _, keyName := range keyNames {
+			k, err := parseKey(keyName)
+			if err != nil {
+				return KeyBindings{}, fmt.Errorf("%s: %v", name, err)
+			}
+			if other, exists := b.commandByKey[k]; exists && other != cmd {
+				return KeyBindings{}, fmt.Errorf("key %q is bound to both %q and %q", k.String(), other, cmd)
+			}
+			b.commandByKey[k] = cmd
+			keys = append(keys, k)
+		}
+		userKeys[cmd] = keys
+	}
+
+	for _, binding := range defaultKeyBindings {
+		if keys, exists := userKeys[binding.command]; exists {
+			b.keysByCommand[binding.command] = keys
+			continue
+		}
+		for _, k := range binding.keys {
+			if _, exists := b.commandByKey[k]; !exists {
+				b.commandByKey[k] = binding.command
+				b.keysByCommand[binding.command] = append(b.keysByCommand[binding.command], k)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// Return the default key bindings
+func DefaultKeyBindings() KeyBindings {
+	b, err := NewKeyBindings(nil)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func isCommand(cmd command) bool {
+	for _, binding := range defaultKeyBindings {
+		if binding.command == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the command bound to the key of event
+func (b KeyBindings) command(event *tcell.EventKey) (command, bool) {
+	cmd, exists := b.commandByKey[eventKey(event)]
+	return cmd, exists
+}
+
+// Entry of the help line shown in the status bar
+type hintEntry struct {
+	label    string
+	commands []command
+}
+
+var tableHint = []hintEntry{
+	{"Down", []command{commandScrollDown}},
+	{"Up", []command{commandScrollUp}},
+	{"Open", []command{commandOpen, commandOpenAll}},
+	{"Close", []command{commandClose, commandCloseAll}},
+	{"Search", []command{commandSearch}},
+	{"Logs", []command{commandLogs}},
+	{"Follow", []command{commandFollow}},
+	{"Browser", []command{commandBrowser}},
+	{"History", []command{commandHistory}},
+	{"Help", []command{commandHelp}},
+	{"Quit", []command{commandQuit}},
+}
+
+var logViewerHint = []hintEntry{
+	{"Down", []command{commandScrollDown}},
+	{"Up", []command{commandScrollUp}},
+	{"Open", []command{commandOpen, commandOpenAll}},
+	{"Close", []command{commandClose, commandCloseAll}},
+	{"Search", []command{commandSearch}},
+	{"Close", []command{commandQuit}},
+}
+
+// Return the help line listing the first key bound to the commands of each entry. Entries whose
+// commands are not bound to any key are left out.
+func (b KeyBindings) hint(entries []hintEntry) string {
+	hints := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys := make([]string, 0, len(entry.commands))
+		separator := ""
+		for _, cmd := range entry.commands {
+			if ks := b.keysByCommand[cmd]; len(ks) > 0 {
+				s := ks[0].String()
+				if utf8.RuneCountInString(s) > 1 {
+					separator = "/"
+				}
+				keys = append(keys, s)
+			}
+		}
+		if len(keys) > 0 {
+			hints = append(hints, fmt.Sprintf("%s:%s", strings.Join(keys, separator), entry.label))
+		}
+	}
+
+	return strings.Join(hints, "  ")
+}
+
+// Return the names of the commands offered by the command palette matching 'input', best match
+// first. A command matches if the characters of 'input' appear in its name in the same order.
+func paletteCompletions(input string) []string {
+	type match struct {
+		name  string
+		score int
+	}
+	matches := make([]match, 0)
+	for _, binding := range defaultKeyBindings {
+		if binding.command == commandPalette {
+			continue
+		}
+		name := string(binding.command)
+		if score, ok := fuzzyScore(input, name); ok {
+			matches = append(matches, match{name: name, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// Return a score measuring how well 'input' matches 'name' (lower is better) and whether it
+// matches at all. Prefixes come first, then names where the characters of input are the closest
+// to each other.
+func fuzzyScore(input string, name string) (int, bool) {
+	input = strings.ToLower(input)
+	if strings.HasPrefix(name, input) {
+		return 0, true
+	}
+
+	score := 1
+	previous := -1
+	runes := []rune(name)
+	for _, r := range input {
+		i := previous + 1
+		for ; i < len(runes) && runes[i] != r; i++ {
+		}
+		if i >= len(runes) {
+			return 0, false
+		}
+		if previous >= 0 {
+			score += i - previous - 1
+		} else {
+			score += i
+		}
+		previous = i
+	}
+
+	return score, true
+}
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseKey(t *testing.T) {
+	testCases := []struct {
+		s   string
+		key key
+	}{
+		{s: "j", key: runeKey('j')},
+		{s: "?", key: runeKey('?')},
+		{s: "Down", key: key{k: tcell.KeyDown}},
+		{s: "pgdn", key: key{k: tcell.KeyPgDn}},
+		{s: "Ctrl-N", key: key{k: tcell.KeyCtrlN}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.s, func(t *testing.T) {
+			k, err := parseKey(testCase.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k != testCase.key {
+				t.Fatalf("expected %v but got %v", testCase.key, k)
+			}
+		})
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := parseKey("Hyper-J"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestNewKeyBindings(t *testing.T) {
+	t.Run("default bindings", func(t *testing.T) {
+		b := DefaultKeyBindings()
+		expected := "j:Down  k:Up  oO:Open  cC:Close  /:Search  v:Logs  f:Follow  b:Browser  h:History  ?:Help  q:Quit"
+		if hint := b.hint(tableHint); hint != expected {
+			t.Fatalf("expected %q but got %q", expected, hint)
+		}
+	})
+
+	t.Run("user bindings override default bindings", func(t *testing.T) {
+		b, err := NewKeyBindings(map[string][]string{
+			"scroll-down": {"Ctrl-N", "j"},
+			"quit":        {"n"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[key]command{
+			{k: tcell.KeyCtrlN}: commandScrollDown,
+			runeKey('j'):        commandScrollDown,
+			{k: tcell.KeyDown}:  "",
+			runeKey('n'):        commandQuit,
+			runeKey('q'):        "",
+			{k: tcell.KeyEnter}: commandNextMatch,
+		}
+		for k, cmd := range expected {
+			if b.commandByKey[k] != cmd {
+				t.Fatalf("expected key %q to run %q but got %q", k.String(), cmd, b.commandByKey[k])
+			}
+		}
+		if hint := b.hint(logViewerHint); hint != "Ctrl-N:Down  k:Up  oO:Open  cC:Close  /:Search  n:Close" {
+			t.Fatalf("unexpected hint %q", hint)
+		}
+	})
+
+	t.Run("invalid bindings", func(t *testing.T) {
+		bindings := []map[string][]string{
+			{"unknown": {"x"}},
+			{"quit": {"Hyper-Q"}},
+			{"quit": {"x"}, "restart": {"x"}},
+		}
+		for _, b := range bindings {
+			if _, err := NewKeyBindings(b); err == nil {
+				t.Fatalf("expected an error for %v", b)
+			}
+		}
+	})
+}
+
+func TestPaletteCompletions(t *testing.T) {
+	testCases := []struct {
+		input       string
+		completions []string
+	}{
+		{input: "re", completions: []string{"ref", "refresh", "restart", "previous-match", "browser", "trigger", "sort-state", "sort-started"}},
+		{input: "lgs", completions: []string{"logs"}},
+		{input: "zz", completions: []string{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			completions := paletteCompletions(testCase.input)
+			if diff := cmp.Diff(testCase.completions, completions); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
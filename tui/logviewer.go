@@ -0,0 +1,405 @@
+package tui
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nbedos/citop/text"
+	"github.com/nbedos/citop/utils"
+)
+
+// Turn `aaa\rbbb\rccc\r\n` into `ccc\r\n`
+// This is mostly for Travis logs that contain metadata hidden by carriage returns
+var deleteUntilCarriageReturn = regexp.MustCompile(`.*\r([^\r\n])`)
+
+// Markers delimiting collapsible sections of logs:
+//   - Travis: "travis_fold:start:name\r" and "travis_fold:end:name\r"
+//   - GitLab: "section_start:1560896352:name[collapsed=true]\r" and "section_end:1560896353:name\r"
+var foldMarker = regexp.MustCompile(`(?:travis_fold:(start|end)|section_(start|end):\d+):([^\s\[\x1b]+)(\[[^\]\r]*\])?\r?`)
+
+// Line of a log or section of a log. A section is shown as a single line when folded.
+type logNode struct {
+	// Content of the line or title of the section
+	line text.StyledString
+	// Name of the section, empty for a regular line
+	section     string
+	parent      *logNode
+	children    []*logNode
+	traversable bool
+}
+
+func (n *logNode) Children() []utils.TreeNode {
+	children := make([]utils.TreeNode, len(n.children))
+	for i := range n.children {
+		children[i] = n.children[i]
+	}
+	return children
+}
+
+func (n *logNode) Traversable() bool {
+	return n.traversable
+}
+
+func (n *logNode) SetTraversable(traversable bool, recursive bool) {
+	n.traversable = traversable
+	if recursive {
+		for _, child := range n.children {
+			child.SetTraversable(traversable, recursive)
+		}
+	}
+}
+
+// LogViewer shows the log of a step. SGR escape sequences of the log are rendered and the sections
+// delimited by Travis and GitLab markers can be folded. Content can be appended to the log while
+// it is shown, in which case the view follows the end of the log unless the user moves the cursor
+// away from the last line.
+type LogViewer struct {
+	width  int
+	height int
+	// Root of the tree of lines and sections of the log
+	root *logNode
+	// Sections started but not ended yet. New lines are added to the last one.
+	sections []*logNode
+	// Graphic rendition at the end of the last complete line
+	ansi text.ANSIState
+	// Last line of the log, not terminated by a newline character yet
+	partial string
+	// Lines currently visible, that is all lines except those of folded sections
+	rows       []*logNode
+	topLine    int
+	activeLine int
+	// Whether the cursor moves automatically to the end of the log when content is written
+	follow bool
+	status string
+}
+
+func NewLogViewer(width, height int) (LogViewer, error) {
+	if width < 0 || height < 0 {
+		return LogViewer{}, errors.New("width and height must be >= 0")
+	}
+
+	root := &logNode{traversable: true}
+	return LogViewer{
+		width:    width,
+		height:   height,
+		root:     root,
+		sections: []*logNode{root},
+		follow:   true,
+	}, nil
+}
+
+// Number of lines of the log shown at once, the first line of the view being used as a title bar
+func (v LogViewer) NbrRows() int {
+	return utils.MaxInt(0, v.height-1)
+}
+
+// Append content to the log
+func (v *LogViewer) Write(content string) {
+	lines := strings.Split(v.partial+content, "\n")
+	for _, line := range lines[:len(lines)-1] {
+		v.addLine(strings.TrimSuffix(line, "\r"))
+	}
+	v.partial = lines[len(lines)-1]
+
+	v.refresh()
+}
+
+func (v *LogViewer) parse(state *text.ANSIState, s string) text.StyledString {
+	s = deleteUntilCarriageReturn.ReplaceAllString(s, "$1")
+	return state.Parse(text.ExpandTabs(s))
+}
+
+// Add a complete line to the log, opening and closing sections based on the fold markers it
+// contains
+func (v *LogViewer) addLine(line string) {
+	matches := foldMarker.FindAllStringSubmatchIndex(line, -1)
+	if len(matches) == 0 {
+		v.appendNode(&logNode{line: v.parse(&v.ansi, line)})
+		return
+	}
+
+	// Section waiting for its title, which is the content following the start marker
+	var untitled *logNode
+	addSegment := func(segment string) {
+		s := v.parse(&v.ansi, segment)
+		if strings.TrimSpace(s.String()) == "" {
+			return
+		}
+		if untitled != nil {
+			untitled.line = s
+			untitled = nil
+		} else {
+			v.appendNode(&logNode{line: s})
+		}
+	}
+
+	start := 0
+	for _, match := range matches {
+		addSegment(line[start:match[0]])
+		start = match[1]
+
+		isTravis := match[2] >= 0
+		kind := ""
+		if isTravis {
+			kind = line[match[2]:match[3]]
+		} else {
+			kind = line[match[4]:match[5]]
+		}
+		name := line[match[6]:match[7]]
+		options := ""
+		if match[8] >= 0 {
+			options = line[match[8]:match[9]]
+		}
+
+		switch kind {
+		case "start":
+			// Travis folds are collapsed by default, GitLab sections only if requested
+			section := &logNode{
+				line:        text.NewStyledString(name),
+				section:     name,
+				traversable: !isTravis && !strings.Contains(options, "collapsed=true"),
+			}
+			v.appendNode(section)
+			v.sections = append(v.sections, section)
+			untitled = section
+		case "end":
+			for i := len(v.sections) - 1; i > 0; i-- {
+				if v.sections[i].section == name {
+					v.sections = v.sections[:i]
+					break
+				}
+			}
+			untitled = nil
+		}
+	}
+	addSegment(line[start:])
+}
+
+func (v *LogViewer) appendNode(n *logNode) {
+	parent := v.sections[len(v.sections)-1]
+	n.parent = parent
+	parent.children = append(parent.children, n)
+}
+
+// Compute the list of visible lines. The cursor stays on the same line unless it is now hidden in
+// a folded section, in which case it moves to the title of the section.
+func (v *LogViewer) refresh() {
+	var active *logNode
+	if v.activeLine >= 0 && v.activeLine < len(v.rows) {
+		active = v.rows[v.activeLine]
+	}
+
+	v.rows = make([]*logNode, 0, len(v.rows))
+	indexes := make(map[*logNode]int)
+	for _, node := range utils.DepthFirstTraversal(v.root, false)[1:] {
+		indexes[node.(*logNode)] = len(v.rows)
+		v.rows = append(v.rows, node.(*logNode))
+	}
+
+	for n := active; n != nil; n = n.parent {
+		if i, exists := indexes[n]; exists {
+			v.activeLine = i
+			break
+		}
+	}
+
+	if v.follow {
+		v.Bottom()
+	} else {
+		v.Scroll(0)
+	}
+}
+
+func (v LogViewer) nbrLines() int {
+	if v.partial != "" {
+		return len(v.rows) + 1
+	}
+	return len(v.rows)
+}
+
+// Move the cursor by 'amount' lines. Moving the cursor away from the last line stops following
+// the end of the log, moving it back to the last line starts following it again.
+func (v *LogViewer) Scroll(amount int) {
+	n := v.nbrLines()
+	activeLine := utils.Bounded(v.activeLine+amount, 0, n-1)
+	switch {
+	case activeLine < v.topLine:
+		v.topLine = activeLine
+	case activeLine > v.topLine+v.NbrRows()-1:
+		v.topLine = utils.MaxInt(0, activeLine-v.NbrRows()+1)
+	}
+	v.activeLine = activeLine
+	v.follow = activeLine == utils.MaxInt(0, n-1)
+	if v.follow {
+		// Show as many lines as possible
+		v.topLine = utils.MaxInt(0, utils.MinInt(v.topLine, n-v.NbrRows()))
+	}
+}
+
+func (v *LogViewer) Top() {
+	v.Scroll(-v.nbrLines())
+}
+
+func (v *LogViewer) Bottom() {
+	v.Scroll(v.nbrLines())
+}
+
+// Fold or unfold the section at the cursor. Folding a line that is not the title of an unfolded
+// section folds the section containing it.
+func (v *LogViewer) SetTraversable(open bool, recursive bool) {
+	if v.activeLine < 0 || v.activeLine >= len(v.rows) {
+		return
+	}
+
+	node := v.rows[v.activeLine]
+	switch {
+	case !open && (node.section == "" || !node.traversable):
+		if node.parent == v.root {
+			return
+		}
+		node = node.parent
+	case node.section == "":
+		return
+	}
+	node.SetTraversable(open, recursive)
+	v.refresh()
+}
+
+// Move the cursor to the next line containing s, unfolding sections if needed. Return false if
+// no line matches.
+func (v *LogViewer) NextMatch(s string, ascending bool) bool {
+	nodes := utils.DepthFirstTraversal(v.root, true)[1:]
+	if len(nodes) == 0 {
+		return false
+	}
+
+	current := len(nodes)
+	if v.activeLine >= 0 && v.activeLine < len(v.rows) {
+		for i, node := range nodes {
+			if node == v.rows[v.activeLine] {
+				current = i
+				break
+			}
+		}
+	}
+
+	step := 1
+	if !ascending {
+		step = -1
+	}
+	for k := 1; k <= len(nodes); k++ {
+		match := nodes[utils.Modulo(current+k*step, len(nodes))].(*logNode)
+		if !match.line.Contains(s) {
+			continue
+		}
+
+		for n := match.parent; n != nil; n = n.parent {
+			n.traversable = true
+		}
+		v.refresh()
+		for i, row := range v.rows {
+			if row == match {
+				v.Scroll(i - v.activeLine)
+				break
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Set the message shown in the title bar
+func (v *LogViewer) SetStatus(status string) {
+	v.status = status
+}
+
+// Process a mouse event: the wheel scrolls the log, a click moves the cursor to the line under
+// the pointer and a click on the fold marker of a section folds or unfolds it
+func (v *LogViewer) ProcessMouse(event mouseEvent) (command, bool) {
+	switch event.action {
+	case mouseWheelUp:
+		v.Scroll(-wheelScrollAmount)
+	case mouseWheelDown:
+		v.Scroll(wheelScrollAmount)
+	case mouseClick, mouseDoubleClick:
+		// The first line of the view is the title bar
+		i := v.topLine + event.y - 1
+		if event.y < 1 || i >= v.nbrLines() {
+			break
+		}
+		v.Scroll(i - v.activeLine)
+		if i < len(v.rows) && v.rows[i].section != "" && event.x < runewidth.StringWidth("+ ") {
+			v.SetTraversable(!v.rows[i].traversable, false)
+		}
+	}
+
+	return "", false
+}
+
+func (v LogViewer) Size() (int, int) {
+	return v.width, v.height
+}
+
+func (v *LogViewer) Resize(width int, height int) {
+	v.width = utils.MaxInt(0, width)
+	v.height = utils.MaxInt(0, height)
+	if v.follow {
+		v.Bottom()
+	} else {
+		v.Scroll(0)
+	}
+}
+
+func (v LogViewer) Text() []text.LocalizedStyledString {
+	texts := make([]text.LocalizedStyledString, 0, v.height)
+	if v.height == 0 {
+		return texts
+	}
+
+	title := v.status
+	if !v.follow {
+		title += " [scroll lock]"
+	}
+	titleText := text.NewStyledString(title, text.TableHeader)
+	titleText.Align(text.Left, v.width)
+	texts = append(texts, text.LocalizedStyledString{
+		X: 0,
+		Y: 0,
+		S: titleText,
+	})
+
+	for i := 0; i < v.NbrRows() && v.topLine+i < v.nbrLines(); i++ {
+		var line text.StyledString
+		if v.topLine+i < len(v.rows) {
+			node := v.rows[v.topLine+i]
+			prefix := text.StyledString{}
+			switch {
+			case node.section == "":
+			case node.traversable:
+				prefix = text.NewStyledString("- ")
+			default:
+				prefix = text.NewStyledString("+ ")
+			}
+			// Join returns a copy so the classes added below do not alter the node
+			line = text.Join([]text.StyledString{prefix, node.line}, text.StyledString{})
+		} else {
+			state := v.ansi
+			partial := foldMarker.ReplaceAllString(v.partial, "")
+			line = text.Join([]text.StyledString{v.parse(&state, partial)}, text.StyledString{})
+		}
+		if v.topLine+i == v.activeLine {
+			line.Align(text.Left, v.width)
+			line.Add(text.ActiveRow)
+		}
+		texts = append(texts, text.LocalizedStyledString{
+			X: 0,
+			Y: i + 1,
+			S: line,
+		})
+	}
+
+	return texts
+}
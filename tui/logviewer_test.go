@@ -0,0 +1,211 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func logViewerLines(v LogViewer) []string {
+	lines := make([]string, 0)
+	for _, line := range v.Text()[1:] {
+		lines = append(lines, line.S.String())
+	}
+	return lines
+}
+
+func TestLogViewer_Write(t *testing.T) {
+	t.Run("content split across writes", func(t *testing.T) {
+		v, err := NewLogViewer(20, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v.Write("first\nsec")
+		v.Write("ond\n\x1b[31mthird\x1b[0m\n")
+		v.Write("aaa\rbbb\n\tfifth")
+		// Only the active line is padded
+		v.Top()
+
+		expected := []string{"first               ", "second", "third", "bbb", "        fifth"}
+		if diff := cmp.Diff(expected, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("follow end of log", func(t *testing.T) {
+		v, err := NewLogViewer(1, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v.Write("1\n2\n3\n4\n")
+		if diff := cmp.Diff([]string{"3", "4"}, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		v.Write("5\n")
+		if diff := cmp.Diff([]string{"4", "5"}, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("moving the cursor up stops following the log", func(t *testing.T) {
+		v, err := NewLogViewer(1, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v.Write("1\n2\n3\n4\n")
+		v.Scroll(-2)
+		v.Write("5\n")
+		if diff := cmp.Diff([]string{"2", "3"}, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+
+		v.Bottom()
+		v.Write("6\n")
+		if diff := cmp.Diff([]string{"5", "6"}, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestLogViewer_Folding(t *testing.T) {
+	log := "" +
+		"travis_fold:start:install\r\x1b[0K$ make install\n" +
+		"installing\n" +
+		"travis_fold:end:install\r\x1b[0K\n" +
+		"\x1b[0Ksection_start:1560896352:script\r\x1b[0KExecuting script\n" +
+		"testing\n" +
+		"\x1b[0Ksection_end:1560896353:script\r\x1b[0K\n" +
+		"section_start:1560896354:after[collapsed=true]\r\x1b[0K\n" +
+		"cleanup\n" +
+		"section_end:1560896355:after\r\x1b[0K\n" +
+		"done\n"
+
+	v, err := NewLogViewer(1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Write(log)
+
+	t.Run("sections are folded based on markers", func(t *testing.T) {
+		expected := []string{
+			"+ $ make install",
+			"- Executing script",
+			"testing",
+			"+ after",
+			"done",
+		}
+		if diff := cmp.Diff(expected, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("unfold section at cursor", func(t *testing.T) {
+		v.Top()
+		v.SetTraversable(true, false)
+		expected := []string{
+			"- $ make install",
+			"installing",
+			"- Executing script",
+			"testing",
+			"+ after",
+			"done",
+		}
+		if diff := cmp.Diff(expected, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("fold section containing the cursor", func(t *testing.T) {
+		v.Scroll(1)
+		v.SetTraversable(false, false)
+		expected := []string{
+			"+ $ make install",
+			"- Executing script",
+			"testing",
+			"+ after",
+			"done",
+		}
+		if diff := cmp.Diff(expected, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if v.activeLine != 0 {
+			t.Fatalf("expected cursor on line 0 but got %d", v.activeLine)
+		}
+	})
+
+	t.Run("search unfolds sections", func(t *testing.T) {
+		if !v.NextMatch("cleanup", true) {
+			t.Fatal("expected a match")
+		}
+		if diff := cmp.Diff("cleanup", v.rows[v.activeLine].line.String()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if v.NextMatch("not found", true) {
+			t.Fatal("expected no match")
+		}
+	})
+}
+
+func TestLogViewer_Resize(t *testing.T) {
+	v, err := NewLogViewer(20, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Write("1\n2\n3\n4\n")
+
+	// Must not panic
+	for _, height := range []int{0, 1, 10} {
+		v.Resize(20, height)
+		v.Text()
+	}
+}
+
+func TestLogViewer_ProcessMouse(t *testing.T) {
+	log := "" +
+		"travis_fold:start:install\r\x1b[0K$ make install\n" +
+		"installing\n" +
+		"travis_fold:end:install\r\x1b[0K\n" +
+		"line 1\n" +
+		"line 2\n" +
+		"line 3\n" +
+		"line 4\n"
+
+	v, err := NewLogViewer(1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Write(log)
+
+	t.Run("click moves the cursor", func(t *testing.T) {
+		v.ProcessMouse(mouseEvent{x: 0, y: 3, action: mouseClick})
+		if v.activeLine != 2 {
+			t.Fatalf("expected cursor on line 2 but got %d", v.activeLine)
+		}
+	})
+
+	t.Run("click on a fold marker unfolds the section", func(t *testing.T) {
+		v.ProcessMouse(mouseEvent{x: 0, y: 1, action: mouseClick})
+		expected := []string{
+			"- $ make install",
+			"installing",
+			"line 1",
+			"line 2",
+			"line 3",
+			"line 4",
+		}
+		if diff := cmp.Diff(expected, logViewerLines(v)); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("wheel scrolls the log", func(t *testing.T) {
+		v.ProcessMouse(mouseEvent{action: mouseWheelDown})
+		if v.activeLine != wheelScrollAmount {
+			t.Fatalf("expected cursor on line %d but got %d", wheelScrollAmount, v.activeLine)
+		}
+	})
+}
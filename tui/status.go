@@ -0,0 +1,152 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/export"
+)
+
+// Formats of the output of RunStatus
+var statusWriters = map[string]func(w io.Writer, c cache.Cache, targets []cache.Target, loc *time.Location) error{
+	"text": writeTree,
+	"json": func(w io.Writer, c cache.Cache, targets []cache.Target, loc *time.Location) error {
+		return export.JSON(w, targetsPipelines(c, targets))
+	},
+	"junit": func(w io.Writer, c cache.Cache, targets []cache.Target, loc *time.Location) error {
+		return export.JUnit(w, targetsPipelines(c, targets))
+	},
+}
+
+// Monitor the pipelines of all targets without user interface until none of them is active, then
+// write the pipelines to w in the format 'format' ("text", "json" or "junit") and return their
+// overall state. If ctx is canceled before all pipelines are finished, the pipelines are written
+// to w as they are and ctx.Err() is returned along with their overall state.
+// Pipelines are only considered finished once every source provider has found the statuses of
+// each target and the monitoring of all the pipelines they reference has ended.
+func RunStatus(ctx context.Context, w io.Writer, format string, targets []cache.Target, pipelineURLs []string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, store *cache.Store, loc *time.Location) (cache.State, error) {
+	if len(CIProviders) == 0 {
+		return cache.Unknown, ErrNoProvider
+	}
+	if len(targets) == 0 {
+		return cache.Unknown, ErrNoTarget
+	}
+	write, exists := statusWriters[format]
+	if !exists {
+		return cache.Unknown, fmt.Errorf("unknown format %q (expected text, json or junit)", format)
+	}
+
+	cacheDB := cache.NewCache(CIProviders, SourceProviders)
+	if store != nil {
+		var err error
+		if cacheDB, err = cache.NewPersistentCache(CIProviders, SourceProviders, store); err != nil {
+			return cache.Unknown, err
+		}
+	}
+
+	monitorCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := make(chan time.Time)
+	errc := make(chan error)
+	monitors := 0
+	monitor := func(f func() error) {
+		monitors++
+		go func() {
+			err := f()
+			select {
+			case errc <- err:
+			case <-monitorCtx.Done():
+			}
+		}()
+	}
+	if len(pipelineURLs) > 0 {
+		monitor(func() error {
+			return cacheDB.MonitorPipelineURLs(monitorCtx, targets[0], pipelineURLs, updates)
+		})
+	} else {
+		for _, target := range targets {
+			target := target
+			monitor(func() error {
+				err := cacheDB.WaitPipelines(monitorCtx, target, updates)
+				if err == cache.ErrUnknownGitReference {
+					err = fmt.Errorf("git reference %q was not found on remote server(s)", target.Ref)
+				}
+				return err
+			})
+		}
+	}
+
+	for {
+		select {
+		case <-updates:
+			// Pipelines found by one provider may be finished while another provider is still
+			// looking for pipelines, so wait for all monitoring functions to return
+		case err := <-errc:
+			if err != nil {
+				return cache.Unknown, err
+			}
+			// Monitoring functions only return without error once all pipelines are finished
+			if monitors--; monitors == 0 {
+				state := pipelinesState(cacheDB, targets)
+				return state, write(w, cacheDB, targets, loc)
+			}
+
+		case <-ctx.Done():
+			state := pipelinesState(cacheDB, targets)
+			if err := write(w, cacheDB, targets, loc); err != nil {
+				return state, err
+			}
+			return state, ctx.Err()
+		}
+	}
+}
+
+// Return the overall state of the pipelines of all targets
+func pipelinesState(c cache.Cache, targets []cache.Target) cache.State {
+	steps := make([]cache.Step, 0)
+	for _, p := range targetsPipelines(c, targets) {
+		steps = append(steps, p.Step)
+	}
+
+	if len(steps) == 0 {
+		return cache.Unknown
+	}
+	return cache.Aggregate(steps).State
+}
+
+// Return the pipelines of all targets
+func targetsPipelines(c cache.Cache, targets []cache.Target) []cache.Pipeline {
+	pipelines := make([]cache.Pipeline, 0)
+	for _, target := range targets {
+		pipelines = append(pipelines, c.PipelinesByRef(target)...)
+	}
+	return pipelines
+}
+
+// Columns written by writeTree. Unlike the table of the user interface, the git reference is
+// shown even if there is only one target since there is no header line to show it.
+var treeColumns = Columns{
+	names: []string{"REF", "PIPELINE", "TYPE", "STATE", "STARTED", "DURATION", "NAME"},
+}
+
+// Write the pipelines of all targets to w as a plain text tree
+func writeTree(w io.Writer, c cache.Cache, targets []cache.Target, loc *time.Location) error {
+	table, err := NewTable(targetsSource(c, targets, false), 0, 0, loc)
+	if err != nil {
+		return err
+	}
+	table.SetColumns(treeColumns)
+	table.Resize(0, len(table.rows)+1)
+
+	for _, line := range table.Text() {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line.S.String(), " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nbedos/citop/cache"
+)
+
+type testProvider struct {
+	pipeline cache.Pipeline
+}
+
+func (p testProvider) ID() string   { return "test-0" }
+func (p testProvider) Host() string { return "example.com" }
+func (p testProvider) Name() string { return "test" }
+func (p testProvider) Log(ctx context.Context, step cache.Step) (string, error) {
+	return "", cache.ErrNoLogHere
+}
+func (p testProvider) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	return p.pipeline, nil
+}
+
+// CI provider serving the pipelines of several URLs
+type testPipelinesProvider struct {
+	pipelines map[string]cache.Pipeline
+}
+
+func (p testPipelinesProvider) ID() string   { return "test-1" }
+func (p testPipelinesProvider) Host() string { return "example.com" }
+func (p testPipelinesProvider) Name() string { return "test" }
+func (p testPipelinesProvider) Log(ctx context.Context, step cache.Step) (string, error) {
+	return "", cache.ErrNoLogHere
+}
+func (p testPipelinesProvider) BuildFromURL(ctx context.Context, u string) (cache.Pipeline, error) {
+	pipeline, exists := p.pipelines[u]
+	if !exists {
+		return cache.Pipeline{}, cache.ErrUnknownPipelineURL
+	}
+	return pipeline, nil
+}
+
+// Source provider answering with the same statuses after 'delay'
+type testSourceProvider struct {
+	id       string
+	delay    time.Duration
+	statuses []string
+}
+
+func (p testSourceProvider) ID() string { return p.id }
+func (p testSourceProvider) RefStatuses(ctx context.Context, url string, ref string, sha string) ([]string, error) {
+	select {
+	case <-time.After(p.delay):
+		return p.statuses, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+func (p testSourceProvider) Commit(ctx context.Context, repo string, sha string) (cache.Commit, error) {
+	return cache.Commit{Sha: "a24840cf94b395af69da4a1001d32e3694637e20"}, nil
+}
+
+func TestRunStatus(t *testing.T) {
+	target := cache.Target{Repository: "https://example.com/owner/repo", Ref: "master"}
+	pipelineURLs := []string{"https://example.com/owner/repo/pipelines/1"}
+	pipeline := func(state cache.State) cache.Pipeline {
+		return cache.Pipeline{
+			Number: "1",
+			GitReference: cache.GitReference{
+				SHA: "a24840cf94b395af69da4a1001d32e3694637e20",
+				Ref: "master",
+			},
+			Step: cache.Step{
+				ID:    "1",
+				State: state,
+				Children: []cache.Step{
+					{
+						ID:    "2",
+						Name:  "tests",
+						Type:  cache.StepJob,
+						State: state,
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("finished pipeline", func(t *testing.T) {
+		provider := testProvider{pipeline: pipeline(cache.Failed)}
+		w := strings.Builder{}
+		state, err := RunStatus(context.Background(), &w, "text", []cache.Target{target}, pipelineURLs, []cache.CIProvider{provider}, nil, nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != cache.Failed {
+			t.Fatalf("expected state %q but got %q", cache.Failed, state)
+		}
+
+		expected := "" +
+			"REF     PIPELINE  TYPE  STATE   STARTED  DURATION  NAME\n" +
+			"master        #1     P  failed  -               -  -test\n" +
+			"master        #1     J  failed  -               -   └── tests\n"
+		if diff := cmp.Diff(expected, w.String()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+
+	t.Run("active pipeline", func(t *testing.T) {
+		provider := testProvider{pipeline: pipeline(cache.Running)}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		w := strings.Builder{}
+		state, err := RunStatus(ctx, &w, "text", []cache.Target{target}, pipelineURLs, []cache.CIProvider{provider}, nil, nil, time.UTC)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected error %v but got %v", context.DeadlineExceeded, err)
+		}
+		if state != cache.Running {
+			t.Fatalf("expected state %q but got %q", cache.Running, state)
+		}
+	})
+
+	t.Run("pipeline found late by a second source provider", func(t *testing.T) {
+		passed := pipeline(cache.Passed)
+		failed := pipeline(cache.Failed)
+		failed.Number = "2"
+		failed.Step.ID = "2"
+		provider := testPipelinesProvider{
+			pipelines: map[string]cache.Pipeline{
+				"https://example.com/owner/repo/pipelines/1": passed,
+				"https://example.com/owner/repo/pipelines/2": failed,
+			},
+		}
+		sourceProviders := []cache.SourceProvider{
+			testSourceProvider{
+				id:       "early",
+				statuses: []string{"https://example.com/owner/repo/pipelines/1"},
+			},
+			testSourceProvider{
+				id:       "late",
+				delay:    200 * time.Millisecond,
+				statuses: []string{"https://example.com/owner/repo/pipelines/2"},
+			},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		w := strings.Builder{}
+		state, err := RunStatus(ctx, &w, "text", []cache.Target{target}, nil, []cache.CIProvider{provider}, sourceProviders, nil, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != cache.Failed {
+			t.Fatalf("expected state %q but got %q", cache.Failed, state)
+		}
+		if !strings.Contains(w.String(), "#2") {
+			t.Fatalf("expected pipeline #2 in output %q", w.String())
+		}
+	})
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/nbedos/citop/text"
 	"github.com/nbedos/citop/utils"
@@ -15,6 +16,8 @@ type StatusBar struct {
 	InputBuffer  string
 	ShowInput    bool
 	inputPrefix  string
+	// Suggestions shown after the input buffer
+	Completions []string
 }
 
 func NewStatusBar(width, height int) (StatusBar, error) {
@@ -47,12 +50,22 @@ func (s *StatusBar) Resize(width int, height int) {
 	s.height = utils.MaxInt(0, height)
 }
 
+// The status bar does not react to the mouse
+func (s *StatusBar) ProcessMouse(event mouseEvent) (command, bool) {
+	return "", false
+}
+
 func (s StatusBar) Text() []text.LocalizedStyledString {
 	if s.ShowInput {
+		input := text.NewStyledString(fmt.Sprintf("%s%s", s.inputPrefix, s.InputBuffer))
+		if len(s.Completions) > 0 {
+			input.Append("  ")
+			input.Append(strings.Join(s.Completions, " "), text.StatusSkipped)
+		}
 		return []text.LocalizedStyledString{{
 			X: 0,
 			Y: utils.MaxInt(s.height-1, 0),
-			S: text.NewStyledString(fmt.Sprintf("%s%s", s.inputPrefix, s.InputBuffer)),
+			S: input,
 		}}
 	}
 
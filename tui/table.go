@@ -1,8 +1,10 @@
 package tui
 
 import (
-	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mattn/go-runewidth"
@@ -21,7 +23,156 @@ type Table struct {
 	width      int
 	sep        string
 	maxWidths  map[string]int
+	columns    Columns
+	filter     filter
+	sort       sortOrder
 	location   *time.Location
+	// Tree prefix of each row of t.rows
+	prefixes []string
+}
+
+// Order of the rows of a table. Rows are sorted among their siblings at every level of the tree.
+type sortOrder struct {
+	// Column used to sort rows. If empty, rows are shown in the order of the data source.
+	column     string
+	descending bool
+}
+
+// Return the order obtained by toggling the sorting by 'column'. Rows are sorted in ascending
+// order first, then in descending order, then in the order of the data source.
+func (s sortOrder) toggle(column string) sortOrder {
+	switch {
+	case s.column != column:
+		return sortOrder{column: column}
+	case !s.descending:
+		return sortOrder{column: column, descending: true}
+	default:
+		return sortOrder{}
+	}
+}
+
+// Return the sort indicator shown after the header of column
+func (s sortOrder) indicator(column string) string {
+	switch {
+	case s.column != column:
+		return ""
+	case s.descending:
+		return "↓"
+	default:
+		return "↑"
+	}
+}
+
+func (s sortOrder) less(a cache.HierarchicalTabularSourceRow, b cache.HierarchicalTabularSourceRow) bool {
+	sa, ok := a.(cache.SortableRow)
+	if !ok {
+		return false
+	}
+	sb, ok := b.(cache.SortableRow)
+	if !ok {
+		return false
+	}
+	ka, oka := sa.SortKey(s.column)
+	kb, okb := sb.SortKey(s.column)
+	if !oka || !okb {
+		return false
+	}
+	if s.descending {
+		return ka > kb
+	}
+	return ka < kb
+}
+
+// Row of the data source along with the descendants shown by the table
+type tableRow struct {
+	cache.HierarchicalTabularSourceRow
+	children []*tableRow
+	prefix   string
+}
+
+func (r *tableRow) SetPrefix(s string) {
+	r.prefix = s
+	r.HierarchicalTabularSourceRow.SetPrefix(s)
+}
+
+func (r tableRow) Children() []utils.TreeNode {
+	children := make([]utils.TreeNode, len(r.children))
+	for i := range r.children {
+		children[i] = r.children[i]
+	}
+	return children
+}
+
+// Columns shown by a table, in order, and the maximum width of each column
+type Columns struct {
+	// Names of the columns. If empty, the default columns of the data source are shown.
+	names []string
+	// Maximum width of each column. Cells wider than their column are truncated.
+	maxWidths map[string]int
+}
+
+// Return the column name matching 'name' (e.g. "allow_failure" for "ALLOW FAILURE")
+func columnName(name string) (string, error) {
+	header := strings.ToUpper(strings.Replace(name, "_", " ", -1))
+	for _, column := range cache.BuildsColumns() {
+		if column == header {
+			return column, nil
+		}
+	}
+	return "", fmt.Errorf("unknown column %q", name)
+}
+
+// Return the columns 'names' of the table listing pipelines, in this order, with the maximum
+// widths 'maxWidths'. Column names are case insensitive, with underscores in place of spaces
+// ("state", "allow_failure"...). If names is empty, the default columns are shown.
+func NewColumns(names []string, maxWidths map[string]int) (Columns, error) {
+	columns := Columns{
+		names:     make([]string, 0, len(names)),
+		maxWidths: make(map[string]int, len(maxWidths)),
+	}
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		column, err := columnName(name)
+		if err != nil {
+			return Columns{}, err
+		}
+		if _, exists := seen[column]; exists {
+			return Columns{}, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[column] = struct{}{}
+		columns.names = append(columns.names, column)
+	}
+	for name, width := range maxWidths {
+		column, err := columnName(name)
+		if err != nil {
+			return Columns{}, err
+		}
+		if width <= 0 {
+			return Columns{}, fmt.Errorf("%s: maximum width must be greater than 0", name)
+		}
+		columns.maxWidths[column] = width
+	}
+
+	return columns, nil
+}
+
+// Return the columns 'names' with the same maximum widths as c
+func (c Columns) withNames(names []string) (Columns, error) {
+	columns, err := NewColumns(names, nil)
+	if err != nil {
+		return Columns{}, err
+	}
+	columns.maxWidths = c.maxWidths
+	return columns, nil
+}
+
+// Return the names of the columns as written in the configuration file
+func (c Columns) String() string {
+	names := make([]string, 0, len(c.names))
+	for _, name := range c.names {
+		names = append(names, strings.ToLower(strings.Replace(name, " ", "_", -1)))
+	}
+	return strings.Join(names, " ")
 }
 
 func NewTable(source cache.HierarchicalTabularDataSource, width int, height int, loc *time.Location) (Table, error) {
@@ -47,9 +198,81 @@ func (t Table) NbrRows() int {
 	return utils.MaxInt(0, t.height-1)
 }
 
+// Return the names of the columns shown, in order. Columns not provided by the data source are
+// left out.
+func (t Table) headers() []string {
+	if len(t.columns.names) == 0 {
+		return t.source.Headers()
+	}
+	alignment := t.source.Alignment()
+	headers := make([]string, 0, len(t.columns.names))
+	for _, name := range t.columns.names {
+		if _, exists := alignment[name]; exists {
+			headers = append(headers, name)
+		}
+	}
+	return headers
+}
+
+// Return the names of the columns shown by default, or those set by SetColumns
+func (t Table) Columns() Columns {
+	if len(t.columns.names) == 0 {
+		columns := t.columns
+		columns.names = t.source.Headers()
+		return columns
+	}
+	return t.columns
+}
+
+// Set the columns shown by the table
+func (t *Table) SetColumns(columns Columns) {
+	t.columns = columns
+	t.computeMaxWidths()
+}
+
+// Set the filter of the table. Rows that do not match the filter are hidden unless one of their
+// descendants matches it.
+func (t *Table) SetFilter(f filter) {
+	t.filter = f
+	t.Refresh()
+}
+
+// Set the order of the rows of the table
+func (t *Table) SetSort(s sortOrder) {
+	t.sort = s
+	t.Refresh()
+}
+
+// Return the rows of 'nodes' shown by the table, recursively. Rows are kept if they match the
+// filter or if one of their descendants does, and sorted among their siblings.
+func (t Table) visibleRows(nodes []cache.HierarchicalTabularSourceRow) []*tableRow {
+	rows := make([]*tableRow, 0, len(nodes))
+	for _, node := range nodes {
+		children := make([]cache.HierarchicalTabularSourceRow, 0, len(node.Children()))
+		for _, child := range node.Children() {
+			children = append(children, child.(cache.HierarchicalTabularSourceRow))
+		}
+		row := tableRow{
+			HierarchicalTabularSourceRow: node,
+			children:                     t.visibleRows(children),
+		}
+		if len(row.children) > 0 || t.filter.match(node, t.location) {
+			rows = append(rows, &row)
+		}
+	}
+	if t.sort.column != "" {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return t.sort.less(rows[i].HierarchicalTabularSourceRow, rows[j].HierarchicalTabularSourceRow)
+		})
+	}
+
+	return rows
+}
+
 func (t *Table) computeMaxWidths() {
-	for _, header := range t.source.Headers() {
-		t.maxWidths[header] = utils.MaxInt(t.maxWidths[header], runewidth.StringWidth(header))
+	for _, header := range t.headers() {
+		width := runewidth.StringWidth(header + t.sort.indicator(header))
+		t.maxWidths[header] = utils.MaxInt(t.maxWidths[header], width)
 	}
 	for _, row := range t.rows {
 		for header, value := range row.Tabular(t.location) {
@@ -87,10 +310,13 @@ func (t *Table) Refresh() {
 		activeKey = t.rows[t.activeLine].Key()
 	}
 	t.rows = make([]cache.HierarchicalTabularSourceRow, 0, len(t.nodes))
-	for _, node := range t.nodes {
+	t.prefixes = make([]string, 0, len(t.nodes))
+	for _, node := range t.visibleRows(t.nodes) {
 		cache.Prefix(node, "", true)
 		for _, childRow := range utils.DepthFirstTraversal(node, false) {
-			t.rows = append(t.rows, childRow.(cache.HierarchicalTabularSourceRow))
+			row := childRow.(*tableRow)
+			t.rows = append(t.rows, row.HierarchicalTabularSourceRow)
+			t.prefixes = append(t.prefixes, row.prefix)
 			// change t.activeline so that the same row stays active, except if t.activeLine == 0
 			if t.activeLine != 0 && activeKey != nil && t.rows[len(t.rows)-1].Key() == activeKey {
 				t.activeLine = len(t.rows) - 1
@@ -169,15 +395,39 @@ func (t *Table) NextMatch(s string, ascending bool) bool {
 	return false
 }
 
+// Return the width of the column 'name'
+func (t Table) columnWidth(name string) int {
+	width := t.maxWidths[name]
+	if maxWidth, exists := t.columns.maxWidths[name]; exists {
+		width = utils.MinInt(width, maxWidth)
+	}
+	return width
+}
+
+// Return the horizontal position of the column 'name' and whether the column is shown
+func (t Table) columnOffset(name string) (int, bool) {
+	x := 0
+	for _, header := range t.headers() {
+		if header == name {
+			return x, true
+		}
+		x += t.columnWidth(header) + runewidth.StringWidth(t.sep)
+	}
+	return 0, false
+}
+
 func (t Table) stringFromColumns(values map[string]text.StyledString, header bool) text.StyledString {
-	paddedColumns := make([]text.StyledString, len(t.source.Headers()))
-	for j, name := range t.source.Headers() {
+	headers := t.headers()
+	paddedColumns := make([]text.StyledString, len(headers))
+	for j, name := range headers {
 		alignment := text.Left
 		if !header {
 			alignment = t.source.Alignment()[name]
 		}
+		width := t.columnWidth(name)
 		paddedColumns[j] = values[name]
-		paddedColumns[j].Align(alignment, t.maxWidths[name])
+		paddedColumns[j].Truncate(width)
+		paddedColumns[j].Align(alignment, width)
 	}
 
 	line := text.Join(paddedColumns, text.NewStyledString(t.sep))
@@ -186,6 +436,40 @@ func (t Table) stringFromColumns(values map[string]text.StyledString, header boo
 	return line
 }
 
+// Column starting with the tree prefix of rows
+const treeColumn = "NAME"
+
+// Process a mouse event: the wheel scrolls the table and a click moves the cursor to the row
+// under the pointer. Clicking the tree prefix of a row folds or unfolds it, double clicking the
+// row opens its log.
+func (t *Table) ProcessMouse(event mouseEvent) (command, bool) {
+	switch event.action {
+	case mouseWheelUp:
+		t.Scroll(-wheelScrollAmount)
+	case mouseWheelDown:
+		t.Scroll(wheelScrollAmount)
+	case mouseClick, mouseDoubleClick:
+		// The first line of the table is the header
+		i := t.topLine + event.y - 1
+		if event.y < 1 || i >= len(t.rows) {
+			break
+		}
+		t.Scroll(i - t.activeLine)
+		row := t.rows[i]
+		if x, exists := t.columnOffset(treeColumn); exists && len(row.Children()) > 0 {
+			if event.x >= x && event.x < x+runewidth.StringWidth(t.prefixes[i]) {
+				t.SetTraversable(!row.Traversable(), false)
+				break
+			}
+		}
+		if event.action == mouseDoubleClick {
+			return commandLogs, true
+		}
+	}
+
+	return "", false
+}
+
 func (t Table) Size() (int, int) {
 	return t.width, t.height
 }
@@ -207,8 +491,8 @@ func (t *Table) Text() []text.LocalizedStyledString {
 
 	if t.height > 0 {
 		headers := make(map[string]text.StyledString)
-		for _, header := range t.source.Headers() {
-			headers[header] = text.NewStyledString(header)
+		for _, header := range t.headers() {
+			headers[header] = text.NewStyledString(header + t.sort.indicator(header))
 		}
 
 		s := t.stringFromColumns(headers, true)
@@ -245,11 +529,16 @@ func (t Table) ActiveRowURL() utils.NullString {
 	return utils.NullString{}
 }
 
-func (t *Table) ActiveRowLog(ctx context.Context) (string, error) {
+func (t Table) ActiveRow() (cache.HierarchicalTabularSourceRow, bool) {
 	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
-		key := t.rows[t.activeLine].Key()
-		return t.source.Log(ctx, key)
+		return t.rows[t.activeLine], true
 	}
+	return nil, false
+}
 
-	return "", cache.ErrNoLogHere
+func (t Table) ActiveRowKey() (interface{}, bool) {
+	if t.activeLine >= 0 && t.activeLine < len(t.rows) {
+		return t.rows[t.activeLine].Key(), true
+	}
+	return nil, false
 }
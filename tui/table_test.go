@@ -2,9 +2,12 @@ package tui
 
 import (
 	"context"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/mattn/go-runewidth"
 	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/text"
@@ -86,6 +89,26 @@ func (s testSource) Log(context.Context, interface{}) (string, error) {
 	return "", nil
 }
 
+func (s testSource) StreamLog(context.Context, interface{}, io.Writer) error {
+	return nil
+}
+
+func (s testSource) Restart(context.Context, interface{}) error {
+	return nil
+}
+
+func (s testSource) Cancel(context.Context, interface{}) error {
+	return nil
+}
+
+func (s testSource) Trigger(context.Context, interface{}) error {
+	return nil
+}
+
+func (s testSource) Play(context.Context, interface{}, map[string]string) error {
+	return nil
+}
+
 var source = testSource{
 	rows: []testRow{
 		{value: "a"},
@@ -427,3 +450,290 @@ func TestTable_NextMatch(t *testing.T) {
 	})
 
 }
+
+func TestNewColumns(t *testing.T) {
+	t.Run("valid columns", func(t *testing.T) {
+		columns, err := NewColumns([]string{"State", "allow_failure", "NAME"}, map[string]int{"name": 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"STATE", "ALLOW FAILURE", "NAME"}, columns.names); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+		if columns.maxWidths["NAME"] != 20 {
+			t.Fatalf("expected maximum width 20 but got %d", columns.maxWidths["NAME"])
+		}
+		if s := columns.String(); s != "state allow_failure name" {
+			t.Fatalf("expected %q but got %q", "state allow_failure name", s)
+		}
+	})
+
+	invalid := []struct {
+		name      string
+		names     []string
+		maxWidths map[string]int
+	}{
+		{
+			name:  "unknown column",
+			names: []string{"state", "color"},
+		},
+		{
+			name:  "duplicate column",
+			names: []string{"state", "STATE"},
+		},
+		{
+			name:      "unknown column in widths",
+			maxWidths: map[string]int{"color": 10},
+		},
+		{
+			name:      "null width",
+			maxWidths: map[string]int{"name": 0},
+		},
+	}
+	for _, testCase := range invalid {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewColumns(testCase.names, testCase.maxWidths); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestTable_SetColumns(t *testing.T) {
+	source := testSource{
+		rows: []testRow{
+			{value: "short"},
+			{value: "much longer value"},
+		},
+	}
+	table, err := NewTable(source, 20, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.SetColumns(Columns{
+		names:     []string{"VALUE"},
+		maxWidths: map[string]int{"VALUE": 8},
+	})
+
+	lines := make([]string, 0)
+	for _, line := range table.Text() {
+		lines = append(lines, strings.TrimRight(line.S.String(), " "))
+	}
+	expected := []string{"VALUE", "short", "much lo…"}
+	if diff := cmp.Diff(expected, lines); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	t.Run("columns unknown to the data source are left out", func(t *testing.T) {
+		table.SetColumns(Columns{names: []string{"STATE", "VALUE"}})
+		if diff := cmp.Diff([]string{"VALUE"}, table.headers()); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	})
+}
+
+func TestTable_SetFilter(t *testing.T) {
+	table, err := NewTable(source, 10, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parseFilter("e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.SetFilter(f)
+
+	// Row "c" is kept since its child "c.e" matches the filter
+	values := make([]string, 0)
+	for _, row := range table.rows {
+		values = append(values, row.(*testRow).value)
+	}
+	if diff := cmp.Diff([]string{"c", "c.e"}, values); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+	if prefix := table.rows[1].(*testRow).prefix; prefix != " └── " {
+		t.Fatalf("expected %q but got %q", " └── ", prefix)
+	}
+
+	table.SetFilter(filter{})
+	if len(table.rows) != 7 {
+		t.Fatalf("expected 7 rows but got %d", len(table.rows))
+	}
+}
+
+func TestTable_SetFilter_pipelines(t *testing.T) {
+	c := cache.NewCache(nil, nil)
+	target := cache.Target{Repository: "repo", Ref: "master"}
+	pipeline := cache.Pipeline{
+		Step: cache.Step{
+			ID:   "1",
+			Type: cache.StepPipeline,
+			Children: []cache.Step{
+				{ID: "2", Type: cache.StepJob, Name: "lint"},
+				{ID: "3", Type: cache.StepJob, Name: "tests"},
+			},
+		},
+	}
+	pipeline.SetProvider("provider", "example.com")
+	if err := c.SavePipeline(target, pipeline); err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewTable(c.BuildsOfRef(target), 80, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.SetTraversable(true, true)
+
+	testCases := []struct {
+		expression string
+		names      []string
+	}{
+		{
+			expression: "name:lint",
+			names:      []string{"-unknown", " └── lint"},
+		},
+		{
+			// The tree prefix is not part of the name
+			expression: "name~─",
+			names:      []string{},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.expression, func(t *testing.T) {
+			f, err := parseFilter(testCase.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.SetFilter(f)
+
+			names := make([]string, 0)
+			for _, row := range table.rows {
+				names = append(names, row.Tabular(time.UTC)["NAME"].String())
+			}
+			if diff := cmp.Diff(testCase.names, names); len(diff) > 0 {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func (r testRow) SortKey(column string) (int64, bool) {
+	if column != "VALUE" {
+		return 0, false
+	}
+	return int64(r.value[len(r.value)-1]), true
+}
+
+func TestTable_SetSort(t *testing.T) {
+	table, err := NewTable(source, 10, 10, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		order    sortOrder
+		expected []string
+	}{
+		{
+			order:    sortOrder{column: "VALUE", descending: true},
+			expected: []string{"g", "f", "c", "c.e", "c.d", "b", "a"},
+		},
+		{
+			order:    sortOrder{column: "VALUE"},
+			expected: []string{"a", "b", "c", "c.d", "c.e", "f", "g"},
+		},
+		{
+			order:    sortOrder{},
+			expected: []string{"a", "b", "c", "c.d", "c.e", "f", "g"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		table.SetSort(testCase.order)
+		values := make([]string, 0)
+		for _, row := range table.rows {
+			values = append(values, row.(*testRow).value)
+		}
+		if diff := cmp.Diff(testCase.expected, values); len(diff) > 0 {
+			t.Fatal(diff)
+		}
+	}
+
+	t.Run("toggle", func(t *testing.T) {
+		s := sortOrder{}
+		expected := []sortOrder{
+			{column: "STATE"},
+			{column: "STATE", descending: true},
+			{},
+		}
+		for _, e := range expected {
+			if s = s.toggle("STATE"); s != e {
+				t.Fatalf("expected %v but got %v", e, s)
+			}
+		}
+	})
+}
+
+func TestTable_ProcessMouse(t *testing.T) {
+	t.Run("wheel and clicks move the cursor", func(t *testing.T) {
+		table, err := NewTable(longSource, 10, 5, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table.ProcessMouse(mouseEvent{action: mouseWheelDown})
+		if table.activeLine != wheelScrollAmount {
+			t.Fatalf("expected active line %d but got %d", wheelScrollAmount, table.activeLine)
+		}
+
+		// The first line is the header
+		if _, exists := table.ProcessMouse(mouseEvent{x: 0, y: 1, action: mouseClick}); exists {
+			t.Fatal("expected no command")
+		}
+		if table.activeLine != table.topLine {
+			t.Fatalf("expected active line %d but got %d", table.topLine, table.activeLine)
+		}
+
+		cmd, exists := table.ProcessMouse(mouseEvent{x: 0, y: 2, action: mouseDoubleClick})
+		if !exists || cmd != commandLogs {
+			t.Fatalf("expected command %q but got %q", commandLogs, cmd)
+		}
+	})
+
+	t.Run("click on the tree prefix folds the row", func(t *testing.T) {
+		c := cache.NewCache(nil, nil)
+		target := cache.Target{Repository: "repo", Ref: "master"}
+		pipeline := cache.Pipeline{
+			Step: cache.Step{
+				ID:   "1",
+				Type: cache.StepPipeline,
+				Children: []cache.Step{
+					{ID: "2", Type: cache.StepJob, Name: "tests"},
+				},
+			},
+		}
+		pipeline.SetProvider("provider", "example.com")
+		if err := c.SavePipeline(target, pipeline); err != nil {
+			t.Fatal(err)
+		}
+
+		table, err := NewTable(c.BuildsOfRef(target), 80, 10, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, exists := table.columnOffset(treeColumn)
+		if !exists {
+			t.Fatal("expected tree column to be shown")
+		}
+
+		table.ProcessMouse(mouseEvent{x: x, y: 1, action: mouseClick})
+		if len(table.rows) != 1 {
+			t.Fatalf("expected 1 row but got %d", len(table.rows))
+		}
+		table.ProcessMouse(mouseEvent{x: x, y: 1, action: mouseClick})
+		if len(table.rows) != 2 {
+			t.Fatalf("expected 2 rows but got %d", len(table.rows))
+		}
+	})
+}
@@ -37,6 +37,11 @@ func (s *TextArea) Resize(width int, height int) {
 	s.height = utils.MaxInt(0, height)
 }
 
+// The content of a text area does not react to the mouse
+func (s *TextArea) ProcessMouse(event mouseEvent) (command, bool) {
+	return "", false
+}
+
 func (s TextArea) Text() []text.LocalizedStyledString {
 	texts := make([]text.LocalizedStyledString, 0)
 	for i, line := range s.content {
@@ -0,0 +1,294 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/text"
+)
+
+// Style of a class of text. Colors are either W3C color names ("maroon", "silver"...), hex values
+// ("#d55e00"), indexes of the 256-color palette ("208") or "default". An empty color leaves the
+// color unchanged. Attributes are among "bold", "dim", "underline", "blink" and "reverse".
+// The attribute "none" removes attributes set by previous classes.
+type Style struct {
+	Foreground string
+	Background string
+	Attributes []string
+}
+
+// Names of the classes that can be styled by a theme
+var themeClasses = map[string]text.Class{
+	"table_header":   text.TableHeader,
+	"active_row":     text.ActiveRow,
+	"provider":       text.Provider,
+	"status_passed":  text.StatusPassed,
+	"status_running": text.StatusRunning,
+	"status_failed":  text.StatusFailed,
+	"status_skipped": text.StatusSkipped,
+	"git_sha":        text.GitSha,
+	"git_ref":        text.GitRef,
+	"git_tag":        text.GitTag,
+	"git_branch":     text.GitBranch,
+	"git_head":       text.GitHead,
+}
+
+var builtinThemes = map[string]map[text.Class]Style{
+	"default": {
+		text.TableHeader:   {Attributes: []string{"bold", "reverse"}},
+		text.ActiveRow:     {Foreground: "black", Background: "silver", Attributes: []string{"none"}},
+		text.Provider:      {Attributes: []string{"bold"}},
+		text.StatusFailed:  {Foreground: "maroon", Attributes: []string{"none"}},
+		text.StatusPassed:  {Foreground: "green", Attributes: []string{"none"}},
+		text.StatusRunning: {Foreground: "olive", Attributes: []string{"none"}},
+		text.StatusSkipped: {Foreground: "gray", Attributes: []string{"none"}},
+		text.GitSha:        {Foreground: "olive"},
+		text.GitBranch:     {Foreground: "teal", Attributes: []string{"none"}},
+		text.GitTag:        {Foreground: "yellow", Attributes: []string{"none"}},
+		text.GitHead:       {Foreground: "aqua"},
+	},
+	// For terminals with a light background
+	"light": {
+		text.TableHeader:   {Attributes: []string{"bold", "reverse"}},
+		text.ActiveRow:     {Foreground: "white", Background: "navy", Attributes: []string{"none"}},
+		text.Provider:      {Attributes: []string{"bold"}},
+		text.StatusFailed:  {Foreground: "#af0000", Attributes: []string{"none"}},
+		text.StatusPassed:  {Foreground: "#005f00", Attributes: []string{"none"}},
+		text.StatusRunning: {Foreground: "#af5f00", Attributes: []string{"none"}},
+		text.StatusSkipped: {Foreground: "gray", Attributes: []string{"none"}},
+		text.GitSha:        {Foreground: "#875f00"},
+		text.GitBranch:     {Foreground: "navy", Attributes: []string{"none"}},
+		text.GitTag:        {Foreground: "purple", Attributes: []string{"none"}},
+		text.GitHead:       {Foreground: "teal"},
+	},
+	"high-contrast": {
+		text.TableHeader:   {Attributes: []string{"bold", "reverse"}},
+		text.ActiveRow:     {Foreground: "default", Background: "default", Attributes: []string{"none", "reverse", "bold"}},
+		text.Provider:      {Attributes: []string{"bold"}},
+		text.StatusFailed:  {Foreground: "red", Attributes: []string{"none", "bold"}},
+		text.StatusPassed:  {Foreground: "lime", Attributes: []string{"none", "bold"}},
+		text.StatusRunning: {Foreground: "yellow", Attributes: []string{"none", "bold"}},
+		text.StatusSkipped: {Foreground: "silver", Attributes: []string{"none"}},
+		text.GitSha:        {Foreground: "yellow"},
+		text.GitBranch:     {Foreground: "aqua", Attributes: []string{"none", "bold"}},
+		text.GitTag:        {Foreground: "fuchsia", Attributes: []string{"none", "bold"}},
+		text.GitHead:       {Foreground: "aqua", Attributes: []string{"bold"}},
+	},
+	// Okabe-Ito palette, distinguishable with the most common forms of color blindness. States
+	// do not rely on red and green.
+	"colorblind": {
+		text.TableHeader:   {Attributes: []string{"bold", "reverse"}},
+		text.ActiveRow:     {Foreground: "black", Background: "silver", Attributes: []string{"none"}},
+		text.Provider:      {Attributes: []string{"bold"}},
+		text.StatusFailed:  {Foreground: "#d55e00", Attributes: []string{"none", "bold"}},
+		text.StatusPassed:  {Foreground: "#0072b2", Attributes: []string{"none"}},
+		text.StatusRunning: {Foreground: "#e69f00", Attributes: []string{"none"}},
+		text.StatusSkipped: {Foreground: "gray", Attributes: []string{"none"}},
+		text.GitSha:        {Foreground: "#e69f00"},
+		text.GitBranch:     {Foreground: "#56b4e9", Attributes: []string{"none"}},
+		text.GitTag:        {Foreground: "#f0e442", Attributes: []string{"none"}},
+		text.GitHead:       {Foreground: "#009e73"},
+	},
+}
+
+const defaultTheme = "default"
+
+// Return the names of the built-in themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Style of a class of text, validated
+type classStyle struct {
+	foreground *tcell.Color
+	background *tcell.Color
+	reset      bool
+	attributes tcell.AttrMask
+}
+
+// Styles of the classes of text shown by the user interface
+type Theme struct {
+	styles map[text.Class]classStyle
+}
+
+// Return the built-in theme 'name' overridden by 'styles'. The keys of styles are class names
+// ("status_failed", "git_branch"...). If name is empty, the default theme is used.
+func NewTheme(name string, styles map[string]Style) (Theme, error) {
+	if name == "" {
+		name = defaultTheme
+	}
+	builtin, exists := builtinThemes[name]
+	if !exists {
+		return Theme{}, fmt.Errorf("unknown theme %q (expected one of %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+
+	t := Theme{
+		styles: make(map[text.Class]classStyle, len(themeClasses)),
+	}
+	for class, style := range builtin {
+		s, err := newClassStyle(style)
+		if err != nil {
+			// Built-in themes are expected to be valid
+			panic(err)
+		}
+		t.styles[class] = s
+	}
+	for className, style := range styles {
+		class, exists := themeClasses[className]
+		if !exists {
+			return Theme{}, fmt.Errorf("unknown class %q", className)
+		}
+		s, err := newClassStyle(style)
+		if err != nil {
+			return Theme{}, fmt.Errorf("%s: %v", className, err)
+		}
+		t.styles[class] = s
+	}
+
+	return t, nil
+}
+
+// Return the default theme
+func DefaultTheme() Theme {
+	t, err := NewTheme(defaultTheme, nil)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func parseColor(s string) (*tcell.Color, error) {
+	if s == "" {
+		return nil, nil
+	}
+	s = strings.ToLower(s)
+	if s == "default" {
+		c := tcell.ColorDefault
+		return &c, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 255 {
+			return nil, fmt.Errorf("invalid color %q (palette indexes range from 0 to 255)", s)
+		}
+		c := tcell.Color(n)
+		return &c, nil
+	}
+	if c := tcell.GetColor(s); c != tcell.ColorDefault {
+		return &c, nil
+	}
+
+	return nil, fmt.Errorf("invalid color %q", s)
+}
+
+var attributes = map[string]tcell.AttrMask{
+	"bold":      tcell.AttrBold,
+	"dim":       tcell.AttrDim,
+	"underline": tcell.AttrUnderline,
+	"blink":     tcell.AttrBlink,
+	"reverse":   tcell.AttrReverse,
+}
+
+func newClassStyle(style Style) (classStyle, error) {
+	var s classStyle
+	var err error
+	if s.foreground, err = parseColor(style.Foreground); err != nil {
+		return s, err
+	}
+	if s.background, err = parseColor(style.Background); err != nil {
+		return s, err
+	}
+	for _, name := range style.Attributes {
+		if name == "none" {
+			s.reset = true
+			continue
+		}
+		attr, exists := attributes[name]
+		if !exists {
+			return s, fmt.Errorf("invalid attribute %q", name)
+		}
+		s.attributes |= attr
+	}
+
+	return s, nil
+}
+
+// Return the color of the palette made of the first 'colors' colors closest to c
+func fitColor(c tcell.Color, colors int) tcell.Color {
+	if c == tcell.ColorDefault || (c&tcell.ColorIsRGB == 0 && int(c) < colors) {
+		return c
+	}
+	palette := make([]tcell.Color, 0, colors)
+	for i := 0; i < colors && i < 256; i++ {
+		palette = append(palette, tcell.Color(i))
+	}
+	return tcell.FindColor(c, palette)
+}
+
+// Return the function applying style to a tcell.Style on a screen supporting 'colors' colors.
+// Without colors, styles setting a background color are shown in reverse video instead.
+func (s classStyle) apply(colors int) func(tcell.Style) tcell.Style {
+	return func(style tcell.Style) tcell.Style {
+		fg, bg, attrs := style.Decompose()
+		if s.reset {
+			attrs = tcell.AttrNone
+		}
+		attrs |= s.attributes
+		if colors > 0 {
+			if s.foreground != nil {
+				fg = fitColor(*s.foreground, colors)
+			}
+			if s.background != nil {
+				bg = fitColor(*s.background, colors)
+			}
+		} else if s.background != nil && *s.background != tcell.ColorDefault {
+			attrs |= tcell.AttrReverse
+		}
+
+		return style.Foreground(fg).Background(bg).
+			Bold(attrs&tcell.AttrBold != 0).
+			Dim(attrs&tcell.AttrDim != 0).
+			Underline(attrs&tcell.AttrUnderline != 0).
+			Blink(attrs&tcell.AttrBlink != 0).
+			Reverse(attrs&tcell.AttrReverse != 0)
+	}
+}
+
+// Return the style sheet of the theme for a screen supporting 'colors' colors. Colors of the theme
+// missing from the screen palette are replaced by the closest color of the palette. If colors is
+// 0, no color is used at all, not even the colors of logs.
+func (t Theme) StyleSheet(colors int) text.StyleSheet {
+	styleSheet := make(text.StyleSheet)
+	for class, style := range t.styles {
+		styleSheet[class] = style.apply(colors)
+	}
+
+	ansiAttributes := map[text.Class]tcell.AttrMask{
+		text.ANSIBold:      tcell.AttrBold,
+		text.ANSIDim:       tcell.AttrDim,
+		text.ANSIUnderline: tcell.AttrUnderline,
+		text.ANSIBlink:     tcell.AttrBlink,
+		text.ANSIReverse:   tcell.AttrReverse,
+	}
+	for class, attr := range ansiAttributes {
+		styleSheet[class] = classStyle{attributes: attr}.apply(colors)
+	}
+
+	if colors > 0 {
+		// Colors of the ANSI palette are the first 16 colors of tcell
+		for i := 0; i < 16; i++ {
+			color := tcell.Color(i)
+			styleSheet[text.ANSIForegroundClass(i)] = classStyle{foreground: &color}.apply(colors)
+			styleSheet[text.ANSIBackgroundClass(i)] = classStyle{background: &color}.apply(colors)
+		}
+	}
+
+	return styleSheet
+}
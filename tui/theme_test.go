@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/text"
+)
+
+func TestNewTheme(t *testing.T) {
+	t.Run("built-in themes", func(t *testing.T) {
+		for _, name := range ThemeNames() {
+			if _, err := NewTheme(name, nil); err != nil {
+				t.Fatalf("theme %q: %v", name, err)
+			}
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		theme  string
+		styles map[string]Style
+	}{
+		{
+			name:  "unknown theme",
+			theme: "solarized-neon",
+		},
+		{
+			name: "unknown class",
+			styles: map[string]Style{
+				"status_unknown": {Foreground: "red"},
+			},
+		},
+		{
+			name: "invalid color",
+			styles: map[string]Style{
+				"status_failed": {Foreground: "reddish"},
+			},
+		},
+		{
+			name: "palette index out of range",
+			styles: map[string]Style{
+				"status_failed": {Background: "256"},
+			},
+		},
+		{
+			name: "invalid attribute",
+			styles: map[string]Style{
+				"status_failed": {Attributes: []string{"italic"}},
+			},
+		},
+	}
+	for _, testCase := range invalid {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := NewTheme(testCase.theme, testCase.styles); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestTheme_StyleSheet(t *testing.T) {
+	t.Run("default theme", func(t *testing.T) {
+		styleSheet := DefaultTheme().StyleSheet(256)
+		testCases := []struct {
+			class    text.Class
+			style    tcell.Style
+			expected tcell.Style
+		}{
+			{
+				class:    text.ActiveRow,
+				style:    tcell.StyleDefault.Bold(true).Underline(true),
+				expected: tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorSilver),
+			},
+			{
+				class:    text.StatusFailed,
+				style:    tcell.StyleDefault.Background(tcell.ColorSilver).Bold(true),
+				expected: tcell.StyleDefault.Foreground(tcell.ColorMaroon).Background(tcell.ColorSilver),
+			},
+			{
+				class:    text.GitHead,
+				style:    tcell.StyleDefault.Bold(true),
+				expected: tcell.StyleDefault.Foreground(tcell.ColorAqua).Bold(true),
+			},
+			{
+				class:    text.ANSIForegroundClass(9),
+				style:    tcell.StyleDefault,
+				expected: tcell.StyleDefault.Foreground(tcell.ColorRed),
+			},
+		}
+		for _, testCase := range testCases {
+			if style := styleSheet[testCase.class](testCase.style); style != testCase.expected {
+				t.Fatalf("class %v: expected %v but got %v", testCase.class, testCase.expected, style)
+			}
+		}
+	})
+
+	t.Run("colors missing from the palette are replaced by the closest color", func(t *testing.T) {
+		theme, err := NewTheme("", map[string]Style{
+			"status_failed": {Foreground: "#ee1111"},
+			"status_passed": {Foreground: "208"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		styleSheet := theme.StyleSheet(16)
+
+		if style := styleSheet[text.StatusFailed](tcell.StyleDefault); style != tcell.StyleDefault.Foreground(tcell.ColorRed) {
+			t.Fatalf("expected red foreground but got %v", style)
+		}
+		fg, _, _ := styleSheet[text.StatusPassed](tcell.StyleDefault).Decompose()
+		if fg < 0 || fg >= 16 {
+			t.Fatalf("expected a color of the 16-color palette but got %v", fg)
+		}
+	})
+
+	t.Run("without colors, backgrounds are replaced by reverse video", func(t *testing.T) {
+		styleSheet := DefaultTheme().StyleSheet(0)
+
+		if style := styleSheet[text.ActiveRow](tcell.StyleDefault); style != tcell.StyleDefault.Reverse(true) {
+			t.Fatalf("expected %v but got %v", tcell.StyleDefault.Reverse(true), style)
+		}
+		if style := styleSheet[text.StatusFailed](tcell.StyleDefault); style != tcell.StyleDefault {
+			t.Fatalf("expected %v but got %v", tcell.StyleDefault, style)
+		}
+		if _, exists := styleSheet[text.ANSIForegroundClass(1)]; exists {
+			t.Fatal("expected ANSI colors to be ignored")
+		}
+	})
+}
@@ -13,62 +13,43 @@ import (
 	"github.com/gdamore/tcell"
 	"github.com/gdamore/tcell/encoding"
 	"github.com/nbedos/citop/cache"
+	"github.com/nbedos/citop/notify"
 	"github.com/nbedos/citop/text"
 )
 
-var ErrNoProvider = errors.New("list of providers must not be empty")
+var ErrNoProvider = errors.New("list of CI providers must not be empty")
 
-func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error), repo string, ref string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, loc *time.Location, help string) (err error) {
-	if len(CIProviders) == 0 || len(SourceProviders) == 0 {
+var ErrNoTarget = errors.New("list of targets must not be empty")
+
+// Run the terminal application. The pipelines of all targets are monitored concurrently.
+// Source providers are optional: without them, only the pipelines listed in the git notes of
+// the local repository or in pipelineURLs are shown. If store is not nil, the cache is loaded
+// from and saved to store. State transitions of pipelines are passed to notifiers. Commands are
+// run by the keys bound to them by keys. Colors of the theme are not used if the environment
+// variable NO_COLOR is set. The table of pipelines shows 'columns'.
+func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error), targets []cache.Target, pipelineURLs []string, CIProviders []cache.CIProvider, SourceProviders []cache.SourceProvider, store *cache.Store, notifiers []notify.Notifier, keys KeyBindings, theme Theme, columns Columns, loc *time.Location, help string) (err error) {
+	if len(CIProviders) == 0 {
 		return ErrNoProvider
 	}
+	if len(targets) == 0 {
+		return ErrNoTarget
+	}
 	// FIXME Discard log until the status bar is implemented in order to hide the "Unsolicited response received on
 	//  idle HTTP channel" from GitLab's HTTP client
 	log.SetOutput(ioutil.Discard)
 	encoding.Register()
 
 	defaultStyle := tcell.StyleDefault
-	styleSheet := text.StyleSheet{
-		text.TableHeader: func(s tcell.Style) tcell.Style {
-			return s.Bold(true).Reverse(true)
-		},
-		text.ActiveRow: func(s tcell.Style) tcell.Style {
-			return s.Background(tcell.ColorSilver).Foreground(tcell.ColorBlack).Bold(false).Underline(false).Blink(false)
-		},
-		text.Provider: func(s tcell.Style) tcell.Style {
-			return s.Bold(true)
-		},
-		text.StatusFailed: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorMaroon).Bold(false)
-		},
-		text.StatusPassed: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorGreen).Bold(false)
-		},
-		text.StatusRunning: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorOlive).Bold(false)
-		},
-		text.StatusSkipped: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorGray).Bold(false)
-		},
-		text.GitSha: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorOlive)
-		},
-		text.GitBranch: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorTeal).Bold(false)
-		},
-		text.GitTag: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorYellow).Bold(false)
-		},
-		text.GitHead: func(s tcell.Style) tcell.Style {
-			return s.Foreground(tcell.ColorAqua)
-		},
-	}
-	defaultStatus := "j:Down  k:Up  oO:Open  cC:Close  /:Search  v:Logs  b:Browser  ?:Help  q:Quit"
-
-	ui, err := NewTUI(newScreen, defaultStyle, styleSheet)
+	ui, err := NewTUI(newScreen, defaultStyle, nil)
 	if err != nil {
 		return err
 	}
+	// See https://no-color.org/
+	colors := ui.screen.Colors()
+	if os.Getenv("NO_COLOR") != "" {
+		colors = 0
+	}
+	ui.styleSheet = theme.StyleSheet(colors)
 	defer func() {
 		// If another goroutine panicked this wouldn't run so we'd be left with a garbled screen.
 		// The alternative would be to defer a call to recover for every goroutine that we launch
@@ -77,13 +58,29 @@ func RunApplication(ctx context.Context, newScreen func() (tcell.Screen, error),
 	}()
 
 	cacheDB := cache.NewCache(CIProviders, SourceProviders)
+	if store != nil {
+		if cacheDB, err = cache.NewPersistentCache(CIProviders, SourceProviders, store); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var notificationErrc chan error
+	if len(notifiers) > 0 {
+		transitions := make(chan cache.StateTransition)
+		notificationErrc = make(chan error)
+		cacheDB.NotifyTransitions(transitions)
+		go notify.Run(ctx, transitions, notifiers, notificationErrc)
+	}
 
-	controller, err := NewController(&ui, ref, cacheDB, loc, defaultStatus, help)
+	controller, err := NewController(&ui, targets, cacheDB, loc, keys, columns, help)
 	if err != nil {
 		return err
 	}
+	controller.notificationErrc = notificationErrc
 
-	return controller.Run(ctx, repo)
+	return controller.Run(ctx, pipelineURLs)
 }
 
 type TUI struct {
@@ -117,7 +114,7 @@ func (t *TUI) init() error {
 		return err
 	}
 	t.screen.SetStyle(t.defaultStyle)
-	//screen.EnableMouse()
+	t.screen.EnableMouse()
 	t.screen.Clear()
 
 	go t.poll()
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gdamore/tcell"
+	"github.com/nbedos/citop/cache"
 	"github.com/nbedos/citop/text"
 )
 
@@ -128,7 +129,8 @@ func TestRunApplication(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		err = RunApplication(ctx, newScreen, pwd, "HEAD", nil, nil, time.UTC, "")
+		targets := []cache.Target{{Repository: pwd, Ref: "HEAD"}}
+		err = RunApplication(ctx, newScreen, targets, nil, nil, nil, nil, nil, DefaultKeyBindings(), DefaultTheme(), Columns{}, time.UTC, "")
 		if err != ErrNoProvider {
 			t.Fatalf("expected %v but got %v", ErrNoProvider, err)
 		}
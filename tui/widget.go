@@ -2,8 +2,29 @@ package tui
 
 import "github.com/nbedos/citop/text"
 
+type mouseAction int
+
+const (
+	mouseClick mouseAction = iota
+	mouseDoubleClick
+	mouseWheelUp
+	mouseWheelDown
+)
+
+// Number of lines scrolled by a turn of the mouse wheel
+const wheelScrollAmount = 3
+
+// Mouse event located relative to the top left corner of the widget it is sent to
+type mouseEvent struct {
+	x      int
+	y      int
+	action mouseAction
+}
+
 type Widget interface {
 	Resize(width int, height int)
 	Text() []text.LocalizedStyledString
 	Size() (width int, height int)
+	// Process a mouse event located on the widget and return the command it leads to, if any
+	ProcessMouse(event mouseEvent) (command, bool)
 }
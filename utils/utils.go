@@ -197,6 +197,13 @@ func getEnvWithDefault(key string, d string) string {
 	return value
 }
 
+// Return the location of a cache file based on
+// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+func XDGCacheLocation(filename string) string {
+	cacheHome := getEnvWithDefault("XDG_CACHE_HOME", path.Join(os.Getenv("HOME"), ".cache"))
+	return path.Join(cacheHome, filename)
+}
+
 // Return possible locations of configuration files based on
 // https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
 func XDGConfigLocations(filename string) []string {